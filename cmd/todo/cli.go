@@ -0,0 +1,289 @@
+package main
+
+import (
+	"github.com/alexflint/go-arg"
+	"go-todo-cli/internal/commands"
+	"strconv"
+	"strings"
+)
+
+// GlobalArgs are the options shared by every command. Their names must not
+// clash with any subcommand's flags, since go-arg gives the global one
+// precedence.
+type GlobalArgs struct {
+	File     string `arg:"-f,--file,env:TODO_FILE" help:"Data file to use instead of the discovered or configured one"`
+	ListName string `arg:"-L,--list-name" help:"List to work on instead of the active one"`
+	Filter   string `arg:"-F,--filter" help:"Only show or change the tasks matching this query, e.g. 'priority:high and tag:work'"`
+	Yes      bool   `arg:"-y,--yes" help:"Change many tasks at once without asking first"`
+	Output   string `arg:"-o,--output" default:"table" help:"Format of listings (ls, ready, search, viz): table, json, ndjson, csv, tsv or markdown"`
+}
+
+// Args defines the command-line arguments structure. Exactly one subcommand
+// is set after parsing.
+type Args struct {
+	GlobalArgs
+
+	Add      *AddCmd      `arg:"subcommand:add" help:"Add a task"`
+	Done     *TasksCmd    `arg:"subcommand:done|complete" help:"Mark tasks as complete"`
+	Remove   *TasksCmd    `arg:"subcommand:rm|delete" help:"Delete tasks"`
+	List     *ListCmd     `arg:"subcommand:ls|list" help:"List tasks"`
+	Edit     *EditCmd     `arg:"subcommand:edit" help:"Edit tasks with flags, in $EDITOR, or interactively"`
+	Tag      *TagCmd      `arg:"subcommand:tag" help:"Add or remove task tags"`
+	Search   *SearchCmd   `arg:"subcommand:search" help:"Search tasks by text and tags"`
+	Show     *ShowCmd     `arg:"subcommand:show" help:"Show the details of a task"`
+	Viz      *VizCmd      `arg:"subcommand:viz|visualize" help:"Visualize task distribution and progress"`
+	Clear    *ClearCmd    `arg:"subcommand:clear" help:"Delete all tasks"`
+	Undo     *StepsCmd    `arg:"subcommand:undo" help:"Undo the last change(s)"`
+	Redo     *StepsCmd    `arg:"subcommand:redo" help:"Redo the last undone change(s)"`
+	History  *HistoryCmd  `arg:"subcommand:history" help:"List recorded changes"`
+	Lists    *ListsCmd    `arg:"subcommand:lists" help:"Show and manage named lists"`
+	Move     *MoveCmd     `arg:"subcommand:mv|move" help:"Move tasks to another list"`
+	Dep      *DepCmd      `arg:"subcommand:dep" help:"Add or remove task dependencies"`
+	Ready    *ReadyCmd    `arg:"subcommand:ready" help:"List pending tasks that are not blocked"`
+	Recur    *RecurCmd    `arg:"subcommand:recur" help:"Make a task repeat, or stop it repeating"`
+	Status   *StatusCmd   `arg:"subcommand:status" help:"Show or change the status of tasks"`
+	Start    *TasksCmd    `arg:"subcommand:start" help:"Move tasks to the workflow's started state (default in-progress)"`
+	Cancel   *TasksCmd    `arg:"subcommand:cancel" help:"Move tasks to the workflow's cancelled state (default cancelled)"`
+	Reopen   *TasksCmd    `arg:"subcommand:reopen" help:"Move tasks back to their initial status"`
+	Agenda   *AgendaCmd   `arg:"subcommand:agenda" help:"List open tasks grouped by when they are due"`
+	Next     *NextCmd     `arg:"subcommand:next" help:"Show the most urgent task"`
+	Annotate *AnnotateCmd `arg:"subcommand:annotate" help:"Add a timestamped note to tasks"`
+}
+
+func (Args) Description() string {
+	return "A command-line TODO list manager."
+}
+
+func (Args) Epilogue() string {
+	return "Run 'todo <command> --help' for the options of a command.\n" +
+		"The flags of earlier versions (--add, --complete, --list, ...) still work but are deprecated."
+}
+
+type AddCmd struct {
+	Task      []string `arg:"positional,required" help:"Task description"`
+	Due       string   `arg:"-d,--due" help:"Due date: YYYY-MM-DD, today, tomorrow, fri, next monday, in 3 days, 2w, eow, eom, with an optional time like 14:00"`
+	Priority  string   `arg:"-p,--priority" help:"Priority: low, medium or high"`
+	Tags      string   `arg:"-t,--tags" help:"Comma-separated tags"`
+	Parent    int      `arg:"--parent" help:"Add the task as a subtask of this task ID"`
+	DependsOn string   `arg:"--depends-on" help:"Comma-separated IDs of tasks that must be done first"`
+	Recur     string   `arg:"--recur" help:"Repeat the task, e.g. 'every 2 weeks' or an RRULE"`
+	Notes     string   `arg:"-n,--notes" help:"Longer notes, links or context for the task"`
+	DryRun    bool     `arg:"--dry-run" help:"Only show the resolved due date; do not add the task"`
+}
+
+type IDCmd struct {
+	ID int `arg:"positional,required" help:"Task ID"`
+}
+
+// TasksCmd selects the tasks a command changes.
+type TasksCmd struct {
+	Tasks []string `arg:"positional,required" help:"Task IDs and ranges, e.g. 1,3,5-9, or a filter query, e.g. tag:sprint-12 and status:open"`
+}
+
+type ListCmd struct {
+	Query     []string `arg:"positional" help:"Filter query, e.g. tag:work and not status:done"`
+	Tag       string   `arg:"--tag" help:"Only list tasks with this tag"`
+	ByUrgency bool     `arg:"-u,--by-urgency" help:"List the most urgent tasks first; short for --sort urgency-"`
+	Sort      string   `arg:"-s,--sort" help:"Comma-separated fields to sort by, each optionally followed by - for descending order, e.g. priority-,due"`
+	Columns   string   `arg:"--columns" help:"Comma-separated columns to show, in order"`
+	View      string   `arg:"--view" help:"Sort order and columns saved in the config file under this name"`
+}
+
+// EditCmd asks for the changes interactively when neither --editor nor a
+// field flag is given.
+type EditCmd struct {
+	Tasks      string `arg:"positional,required" help:"Task ID; with field flags also IDs and ranges, e.g. 1,3,5-9, or a quoted filter query"`
+	Task       string `arg:"--task" help:"New description"`
+	Due        string `arg:"-d,--due" help:"New due date, in any form add --due takes"`
+	ClearDue   bool   `arg:"--clear-due" help:"Remove the due date"`
+	Priority   string `arg:"-p,--priority" help:"New priority: low, medium or high"`
+	Tags       string `arg:"-t,--tags" help:"Comma-separated tags replacing the current ones"`
+	ClearTags  bool   `arg:"--clear-tags" help:"Remove all tags"`
+	Notes      string `arg:"-n,--notes" help:"Notes replacing the current ones"`
+	ClearNotes bool   `arg:"--clear-notes" help:"Remove the notes"`
+	Editor     bool   `arg:"--editor" help:"Edit the task as a document in $VISUAL or $EDITOR"`
+}
+
+func (c EditCmd) hasFieldFlags() bool {
+	return c.Task != "" || c.Due != "" || c.ClearDue || c.Priority != "" || c.Tags != "" || c.ClearTags || c.Notes != "" || c.ClearNotes
+}
+
+type TagCmd struct {
+	Add    *TagChangeCmd `arg:"subcommand:add" help:"Add a tag to a task"`
+	Remove *TagChangeCmd `arg:"subcommand:rm|remove" help:"Remove a tag from a task"`
+}
+
+type TagChangeCmd struct {
+	Tasks string `arg:"positional,required" help:"Task IDs and ranges, e.g. 1,3,5-9, or a quoted filter query"`
+	Tag   string `arg:"positional,required" help:"Tag"`
+}
+
+type SearchCmd struct {
+	Keywords []string `arg:"positional,required" help:"Text to look for"`
+}
+
+type ShowCmd struct {
+	ID      int  `arg:"positional,required" help:"Task ID"`
+	History bool `arg:"--history" help:"Also show the task's change history"`
+}
+
+type VizCmd struct{}
+
+type ClearCmd struct{}
+
+type StepsCmd struct {
+	Steps int `arg:"positional" default:"1" help:"Number of changes"`
+}
+
+type HistoryCmd struct{}
+
+// ListsCmd shows the lists when no subcommand is given.
+type ListsCmd struct {
+	Archived  bool           `arg:"--archived" help:"Also show archived lists"`
+	New       *ListNameCmd   `arg:"subcommand:new|create" help:"Create a list"`
+	Rename    *RenameListCmd `arg:"subcommand:rename" help:"Rename a list and move its tasks along"`
+	Archive   *ListNameCmd   `arg:"subcommand:archive" help:"Archive a list"`
+	Unarchive *ListNameCmd   `arg:"subcommand:unarchive" help:"Restore an archived list"`
+	Use       *ListNameCmd   `arg:"subcommand:use|switch" help:"Make a list the active one"`
+}
+
+type ListNameCmd struct {
+	Name string `arg:"positional,required" help:"List name"`
+}
+
+type RenameListCmd struct {
+	Name    string `arg:"positional,required" help:"Current list name"`
+	NewName string `arg:"positional,required" help:"New list name"`
+}
+
+type DepCmd struct {
+	Add    *DepChangeCmd `arg:"subcommand:add" help:"Make a task wait for another"`
+	Remove *DepChangeCmd `arg:"subcommand:rm|remove" help:"Remove a dependency"`
+}
+
+type DepChangeCmd struct {
+	ID        int `arg:"positional,required" help:"Task ID"`
+	DependsOn int `arg:"positional,required" help:"ID of the task it waits for"`
+}
+
+type AnnotateCmd struct {
+	Tasks string   `arg:"positional,required" help:"Task IDs and ranges, e.g. 1,3,5-9, or a quoted filter query"`
+	Text  []string `arg:"positional,required" help:"The annotation, e.g. \"called the vendor\""`
+}
+
+type ReadyCmd struct{}
+
+type NextCmd struct{}
+
+type AgendaCmd struct {
+	Summary bool `arg:"--summary" help:"Only print the number of overdue, due today and open tasks"`
+}
+
+type StatusCmd struct {
+	Tasks string `arg:"positional,required" help:"Task IDs and ranges, e.g. 1,3,5-9, or a quoted filter query"`
+	State string `arg:"positional" help:"New status, e.g. in-progress, waiting or cancelled"`
+}
+
+type RecurCmd struct {
+	ID    int    `arg:"positional,required" help:"Task ID"`
+	Rule  string `arg:"positional" help:"Recurrence rule, e.g. 'every monday' or 'FREQ=MONTHLY;BYMONTHDAY=1'"`
+	Clear bool   `arg:"--clear" help:"Stop the task repeating"`
+}
+
+type MoveCmd struct {
+	Tasks string `arg:"positional,required" help:"Task IDs and ranges, e.g. 1,3,5-9, or a quoted filter query"`
+	List  string `arg:"positional,required" help:"List to move the task to"`
+}
+
+// parseCommandLine parses argv, which is either a subcommand invocation or a
+// deprecated flag-style one. Like arg.MustParse it prints help or usage errors
+// and exits for malformed input.
+func parseCommandLine(argv []string) (Args, error) {
+	if isLegacyInvocation(argv) {
+		var legacy LegacyArgs
+		p, err := arg.NewParser(arg.Config{Program: "todo", Exit: exitUsage}, &legacy)
+		if err != nil {
+			return Args{}, err
+		}
+		p.MustParse(argv)
+		return legacy.toArgs()
+	}
+
+	var args Args
+	p, err := arg.NewParser(arg.Config{Program: "todo", Exit: exitUsage}, &args)
+	if err != nil {
+		return Args{}, err
+	}
+	p.MustParse(argv)
+	if p.Subcommand() == nil {
+		return args, commands.Errorf(commands.InvalidInput, "invalid command. Use --help for usage information")
+	}
+	return args, validate(args)
+}
+
+// parseTaskID returns the task ID s holds, or 0 if it is not a number.
+func parseTaskID(s string) int {
+	id, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// validate checks what go-arg cannot express in struct tags.
+func validate(args Args) error {
+	var id int
+	switch {
+	case args.Add != nil && args.Add.Parent < 0:
+		return commands.Errorf(commands.InvalidInput, "invalid task ID: %d", args.Add.Parent)
+	case args.Edit != nil:
+		switch {
+		case args.Edit.Due != "" && args.Edit.ClearDue:
+			return commands.Errorf(commands.InvalidInput, "--due cannot be combined with --clear-due")
+		case args.Edit.Tags != "" && args.Edit.ClearTags:
+			return commands.Errorf(commands.InvalidInput, "--tags cannot be combined with --clear-tags")
+		case args.Edit.Notes != "" && args.Edit.ClearNotes:
+			return commands.Errorf(commands.InvalidInput, "--notes cannot be combined with --clear-notes")
+		case args.Edit.Editor && args.Edit.hasFieldFlags():
+			return commands.Errorf(commands.InvalidInput, "--editor cannot be combined with other edit flags")
+		case args.Edit.hasFieldFlags():
+			return nil
+		}
+		if id = parseTaskID(args.Edit.Tasks); id <= 0 {
+			return commands.Errorf(commands.InvalidInput, "invalid task ID: %s (only field flags edit several tasks at once)", args.Edit.Tasks)
+		}
+	case args.Show != nil:
+		id = args.Show.ID
+	case args.Recur != nil:
+		if (args.Recur.Rule == "") == !args.Recur.Clear {
+			return commands.Errorf(commands.InvalidInput, "usage: todo recur <task_id> <rule> | --clear")
+		}
+		id = args.Recur.ID
+	case args.Dep != nil:
+		var change *DepChangeCmd
+		switch {
+		case args.Dep.Add != nil:
+			change = args.Dep.Add
+		case args.Dep.Remove != nil:
+			change = args.Dep.Remove
+		default:
+			return commands.Errorf(commands.InvalidInput, "usage: todo dep add|rm <task_id> <depends_on_id>")
+		}
+		if change.DependsOn <= 0 {
+			return commands.Errorf(commands.InvalidInput, "invalid task ID: %d", change.DependsOn)
+		}
+		id = change.ID
+	case args.Tag != nil && args.Tag.Add == nil && args.Tag.Remove == nil:
+		return commands.Errorf(commands.InvalidInput, "usage: todo tag add|rm <task_ids> <tag>")
+	case args.List != nil && args.List.Sort != "" && args.List.ByUrgency:
+		return commands.Errorf(commands.InvalidInput, "--by-urgency cannot be combined with --sort")
+	case args.Undo != nil && args.Undo.Steps < 1, args.Redo != nil && args.Redo.Steps < 1:
+		return commands.Errorf(commands.InvalidInput, "the number of changes must be at least 1")
+	default:
+		return nil
+	}
+	if id <= 0 {
+		return commands.Errorf(commands.InvalidInput, "invalid task ID: %d", id)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"go-todo-cli/internal/commands"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LegacyArgs is the flag-only command line of earlier versions. Each command
+// flag is translated to the matching subcommand with a deprecation warning.
+type LegacyArgs struct {
+	GlobalArgs
+
+	Add       []string `arg:"-a,--add" help:"Add a task to the TODO list (deprecated: use 'todo add')"`
+	DueDate   string   `arg:"-d,--due" help:"Set a due date for the task (format: YYYY-MM-DD)"`
+	Priority  string   `arg:"-p,--priority" help:"Set a priority for the task (low, medium, high)"`
+	Tags      string   `arg:"-t,--tags" help:"Comma-separated tags for the task"`
+	Complete  int      `arg:"-c,--complete" help:"Mark a task as complete by ID (deprecated: use 'todo done')"`
+	Delete    int      `arg:"-r,--delete" help:"Delete a task by ID (deprecated: use 'todo rm')"`
+	List      bool     `arg:"-l,--list" help:"List all tasks (deprecated: use 'todo ls')"`
+	Clear     bool     `arg:"-x,--clear-tasks" help:"Clear all tasks (deprecated: use 'todo clear')"`
+	Edit      int      `arg:"-e,--edit" help:"Edit a task by ID (deprecated: use 'todo edit')"`
+	AddTag    []string `arg:"--add-tag" help:"Add a tag to a task: <task_id> <tag> (deprecated: use 'todo tag add')"`
+	RemoveTag []string `arg:"--remove-tag" help:"Remove a tag from a task: <task_id> <tag> (deprecated: use 'todo tag rm')"`
+	FilterTag string   `arg:"--filter-tag" help:"Filter tasks by tag (deprecated: use 'todo ls --tag')"`
+	Search    []string `arg:"--search" help:"Search for tasks containing the given keyword (deprecated: use 'todo search')"`
+	Visualize bool     `arg:"--visualize" help:"Visualize task distribution and progress (deprecated: use 'todo viz')"`
+}
+
+// legacyCommandFlags are the flags that selected a command in the flag-only
+// command line. Subcommands must not define flags with these names.
+var legacyCommandFlags = map[string]bool{
+	"-a": true, "--add": true,
+	"-c": true, "--complete": true,
+	"-r": true, "--delete": true,
+	"-l": true, "--list": true,
+	"-x": true, "--clear-tasks": true,
+	"-e": true, "--edit": true,
+	"--add-tag":    true,
+	"--remove-tag": true,
+	"--filter-tag": true,
+	"--search":     true,
+	"--visualize":  true,
+}
+
+// isLegacyInvocation reports whether argv uses the deprecated flag-style
+// command line, i.e. whether it contains one of the old command flags.
+func isLegacyInvocation(argv []string) bool {
+	for _, a := range argv {
+		if a == "--" {
+			return false
+		}
+		name, _, _ := strings.Cut(a, "=")
+		if legacyCommandFlags[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// toArgs translates the legacy flags into the equivalent subcommand. Earlier
+// versions silently ran only the first command flag given; giving more than
+// one is now an error.
+func (l LegacyArgs) toArgs() (Args, error) {
+	args := Args{GlobalArgs: l.GlobalArgs}
+	var used []string
+	use := func(flag, replacement string) {
+		used = append(used, flag)
+		fmt.Fprintf(os.Stderr, "warning: %s is deprecated, use 'todo %s' instead\n", flag, replacement)
+	}
+
+	if len(l.Add) > 0 {
+		use("--add", "add")
+		args.Add = &AddCmd{Task: l.Add, Due: l.DueDate, Priority: l.Priority, Tags: l.Tags}
+	}
+	if l.Complete != 0 {
+		use("--complete", "done")
+		args.Done = &TasksCmd{Tasks: []string{strconv.Itoa(l.Complete)}}
+	}
+	if l.Delete != 0 {
+		use("--delete", "rm")
+		args.Remove = &TasksCmd{Tasks: []string{strconv.Itoa(l.Delete)}}
+	}
+	if l.List {
+		use("--list", "ls")
+		args.List = &ListCmd{}
+	}
+	if l.Clear {
+		use("--clear-tasks", "clear")
+		args.Clear = &ClearCmd{}
+	}
+	if l.Edit != 0 {
+		use("--edit", "edit")
+		args.Edit = &EditCmd{Tasks: strconv.Itoa(l.Edit)}
+	}
+	if l.AddTag != nil {
+		use("--add-tag", "tag add")
+		change, err := legacyTagChange("--add-tag", l.AddTag)
+		if err != nil {
+			return args, err
+		}
+		args.Tag = &TagCmd{Add: change}
+	}
+	if l.RemoveTag != nil {
+		use("--remove-tag", "tag rm")
+		change, err := legacyTagChange("--remove-tag", l.RemoveTag)
+		if err != nil {
+			return args, err
+		}
+		args.Tag = &TagCmd{Remove: change}
+	}
+	if l.FilterTag != "" {
+		use("--filter-tag", "ls --tag")
+		args.List = &ListCmd{Tag: l.FilterTag}
+	}
+	if len(l.Search) > 0 {
+		use("--search", "search")
+		args.Search = &SearchCmd{Keywords: l.Search}
+	}
+	if l.Visualize {
+		use("--visualize", "viz")
+		args.Viz = &VizCmd{}
+	}
+
+	switch {
+	case len(used) == 0:
+		return args, commands.Errorf(commands.InvalidInput, "invalid command. Use --help for usage information")
+	case len(used) > 1:
+		return args, commands.Errorf(commands.InvalidInput, "only one command can be given at a time, got %s", strings.Join(used, " and "))
+	case args.Add == nil && (l.DueDate != "" || l.Priority != "" || l.Tags != ""):
+		return args, commands.Errorf(commands.InvalidInput, "--due, --priority and --tags can only be used with --add")
+	}
+	return args, validate(args)
+}
+
+func legacyTagChange(flag string, values []string) (*TagChangeCmd, error) {
+	if len(values) != 2 {
+		return nil, commands.Errorf(commands.InvalidInput, "%s takes <task_id> <tag>", flag)
+	}
+	return &TagChangeCmd{Tasks: values[0], Tag: values[1]}, nil
+}
@@ -1,139 +1,610 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"github.com/alexflint/go-arg"
 	"go-todo-cli/internal/commands"
+	"go-todo-cli/internal/config"
+	"go-todo-cli/internal/filter"
 	"go-todo-cli/internal/todo"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+	// Due times name their time zone, which has to load on systems without
+	// a zone database too.
+	_ "time/tzdata"
 )
 
-var defaultFileToWrite = "todos.json"
-
-// Args defines the command-line arguments structure
-type Args struct {
-	Add       []string `arg:"-a,--add" help:"Add a task to the TODO list"`
-	DueDate   string   `arg:"-d,--due" help:"Set a due date for the task (format: YYYY-MM-DD)"`
-	Priority  string   `arg:"-p,--priority" help:"Set a priority for the task (low, medium, high)"`
-	Tags      string   `arg:"-t,--tags" help:"Comma-separated tags for the task"`
-	Complete  int      `arg:"-c,--complete" help:"Mark a task as complete"`
-	Delete    int      `arg:"-r,--delete" help:"Delete a task"`
-	List      bool     `arg:"-l,--list" help:"List all tasks"`
-	Clear     bool     `arg:"-x,--clear-tasks" help:"Clear all tasks"`
-	Edit      int      `arg:"-e,--edit" help:"Edit a task"`
-	AddTag    []string `arg:"--add-tag" help:"Add a tag to a task: <task_number> <tag>"`
-	RemoveTag []string `arg:"--remove-tag" help:"Remove a tag from a task: <task_number> <tag>"`
-	FilterTag string   `arg:"--filter-tag" help:"Filter tasks by tag"`
-	Search    []string `arg:"--search" help:"Search for tasks containing the given keyword"`
-	Visualize bool     `arg:"--visualize" help:"Visualize task distribution and progress"`
-}
+// Exit codes, also documented in the README.
+const (
+	exitOK           = 0
+	exitFailure      = 1
+	exitInvalidInput = 2
+	exitNotFound     = 3
+	exitStorage      = 4
+	exitConflict     = 5
+)
 
 func main() {
-	var args Args
-	arg.MustParse(&args)
-
-	_, err := parseArgs(args)
+	args, err := parseCommandLine(os.Args[1:])
+	if err == nil {
+		_, err = parseArgs(args)
+	}
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	}
+	os.Exit(exitCode(err))
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	switch commands.KindOf(err) {
+	case commands.InvalidInput:
+		return exitInvalidInput
+	case commands.NotFound:
+		return exitNotFound
+	case commands.Storage:
+		return exitStorage
+	case commands.Conflict:
+		return exitConflict
+	default:
+		return exitFailure
 	}
 }
 
+// exitUsage replaces os.Exit for go-arg, which exits with -1 for usage errors.
+func exitUsage(code int) {
+	if code != exitOK {
+		code = exitInvalidInput
+	}
+	os.Exit(code)
+}
+
 func parseArgs(args Args) (todo.Todos, error) {
-	todoList := &todo.Todos{}
-	err := handleFileLoading(todoList, defaultFileToWrite)
+	cfg, err := config.Load(config.Path())
 	if err != nil {
-		return *todoList, err
+		return nil, commands.Errorf(commands.InvalidInput, "%w", err)
 	}
 
-	err = executeCommand(args, todoList)
+	cwd, err := os.Getwd()
 	if err != nil {
-		return *todoList, err
+		return nil, commands.Errorf(commands.Storage, "%w", err)
+	}
+	filename := cfg.ResolveDataFile(args.File, cwd)
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, commands.Errorf(commands.Storage, "%w", err)
+	}
+	store, err := todo.OpenStore(cfg.Backend, filename)
+	if err != nil {
+		return nil, commands.Errorf(commands.InvalidInput, "%w", err)
+	}
+	commands.Store = store
+	if commands.SubtaskRules, err = subtaskRules(cfg.Subtasks); err != nil {
+		return nil, err
+	}
+	if todo.ActiveWorkflow, err = workflow(cfg.Workflow); err != nil {
+		return nil, err
+	}
+	if todo.ActiveUrgency, err = urgency(cfg.Urgency); err != nil {
+		return nil, err
 	}
+	if commands.View, err = tableView(cfg.Views, args.List); err != nil {
+		return nil, err
+	}
+	if commands.Filter, err = parseFilter(args); err != nil {
+		return nil, err
+	}
+	if commands.ConfirmAbove, err = confirmAbove(cfg.ConfirmAbove, args.Yes); err != nil {
+		return nil, err
+	}
+	if commands.Output, err = outputFormat(args); err != nil {
+		return nil, err
+	}
+
+	todo.Color = colorEnabled()
+	handleFileLoading(filename)
+
+	// Update holds the file lock from loading the list until the changed list
+	// is saved, so concurrent invocations run one after the other. A command
+	// that needs to ask the user something gives up the lock to ask, and runs
+	// again with the answer.
+	var result todo.Todos
+	var journal *todo.Journal
+	err = commands.WithAnswers(func() error {
+		return store.Update(func(todoList *todo.Todos) error {
+			var err error
+			if journal, err = todo.LoadJournal(filename + ".journal"); err != nil {
+				return err
+			}
+
+			lists := &store.Meta().ListSet
+			commands.ListName = args.ListName
+			if commands.ListName == "" {
+				commands.ListName = lists.ActiveName()
+			}
+			if lists.Find(commands.ListName) == nil {
+				return commands.Errorf(commands.NotFound, "%w: %s", todo.ErrNoSuchList, commands.ListName)
+			}
+
+			before := todoList.Clone()
+			listsBefore := lists.Clone()
+			if err := executeCommand(args, todoList, journal); err != nil {
+				return err
+			}
+			if args.Undo == nil && args.Redo == nil {
+				if err := journal.RecordLists(describeCommand(os.Args[1:]), before, *todoList, listsBefore, *lists); err != nil {
+					return err
+				}
+			}
+			result = *todoList
+			return nil
+		}, func() error {
+			// Only once the list is saved, so that the journal never holds a
+			// change the list does not, which undo could never get past.
+			return journal.Save()
+		})
+	})
+	return result, storeError(err)
+}
 
-	return *todoList, todoList.Save(defaultFileToWrite)
+func subtaskRules(cfg config.SubtaskConfig) (todo.SubtaskRules, error) {
+	var rules todo.SubtaskRules
+	var err error
+	if rules.OnComplete, err = todo.ParseSubtaskPolicy(cfg.OnComplete); err != nil {
+		return rules, commands.Errorf(commands.InvalidInput, "subtasks.on_complete: %w", err)
+	}
+	if rules.OnDelete, err = todo.ParseSubtaskPolicy(cfg.OnDelete); err != nil {
+		return rules, commands.Errorf(commands.InvalidInput, "subtasks.on_delete: %w", err)
+	}
+	return rules, nil
 }
 
-func handleFileLoading(todoList *todo.Todos, filename string) error {
-	if err := todoList.Load(filename); err != nil {
-		if os.IsNotExist(err) {
-			fmt.Println("File not found, creating a new todos.json file.")
-			return todoList.Save(filename)
+// workflow builds the task workflow from the config file, filling in the
+// defaults documented on config.WorkflowConfig.
+func workflow(cfg *config.WorkflowConfig) (todo.Workflow, error) {
+	if cfg == nil {
+		return todo.DefaultWorkflow, nil
+	}
+	w := todo.Workflow{States: cfg.States, Initial: cfg.Initial, Done: cfg.Done, Started: cfg.Started, Cancelled: cfg.Cancelled, Closed: cfg.Closed, Transitions: cfg.Transitions}
+	if w.Initial == "" && len(w.States) > 0 {
+		w.Initial = w.States[0]
+	}
+	if w.Done == "" {
+		w.Done = "done"
+	}
+	if w.Started == "" && w.Has(todo.DefaultWorkflow.Started) {
+		w.Started = todo.DefaultWorkflow.Started
+	}
+	if w.Cancelled == "" && w.Has(todo.DefaultWorkflow.Cancelled) {
+		w.Cancelled = todo.DefaultWorkflow.Cancelled
+	}
+	if err := w.Validate(); err != nil {
+		return w, commands.Errorf(commands.InvalidInput, "config: %w", err)
+	}
+	return w, nil
+}
+
+// urgency applies the coefficients set in the config file over the
+// defaults.
+func urgency(cfg *config.UrgencyConfig) (todo.UrgencyCoefficients, error) {
+	c := todo.DefaultUrgency
+	if cfg == nil {
+		return c, nil
+	}
+	c.Priority = make(map[todo.Priority]float64)
+	for p, v := range todo.DefaultUrgency.Priority {
+		c.Priority[p] = v
+	}
+	for name, v := range cfg.Priority {
+		p, err := todo.ParsePriority(name)
+		if err != nil {
+			return c, commands.Errorf(commands.InvalidInput, "urgency.priority: %w", err)
 		}
-		return fmt.Errorf("error loading go-todo-cli file: %w", err)
+		c.Priority[p] = v
 	}
-	return nil
+	c.Tags = mergeWeights(todo.DefaultUrgency.Tags, cfg.Tags)
+	c.Status = mergeWeights(todo.DefaultUrgency.Status, cfg.Status)
+	if cfg.Due != nil {
+		c.Due = *cfg.Due
+	}
+	if cfg.Age != nil {
+		c.Age = *cfg.Age
+	}
+	if cfg.Blocked != nil {
+		c.Blocked = *cfg.Blocked
+	}
+	return c, nil
+}
+
+func mergeWeights(defaults, overrides map[string]float64) map[string]float64 {
+	merged := make(map[string]float64, len(defaults)+len(overrides))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
+
+// tableView works out how to lay out task tables: the view named by --view,
+// or else the config file's "default" view, with --sort and --columns on top.
+func tableView(views map[string]config.ViewConfig, cmd *ListCmd) (commands.TableView, error) {
+	name := "default"
+	if cmd != nil && cmd.View != "" {
+		name = cmd.View
+		if _, ok := views[name]; !ok {
+			return commands.TableView{}, commands.Errorf(commands.NotFound, "no view named '%s' in the config file", name)
+		}
+	}
+	var view commands.TableView
+	var err error
+	saved := views[name]
+	if view.Sort, err = todo.ParseSort(saved.Sort); err != nil {
+		return view, commands.Errorf(commands.InvalidInput, "views.%s.sort: %w", name, err)
+	}
+	if saved.Columns != "" {
+		if view.Columns, err = todo.ParseColumns(saved.Columns); err != nil {
+			return view, commands.Errorf(commands.InvalidInput, "views.%s.columns: %w", name, err)
+		}
+	}
+	if cmd == nil {
+		return view, nil
+	}
+
+	sortSpec := cmd.Sort
+	if cmd.ByUrgency {
+		sortSpec = "urgency-"
+	}
+	if sortSpec != "" {
+		if view.Sort, err = todo.ParseSort(sortSpec); err != nil {
+			return view, commands.Errorf(commands.InvalidInput, "%w", err)
+		}
+	}
+	if cmd.Columns != "" {
+		if view.Columns, err = todo.ParseColumns(cmd.Columns); err != nil {
+			return view, commands.Errorf(commands.InvalidInput, "%w", err)
+		}
+	}
+	return view, nil
+}
+
+// parseFilter combines --filter with the query given to ls.
+func parseFilter(args Args) (*filter.Filter, error) {
+	queries := []string{args.Filter}
+	if args.List != nil {
+		queries = append(queries, strings.Join(args.List.Query, " "))
+	}
+	var combined *filter.Filter
+	for _, query := range queries {
+		if strings.TrimSpace(query) == "" {
+			continue
+		}
+		f, err := filter.Parse(query, todo.Now())
+		if err != nil {
+			return nil, commands.Errorf(commands.InvalidInput, "%w", err)
+		}
+		combined = combined.And(f)
+	}
+	return combined, nil
+}
+
+// confirmAbove is how many tasks a command may change without asking: none
+// with --yes, else the configured number.
+func confirmAbove(configured *int, yes bool) (int, error) {
+	switch {
+	case yes:
+		return -1, nil
+	case configured == nil:
+		return commands.ConfirmAbove, nil
+	case *configured < 0:
+		return 0, commands.Errorf(commands.InvalidInput, "confirm_above must not be negative")
+	}
+	return *configured, nil
 }
 
-func executeCommand(args Args, todoList *todo.Todos) error {
+// outputFormat checks --output, which only listings take.
+func outputFormat(args Args) (string, error) {
+	format, err := todo.ParseFormat(args.Output)
+	if err != nil {
+		return "", commands.Errorf(commands.InvalidInput, "%w", err)
+	}
+	if format != "table" && args.List == nil && args.Ready == nil && args.Search == nil && args.Viz == nil {
+		return "", commands.Errorf(commands.InvalidInput, "--output only applies to ls, ready, search and viz")
+	}
+	return format, nil
+}
+
+// colorEnabled reports whether to highlight output: only on a terminal, and
+// not when NO_COLOR is set (see https://no-color.org) or TERM is "dumb".
+func colorEnabled() bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// storeError classifies errors that did not come from a command: failing to
+// get the lock is a conflict with another process, anything else a storage
+// failure.
+func storeError(err error) error {
+	var cmdErr *commands.Error
 	switch {
-	case len(args.Add) > 0:
-		return handleAddCommand(args, todoList)
-	case args.Complete > 0:
-		commands.CompleteCommand([]string{fmt.Sprint(args.Complete)}, todoList)
-	case args.Delete > 0:
-		commands.DeleteCommand([]string{fmt.Sprint(args.Delete)}, todoList)
-	case args.List:
-		commands.ListCommand(todoList)
-	case args.Clear:
-		commands.ClearTasksCommand(todoList)
-	case args.Edit > 0:
-		commands.EditCommand(args.Edit, todoList)
-	case len(args.AddTag) == 2:
-		commands.AddTagCommand(args.AddTag, todoList)
-	case len(args.RemoveTag) == 2:
-		commands.RemoveTagCommand(args.RemoveTag, todoList)
-	case args.FilterTag != "":
-		commands.FilterByTagCommand([]string{args.FilterTag}, todoList)
-	case len(args.Search) > 0:
-		commands.SearchCommand(args.Search, todoList)
-	case args.Visualize:
-		commands.VisualizeCommand(todoList)
+	case err == nil, errors.As(err, &cmdErr):
+		return err
+	case errors.Is(err, todo.ErrLocked):
+		return commands.Errorf(commands.Conflict, "%w; is another todo command still running?", err)
 	default:
-		return fmt.Errorf("invalid command. Use --help for usage information")
+		return commands.Errorf(commands.Storage, "%w", err)
+	}
+}
+
+// globalFlags are the flags of GlobalArgs, and whether each takes a value.
+var globalFlags = map[string]bool{
+	"-f": true, "--file": true,
+	"-L": true, "--list-name": true,
+	"-F": true, "--filter": true,
+	"-o": true, "--output": true,
+	"-y": false, "--yes": false,
+}
+
+// describeCommand is how the invocation argv is shown in the undo history:
+// the command and its own arguments, without global flags such as the file
+// it ran against.
+func describeCommand(argv []string) string {
+	var words []string
+	for i := 0; i < len(argv); i++ {
+		if argv[i] == "--" {
+			words = append(words, argv[i:]...)
+			break
+		}
+		name, _, hasValue := strings.Cut(argv[i], "=")
+		if takesValue, ok := globalFlags[name]; ok {
+			if takesValue && !hasValue {
+				i++
+			}
+			continue
+		}
+		words = append(words, argv[i])
+	}
+	return strings.Join(words, " ")
+}
+
+func handleFileLoading(filename string) {
+	if _, err := os.Stat(filename); errors.Is(err, os.ErrNotExist) {
+		// Keep the notice out of output meant for other programs.
+		out := os.Stdout
+		if commands.Output != "table" {
+			out = os.Stderr
+		}
+		fmt.Fprintf(out, "File not found, creating a new %s file.\n", filename)
+	}
+}
+
+func executeCommand(args Args, todoList *todo.Todos, journal *todo.Journal) error {
+	switch {
+	case args.Add != nil:
+		return handleAddCommand(args.Add, todoList)
+	case args.Done != nil:
+		return commands.CompleteCommand(args.Done.Tasks, todoList)
+	case args.Remove != nil:
+		return commands.DeleteCommand(args.Remove.Tasks, todoList)
+	case args.List != nil && args.List.Tag != "":
+		return commands.FilterByTagCommand([]string{args.List.Tag}, todoList)
+	case args.List != nil:
+		return commands.ListCommand(todoList)
+	case args.Clear != nil:
+		return commands.ClearTasksCommand(todoList)
+	case args.Edit != nil:
+		return handleEditCommand(args.Edit, todoList)
+	case args.Tag != nil && args.Tag.Add != nil:
+		return commands.AddTagCommand([]string{args.Tag.Add.Tasks, args.Tag.Add.Tag}, todoList)
+	case args.Tag != nil && args.Tag.Remove != nil:
+		return commands.RemoveTagCommand([]string{args.Tag.Remove.Tasks, args.Tag.Remove.Tag}, todoList)
+	case args.Search != nil:
+		return commands.SearchCommand(args.Search.Keywords, todoList)
+	case args.Show != nil:
+		return commands.ShowCommand([]string{fmt.Sprint(args.Show.ID)}, args.Show.History, todoList)
+	case args.Viz != nil:
+		return commands.VisualizeCommand(todoList)
+	case args.Undo != nil:
+		return commands.UndoCommand(args.Undo.Steps, journal, todoList)
+	case args.Redo != nil:
+		return commands.RedoCommand(args.Redo.Steps, journal, todoList)
+	case args.History != nil:
+		return commands.HistoryCommand(journal)
+	case args.Lists != nil:
+		return executeListsCommand(args.Lists, todoList)
+	case args.Move != nil:
+		return commands.MoveCommand([]string{args.Move.Tasks, args.Move.List}, todoList)
+	case args.Dep != nil && args.Dep.Add != nil:
+		return commands.DependCommand(args.Dep.Add.ID, args.Dep.Add.DependsOn, todoList)
+	case args.Dep != nil && args.Dep.Remove != nil:
+		return commands.UndependCommand(args.Dep.Remove.ID, args.Dep.Remove.DependsOn, todoList)
+	case args.Ready != nil:
+		return commands.ReadyCommand(todoList)
+	case args.Recur != nil:
+		return commands.RecurCommand(args.Recur.ID, args.Recur.Rule, todoList)
+	case args.Status != nil:
+		return commands.StatusCommand(args.Status.Tasks, args.Status.State, todoList)
+	case args.Start != nil:
+		return moveToRole("start", "started", todo.ActiveWorkflow.Started, args.Start.Tasks, todoList)
+	case args.Cancel != nil:
+		return moveToRole("cancel", "cancelled", todo.ActiveWorkflow.Cancelled, args.Cancel.Tasks, todoList)
+	case args.Reopen != nil:
+		return commands.StatusCommand(strings.Join(args.Reopen.Tasks, " "), todo.ActiveWorkflow.Initial, todoList)
+	case args.Agenda != nil:
+		return commands.AgendaCommand(args.Agenda.Summary, todoList)
+	case args.Next != nil:
+		return commands.NextCommand(todoList)
+	case args.Annotate != nil:
+		return commands.AnnotateCommand(args.Annotate.Tasks, strings.Join(args.Annotate.Text, " "), todoList)
+	default:
+		return commands.Errorf(commands.InvalidInput, "invalid command. Use --help for usage information")
+	}
+}
+
+func executeListsCommand(cmd *ListsCmd, todoList *todo.Todos) error {
+	switch {
+	case cmd.New != nil:
+		return commands.CreateListCommand(cmd.New.Name, todoList)
+	case cmd.Rename != nil:
+		return commands.RenameListCommand(cmd.Rename.Name, cmd.Rename.NewName, todoList)
+	case cmd.Archive != nil:
+		return commands.ArchiveListCommand(cmd.Archive.Name, true, todoList)
+	case cmd.Unarchive != nil:
+		return commands.ArchiveListCommand(cmd.Unarchive.Name, false, todoList)
+	case cmd.Use != nil:
+		return commands.UseListCommand(cmd.Use.Name, todoList)
+	default:
+		return commands.ListsCommand(cmd.Archived, todoList)
 	}
-	return nil
 }
 
-func handleAddCommand(args Args, todoList *todo.Todos) error {
-	task := strings.Join(args.Add, " ")
-	dueDate, err := parseDueDate(args.DueDate)
+func handleAddCommand(cmd *AddCmd, todoList *todo.Todos) error {
+	task := strings.Join(cmd.Task, " ")
+	dueDate, err := parseDueDate(cmd.Due)
 	if err != nil {
 		return err
 	}
-	priority, err := parsePriority(args.Priority)
+	priority, err := parsePriority(cmd.Priority)
 	if err != nil {
 		return err
 	}
-	tags := parseTags(args.Tags)
-	commands.AddCommand([]string{task}, dueDate, priority, todoList, tags)
+	tags := parseTags(cmd.Tags)
+	dependsOn, err := parseIDs(cmd.DependsOn)
+	if err != nil {
+		return err
+	}
+	// Check the dependencies and rule up front so that --dry-run reports
+	// them too. A later step failing still saves nothing, since Update only
+	// saves once the whole command has succeeded.
+	for _, dep := range dependsOn {
+		if todoList.IndexOf(dep) < 0 {
+			return commands.Errorf(commands.NotFound, "task %d not found", dep)
+		}
+	}
+	if cmd.Recur != "" {
+		if _, err := todo.ParseRecurrence(cmd.Recur); err != nil {
+			return commands.Errorf(commands.InvalidInput, "%w", err)
+		}
+	}
+
+	if cmd.DryRun {
+		fmt.Printf("Would add %q, due %s.\n", task, describeDueDate(dueDate))
+		return nil
+	}
+
+	if cmd.Parent != 0 {
+		err = commands.AddSubtaskCommand(cmd.Parent, []string{task}, dueDate, priority, todoList, tags)
+	} else {
+		err = commands.AddCommand([]string{task}, dueDate, priority, todoList, tags)
+	}
+	if err != nil {
+		return err
+	}
+	id := (*todoList)[len(*todoList)-1].ID
+	(*todoList)[len(*todoList)-1].Notes = cmd.Notes
+	for _, dep := range dependsOn {
+		if err := commands.DependCommand(id, dep, todoList); err != nil {
+			return err
+		}
+	}
+	if cmd.Recur != "" {
+		return commands.RecurCommand(id, cmd.Recur, todoList)
+	}
 	return nil
 }
 
+// moveToRole runs a status shortcut such as start, which moves tasks to the
+// state the workflow gives that role, named by key in the config file.
+func moveToRole(command, key, state string, tasks []string, todoList *todo.Todos) error {
+	if state == "" {
+		return commands.Errorf(commands.InvalidInput, "%s: the workflow has no %s state (set workflow.%s in the config file, or use status)", command, key, key)
+	}
+	return commands.StatusCommand(strings.Join(tasks, " "), state, todoList)
+}
+
+func handleEditCommand(cmd *EditCmd, todoList *todo.Todos) error {
+	if cmd.Editor {
+		return commands.EditorCommand(parseTaskID(cmd.Tasks), todoList)
+	}
+	if !cmd.hasFieldFlags() {
+		return commands.EditCommand(parseTaskID(cmd.Tasks), todoList)
+	}
+	edit := commands.TaskEdit{ClearDue: cmd.ClearDue, ClearTags: cmd.ClearTags}
+	if cmd.Task != "" {
+		edit.Task = &cmd.Task
+	}
+	var err error
+	if edit.Due, err = parseDueDate(cmd.Due); err != nil {
+		return err
+	}
+	if cmd.Priority != "" {
+		priority, err := parsePriority(cmd.Priority)
+		if err != nil {
+			return err
+		}
+		edit.Priority = &priority
+	}
+	edit.Tags = parseTags(cmd.Tags)
+	if cmd.Notes != "" || cmd.ClearNotes {
+		edit.Notes = &cmd.Notes
+	}
+	return commands.EditFieldsCommand(cmd.Tasks, edit, todoList)
+}
+
 func parseDueDate(dateStr string) (*time.Time, error) {
 	if dateStr == "" {
 		return nil, nil
 	}
-	parsedDate, err := time.Parse("2006-01-02", dateStr)
+	parsedDate, err := todo.ParseDueDate(dateStr, todo.Now())
 	if err != nil {
-		return nil, fmt.Errorf("invalid date format: %s. Use YYYY-MM-DD", dateStr)
+		return nil, commands.Errorf(commands.InvalidInput, "%w", err)
 	}
 	return &parsedDate, nil
 }
 
+// describeDueDate spells out a resolved due date, weekday included, so that
+// --dry-run shows what a phrase like "next fri" turned into.
+func describeDueDate(due *time.Time) string {
+	if due == nil {
+		return "never"
+	}
+	return due.Format("Monday") + " " + todo.FormatDueDate(due)
+}
+
 func parsePriority(priorityStr string) (todo.Priority, error) {
 	if priorityStr == "" {
 		return todo.Low, nil
 	}
 	priority, err := todo.ParsePriority(priorityStr)
 	if err != nil {
-		return todo.Low, fmt.Errorf("invalid priority: %s. Use low, medium, or high", priorityStr)
+		return todo.Low, commands.Errorf(commands.InvalidInput, "invalid priority: %s. Use low, medium, or high", priorityStr)
 	}
 	return priority, nil
 }
 
+func parseIDs(idString string) ([]int, error) {
+	if idString == "" {
+		return nil, nil
+	}
+	var ids []int
+	for _, field := range strings.Split(idString, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || id <= 0 {
+			return nil, commands.Errorf(commands.InvalidInput, "invalid task ID: %s", strings.TrimSpace(field))
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
 func parseTags(tagString string) []string {
 	if tagString == "" {
 		return nil
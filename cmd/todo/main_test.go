@@ -0,0 +1,49 @@
+package main
+
+import (
+	"go-todo-cli/internal/commands"
+	"go-todo-cli/internal/config"
+	"go-todo-cli/internal/todo"
+	"testing"
+)
+
+func TestDescribeCommand(t *testing.T) {
+	testCases := []struct {
+		argv     []string
+		expected string
+	}{
+		{[]string{"add", "Buy milk"}, "add Buy milk"},
+		{[]string{"--file", "x.json", "add", "Buy milk"}, "add Buy milk"},
+		{[]string{"-f=x.json", "done", "1"}, "done 1"},
+		{[]string{"--yes", "rm", "1-3"}, "rm 1-3"},
+		{[]string{"-L", "work", "-y", "clear"}, "clear"},
+		{[]string{"rm", "-o", "json", "2"}, "rm 2"},
+		{[]string{"add", "--", "-f", "is a task"}, "add -- -f is a task"},
+		{[]string{"-f", "x.json", "--add", "legacy"}, "--add legacy"},
+	}
+
+	for _, tc := range testCases {
+		if got := describeCommand(tc.argv); got != tc.expected {
+			t.Errorf("describeCommand(%q): expected %q, got %q", tc.argv, tc.expected, got)
+		}
+	}
+}
+
+func TestWorkflowRoles(t *testing.T) {
+	w, err := workflow(&config.WorkflowConfig{States: []string{"todo", "in-progress", "done", "cancelled"}})
+	if err != nil || w.Started != "in-progress" || w.Cancelled != "cancelled" {
+		t.Errorf("Expected the default roles for states of the same names, got %+v, %v", w, err)
+	}
+
+	w, err = workflow(&config.WorkflowConfig{States: []string{"open", "review", "done", "dropped"}, Started: "review"})
+	if err != nil || w.Started != "review" || w.Cancelled != "" {
+		t.Errorf("Expected the configured start state and no cancel state, got %+v, %v", w, err)
+	}
+	if err := moveToRole("cancel", "cancelled", w.Cancelled, []string{"1"}, &todo.Todos{{ID: 1, Task: "Task"}}); commands.KindOf(err) != commands.InvalidInput {
+		t.Errorf("Expected cancel to be invalid input without a cancelled state, got %v", err)
+	}
+
+	if _, err := workflow(&config.WorkflowConfig{States: []string{"open", "done"}, Started: "doing"}); commands.KindOf(err) != commands.InvalidInput {
+		t.Errorf("Expected an unknown started state to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+package commands
+
+import (
+	"fmt"
+	"go-todo-cli/internal/todo"
+)
+
+// AgendaCommand prints the open tasks of the current list grouped by when they
+// are due, or with summary just the counts.
+func AgendaCommand(summary bool, todoList *todo.Todos) error {
+	sections := listed(*todoList, todoList).Agenda(todo.Now())
+	if summary {
+		fmt.Println(todo.AgendaSummary(sections))
+		return nil
+	}
+	todo.PrintAgenda(sections, *todoList)
+	return nil
+}
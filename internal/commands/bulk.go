@@ -0,0 +1,172 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-todo-cli/internal/filter"
+	"go-todo-cli/internal/todo"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ConfirmAbove is how many tasks a command may change without asking first.
+// A negative value never asks, as with --yes.
+var ConfirmAbove = 5
+
+// Confirm asks a yes/no question on the terminal. Tests replace it.
+var Confirm = func(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		fmt.Println()
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// selectTasks resolves the tasks a command applies to, given as IDs and
+// ranges such as "1,3,5-9", or as a filter query with at least one
+// field:value term, such as "tag:sprint-12 and status:open". Only tasks of
+// the current list that Filter matches are selected. IDs are returned in
+// list order.
+func selectTasks(spec string, todoList *todo.Todos) ([]int, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, Errorf(InvalidInput, "no tasks given")
+	}
+	selected := map[int]bool{}
+	if isIDList(spec) {
+		if err := selectIDs(spec, todoList, selected); err != nil {
+			return nil, err
+		}
+	} else {
+		// A bare word is more likely a mistyped ID than a query for every
+		// task mentioning it.
+		if !strings.Contains(spec, ":") {
+			return nil, Errorf(InvalidInput, "invalid task ID: %s", spec)
+		}
+		query, err := filter.Parse(spec, todo.Now())
+		if err != nil {
+			return nil, Errorf(InvalidInput, "%w", err)
+		}
+		for _, task := range query.Apply(todoList.InList(ListName), *todoList) {
+			selected[task.ID] = true
+		}
+	}
+
+	var ids []int
+	for _, task := range *todoList {
+		if selected[task.ID] && Filter.Match(task, *todoList) {
+			ids = append(ids, task.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, Errorf(NotFound, "no tasks match '%s'", spec)
+	}
+	return ids, nil
+}
+
+func isIDList(spec string) bool {
+	return strings.Trim(spec, "0123456789,- ") == "" && strings.ContainsAny(spec, "0123456789")
+}
+
+// selectIDs adds the tasks named by a list of IDs and ranges. A single ID
+// must be a task of the current list; a range takes whichever tasks of the
+// list fall in it.
+func selectIDs(spec string, todoList *todo.Todos, selected map[int]bool) error {
+	parts := strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == ' ' })
+	for _, part := range parts {
+		from, to, isRange := strings.Cut(part, "-")
+		if !isRange {
+			index, err := taskIndex(part, todoList)
+			if err != nil {
+				return err
+			}
+			selected[(*todoList)[index].ID] = true
+			continue
+		}
+		lo, hi := parseID(from), parseID(to)
+		if lo < 0 || hi < 0 || lo > hi {
+			return Errorf(InvalidInput, "invalid range: %s", part)
+		}
+		for _, task := range *todoList {
+			if task.ID >= lo && task.ID <= hi && task.InList(ListName) {
+				selected[task.ID] = true
+			}
+		}
+	}
+	return nil
+}
+
+// confirmChange shows the tasks about to change and asks before going on
+// when there are more than ConfirmAbove of them. It asks by returning a
+// Question, so that the list is not locked while the user thinks about it.
+func confirmChange(action string, ids []int, todoList *todo.Todos) error {
+	if ConfirmAbove < 0 || len(ids) <= ConfirmAbove {
+		return nil
+	}
+	tasks := todo.Todos{}
+	for _, id := range ids {
+		tasks = append(tasks, (*todoList)[todoList.IndexOf(id)])
+	}
+	if shown := confirmed; shown != nil {
+		confirmed = nil
+		if !sameTasks(shown, tasks) {
+			return Errorf(Conflict, "the tasks changed while you were answering; no tasks were changed")
+		}
+		return nil
+	}
+	tasks, all := tasks.Clone(), todoList.Clone()
+	return &Question{func() error {
+		if err := printTasks(tasks, &all); err != nil {
+			return err
+		}
+		if !Confirm(fmt.Sprintf("%s %d tasks?", action, len(ids))) {
+			return Errorf(Failure, "aborted; no tasks were changed (use --yes to skip this question)")
+		}
+		confirmed = tasks
+		return nil
+	}}
+}
+
+// confirmed holds the tasks the user last agreed to change, as they were
+// shown, until confirmChange is asked about them again.
+var confirmed todo.Todos
+
+// sameTasks reports whether a and b hold the same tasks with the same
+// fields.
+func sameTasks(a, b todo.Todos) bool {
+	encodedA, errA := json.Marshal(a)
+	encodedB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
+
+// reversed returns ids last first. Subtasks always come after their parent
+// in the list, so this handles them before it, which the "block" subtask
+// policy needs when both are selected.
+func reversed(ids []int) []int {
+	r := make([]int, len(ids))
+	for i, id := range ids {
+		r[len(ids)-1-i] = id
+	}
+	return r
+}
+
+// pluralTasks is "task 3" for one ID and "5 tasks" for more.
+func pluralTasks(ids []int) string {
+	if len(ids) == 1 {
+		return "task " + strconv.Itoa(ids[0])
+	}
+	return strconv.Itoa(len(ids)) + " tasks"
+}
+
+// alreadyPrefix starts a message about tasks that need no change.
+func alreadyPrefix(ids []int) string {
+	if len(ids) == 1 {
+		return fmt.Sprintf("Task %d is", ids[0])
+	}
+	return fmt.Sprintf("All %d tasks are", len(ids))
+}
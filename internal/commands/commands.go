@@ -2,7 +2,9 @@ package commands
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"go-todo-cli/internal/search"
 	"go-todo-cli/internal/todo"
 	"os"
 	"strconv"
@@ -10,97 +12,158 @@ import (
 	"time"
 )
 
-var FileToWrite = "todos.json"
+// Store holds the list and its metadata. Commands only change the list they
+// are given; the Store.Update they run in saves it once they succeed.
+var Store todo.Store = todo.NewJSONStore("todos.json")
 
-func AddCommand(args []string, dueDate *time.Time, priority todo.Priority, todoList *todo.Todos, tags []string) {
-	if len(args) < 1 {
-		fmt.Println("Usage: add <task> [--tag tag1,tag2,...]")
-		return
+func AddCommand(args []string, dueDate *time.Time, priority todo.Priority, todoList *todo.Todos, tags []string) error {
+	if len(args) < 1 || strings.TrimSpace(strings.Join(args, " ")) == "" {
+		return Errorf(InvalidInput, "usage: add <task> [--tags tag1,tag2,...]")
+	}
+	if err := checkListWritable(); err != nil {
+		return err
+	}
+	if err := todoList.Add(Store.Meta(), strings.Join(args, " "), dueDate, priority, tags); err != nil {
+		return Errorf(Failure, "%w", err)
+	}
+	if ListName != todo.DefaultList {
+		(*todoList)[len(*todoList)-1].List = ListName
 	}
-	todoList.Add(strings.Join(args, " "), dueDate, priority, tags)
 	fmt.Println("Task added.")
-	saveTodoList(todoList)
+	return nil
 }
 
-func CompleteCommand(args []string, todoList *todo.Todos) {
-	if len(args) != 1 {
-		fmt.Println("Usage: complete <task_number>")
-		return
+// CompleteCommand completes the selected tasks; see selectTasks. It fails
+// without changing anything if any of them may not be completed.
+func CompleteCommand(args []string, todoList *todo.Todos) error {
+	if len(args) == 0 {
+		return Errorf(InvalidInput, "usage: complete <task_ids|query>")
 	}
-	index := parseIndex(args[0])
-	if index >= 0 && index < len(*todoList) {
-		if err := todoList.Complete(index); err == nil {
-			fmt.Println("Task marked as complete.")
-		} else {
-			fmt.Println(err)
+	ids, err := selectTasks(strings.Join(args, " "), todoList)
+	if err != nil {
+		return err
+	}
+	workflow := todo.ActiveWorkflow
+	for _, id := range ids {
+		if from := workflow.StateOf((*todoList)[todoList.IndexOf(id)]); from != workflow.Done && !workflow.CanMove(from, workflow.Done) {
+			return Errorf(InvalidInput, "%w: task %d: %s -> %s", todo.ErrTransition, id, from, workflow.Done)
 		}
+	}
+	if err := confirmChange("Complete", ids, todoList); err != nil {
+		return err
+	}
+	count := len(*todoList)
+	for _, id := range reversed(ids) {
+		if err := todoList.CompleteTree(Store.Meta(), todoList.IndexOf(id), SubtaskRules.OnComplete); err != nil {
+			return subtaskError(err)
+		}
+	}
+	if len(ids) == 1 {
+		fmt.Println("Task marked as complete.")
 	} else {
-		fmt.Println("Invalid task number.")
+		fmt.Printf("%d tasks marked as complete.\n", len(ids))
+	}
+	for _, next := range (*todoList)[count:] {
+		fmt.Printf("Next occurrence: task %d, due %s.\n", next.ID, todo.FormatDueDate(next.DueDate))
 	}
-	saveTodoList(todoList)
+	return nil
 }
 
-func DeleteCommand(args []string, todoList *todo.Todos) {
-	if len(args) != 1 {
-		fmt.Println("Usage: delete <task_number>")
-		return
+// DeleteCommand deletes the selected tasks; see selectTasks.
+func DeleteCommand(args []string, todoList *todo.Todos) error {
+	if len(args) == 0 {
+		return Errorf(InvalidInput, "usage: delete <task_ids|query>")
 	}
-	index := parseIndex(args[0])
-	if index >= 0 && index < len(*todoList) {
-		if err := todoList.Delete(index); err == nil {
-			fmt.Println("Task deleted.")
-		} else {
-			fmt.Println(err)
-		}
+	ids, err := selectTasks(strings.Join(args, " "), todoList)
+	if err != nil {
+		return err
+	}
+	if err := confirmChange("Delete", ids, todoList); err != nil {
+		return err
+	}
+	if err := deleteTasks(ids, todoList); err != nil {
+		return err
+	}
+	if len(ids) == 1 {
+		fmt.Println("Task deleted.")
 	} else {
-		fmt.Println("Invalid task number.")
+		fmt.Printf("%d tasks deleted.\n", len(ids))
 	}
-	saveTodoList(todoList)
+	return nil
 }
 
-func ListCommand(todoList *todo.Todos) {
-	todo.Print(todoList)
+func ListCommand(todoList *todo.Todos) error {
+	return printTasks(listed(*todoList, todoList), todoList)
 }
 
-func ClearTasksCommand(todoList *todo.Todos) {
-	*todoList = todo.Todos{}
-	fmt.Println("All tasks cleared.")
-	saveTodoList(todoList)
+// deleteTasks deletes the tasks with the given IDs, applying the configured
+// subtask policy to each the way delete does.
+func deleteTasks(ids []int, todoList *todo.Todos) error {
+	for _, id := range reversed(ids) {
+		// Deleting a parent may already have deleted its subtasks.
+		if index := todoList.IndexOf(id); index >= 0 {
+			if err := todoList.DeleteTree(index, SubtaskRules.OnDelete); err != nil {
+				return subtaskError(err)
+			}
+		}
+	}
+	return nil
 }
 
-func EditCommand(taskNumber int, todoList *todo.Todos) {
-	index := taskNumber - 1
-	if index < 0 || index >= len(*todoList) {
-		fmt.Println("Invalid task number.")
-		return
+// ClearTasksCommand deletes every task in the current list, or only those
+// Filter matches. Subtasks are handled as they are by delete.
+func ClearTasksCommand(todoList *todo.Todos) error {
+	var ids []int
+	for _, task := range listed(*todoList, todoList) {
+		ids = append(ids, task.ID)
+	}
+	if err := confirmChange("Delete", ids, todoList); err != nil {
+		return err
+	}
+	before := len(*todoList)
+	if err := deleteTasks(ids, todoList); err != nil {
+		return err
+	}
+	deleted := before - len(*todoList)
+	if Filter == nil {
+		fmt.Println("All tasks cleared.")
+	} else {
+		fmt.Printf("Cleared %d task(s).\n", deleted)
 	}
+	return nil
+}
+
+func EditCommand(taskID int, todoList *todo.Todos) error {
+	return editTask(taskID, todoList, editByPrompts)
+}
 
-	task := &(*todoList)[index]
+// editByPrompts asks for each field of task in turn on the terminal.
+func editByPrompts(task todo.Todo) (*TaskEdit, error) {
+	var edit TaskEdit
 	reader := bufio.NewReader(os.Stdin)
 
 	fmt.Printf("Current task: %s\nEnter new task description (or press Enter to keep current): ", task.Task)
 	newTask, _ := reader.ReadString('\n')
 	newTask = strings.TrimSpace(newTask)
 	if newTask != "" {
-		task.Task = newTask
+		edit.Task = &newTask
 	}
 
-	var newDueDate time.Time
-	var err error
 	for {
-		fmt.Printf("Current due date: %v\nEnter new due date (YYYY-MM-DD) or press Enter to keep current: ", task.DueDate)
+		fmt.Printf("Current due date: %s\nEnter new due date (YYYY-MM-DD, tomorrow, fri, in 3 days, ...) or press Enter to keep current: ", todo.FormatDueDate(task.DueDate))
 		input, _ := reader.ReadString('\n')
 		input = strings.TrimSpace(input)
 
 		if input == "" {
 			break
 		}
-		newDueDate, err = time.Parse("2006-01-02", input)
+		newDueDate, err := todo.ParseDueDate(input, todo.Now())
 		if err == nil {
-			task.DueDate = &newDueDate
+			edit.Due = &newDueDate
+			fmt.Printf("Due date set to %s.\n", todo.FormatDueDate(&newDueDate))
 			break
 		}
-		fmt.Println("Invalid date format. Please use YYYY-MM-DD.")
+		fmt.Println(err)
 	}
 
 	for {
@@ -112,7 +175,7 @@ func EditCommand(taskNumber int, todoList *todo.Todos) {
 		}
 		newPriority, err := todo.ParsePriority(strings.ToLower(input))
 		if err == nil {
-			task.Priority = newPriority
+			edit.Priority = &newPriority
 			break
 		}
 		fmt.Println("Invalid priority. Please use low, medium, or high.")
@@ -122,112 +185,293 @@ func EditCommand(taskNumber int, todoList *todo.Todos) {
 	input, _ := reader.ReadString('\n')
 	input = strings.TrimSpace(input)
 	if input != "" {
-		task.Tags = strings.Split(input, ",")
+		edit.Tags = strings.Split(input, ",")
 	}
-
-	fmt.Println("Task updated successfully.")
-	saveTodoList(todoList)
+	return &edit, nil
 }
 
-func AddTagCommand(args []string, todoList *todo.Todos) {
+// AddTagCommand tags the selected tasks; see selectTasks.
+func AddTagCommand(args []string, todoList *todo.Todos) error {
 	if len(args) != 2 {
-		fmt.Println("Usage: add-tag <task_number> <tag>")
-		return
-	}
-	index := parseIndex(args[0])
-	if index >= 0 && index < len(*todoList) {
-		task := &(*todoList)[index]
-		newTag := strings.TrimSpace(args[1])
-		if !contains(task.Tags, newTag) {
-			task.Tags = append(task.Tags, newTag)
-			fmt.Printf("Tag '%s' added to task %d.\n", newTag, index+1)
-			saveTodoList(todoList)
+		return Errorf(InvalidInput, "usage: add-tag <task_ids|query> <tag>")
+	}
+	newTag := strings.TrimSpace(args[1])
+	if newTag == "" {
+		return Errorf(InvalidInput, "tag cannot be empty")
+	}
+	ids, err := selectTasks(args[0], todoList)
+	if err != nil {
+		return err
+	}
+	var changed []int
+	for _, id := range ids {
+		if !contains((*todoList)[todoList.IndexOf(id)].Tags, newTag) {
+			changed = append(changed, id)
+		}
+	}
+	if len(changed) == 0 {
+		if len(ids) == 1 {
+			fmt.Printf("Tag '%s' already exists for task %d.\n", newTag, ids[0])
 		} else {
-			fmt.Printf("Tag '%s' already exists for task %d.\n", newTag, index+1)
+			fmt.Printf("All %d tasks already have tag '%s'.\n", len(ids), newTag)
 		}
-	} else {
-		fmt.Println("Invalid task number.")
+		return nil
+	}
+	if err := confirmChange("Tag", changed, todoList); err != nil {
+		return err
+	}
+	for _, id := range changed {
+		task := &(*todoList)[todoList.IndexOf(id)]
+		oldTags := todo.FormatTags(task.Tags)
+		task.Tags = append(task.Tags, newTag)
+		task.RecordChange("Tags", oldTags, todo.FormatTags(task.Tags))
 	}
+	fmt.Printf("Tag '%s' added to %s.\n", newTag, pluralTasks(changed))
+	return nil
 }
 
-func RemoveTagCommand(args []string, todoList *todo.Todos) {
+// RemoveTagCommand untags the selected tasks; see selectTasks. Tasks without
+// the tag are left alone, but at least one must have it.
+func RemoveTagCommand(args []string, todoList *todo.Todos) error {
 	if len(args) != 2 {
-		fmt.Println("Usage: remove-tag <task_number> <tag>")
-		return
-	}
-	index := parseIndex(args[0])
-	if index >= 0 && index < len(*todoList) {
-		task := &(*todoList)[index]
-		tagToRemove := strings.TrimSpace(args[1])
-		if removeString(&task.Tags, tagToRemove) {
-			fmt.Printf("Tag '%s' removed from task %d.\n", tagToRemove, index+1)
-			saveTodoList(todoList)
-		} else {
-			fmt.Printf("Tag '%s' not found for task %d.\n", tagToRemove, index+1)
+		return Errorf(InvalidInput, "usage: remove-tag <task_ids|query> <tag>")
+	}
+	tagToRemove := strings.TrimSpace(args[1])
+	ids, err := selectTasks(args[0], todoList)
+	if err != nil {
+		return err
+	}
+	var changed []int
+	for _, id := range ids {
+		if contains((*todoList)[todoList.IndexOf(id)].Tags, tagToRemove) {
+			changed = append(changed, id)
 		}
-	} else {
-		fmt.Println("Invalid task number.")
 	}
+	if len(changed) == 0 {
+		if len(ids) == 1 {
+			return Errorf(NotFound, "tag '%s' not found for task %d", tagToRemove, ids[0])
+		}
+		return Errorf(NotFound, "tag '%s' not found for any of the %d tasks", tagToRemove, len(ids))
+	}
+	if err := confirmChange("Untag", changed, todoList); err != nil {
+		return err
+	}
+	for _, id := range changed {
+		task := &(*todoList)[todoList.IndexOf(id)]
+		oldTags := todo.FormatTags(task.Tags)
+		removeString(&task.Tags, tagToRemove)
+		task.RecordChange("Tags", oldTags, todo.FormatTags(task.Tags))
+	}
+	fmt.Printf("Tag '%s' removed from %s.\n", tagToRemove, pluralTasks(changed))
+	return nil
 }
 
-func FilterByTagCommand(args []string, todoList *todo.Todos) {
+func FilterByTagCommand(args []string, todoList *todo.Todos) error {
 	if len(args) != 1 {
-		fmt.Println("Usage: filter-tag <tag>")
-		return
+		return Errorf(InvalidInput, "usage: filter-tag <tag>")
 	}
 	tag := strings.TrimSpace(args[0])
 	filteredList := todo.Todos{}
-	for _, task := range *todoList {
+	for _, task := range listed(*todoList, todoList) {
 		if contains(task.Tags, tag) {
 			filteredList = append(filteredList, task)
 		}
 	}
-	if len(filteredList) > 0 {
-		todo.Print(&filteredList)
-	} else {
+	if len(filteredList) == 0 && Output == "table" {
 		fmt.Printf("No tasks found with tag '%s'.\n", tag)
+		return nil
 	}
+	return printTasks(filteredList, todoList)
 }
 
-func SearchCommand(args []string, todoList *todo.Todos) {
+// SearchCommand runs a full-text search over the tasks of the current list;
+// see the search package for the query syntax. Results are listed most
+// relevant first, with the matches highlighted.
+func SearchCommand(args []string, todoList *todo.Todos) error {
 	if len(args) == 0 {
-		fmt.Printf("Usage: search <keyword>")
-		return
+		return Errorf(InvalidInput, "usage: search <keyword>")
 	}
-
-	keyword := strings.ToLower(strings.Join(args, " "))
-	results := todo.Todos{}
-
-	for _, task := range *todoList {
-		// check search for task name
-		if strings.Contains(strings.ToLower(task.Task), keyword) {
-			results = append(results, task)
-			continue
+	query := strings.Join(args, " ")
+	q, err := search.ParseQuery(query)
+	if err != nil {
+		return Errorf(InvalidInput, "%w", err)
+	}
+	results := search.NewIndex(listed(*todoList, todoList)).Search(q)
+	if Output != "table" {
+		// Other formats keep the order of relevance and add the score.
+		tasks := todo.Todos{}
+		scores := map[int]float64{}
+		for _, r := range results {
+			tasks = append(tasks, r.Task)
+			scores[r.Task.ID] = r.Score
 		}
+		return writeTasks(tasks, todoList, scores)
+	}
+	if len(results) == 0 {
+		fmt.Printf("No tasks found matching '%s'\n", query)
+		return nil
+	}
 
-		// filter each task by searching through tags
-		for _, tag := range task.Tags {
-			if strings.Contains(strings.ToLower(tag), keyword) {
-				results = append(results, task)
-				break
+	fmt.Printf("Found %d matching task(s):\n", len(results))
+	for _, r := range results {
+		task := r.Task.Task
+		var details []string
+		for _, m := range r.Matches {
+			if m.Field == "task" {
+				task = search.Highlight(m.Text, m.Spans, todo.Emphasize)
+			} else {
+				details = append(details, fmt.Sprintf("%s: %s", m.Field, search.Fragment(m, 60, todo.Emphasize)))
 			}
 		}
+		fmt.Printf("%4d  %s\n", r.Task.ID, task)
+		for _, d := range details {
+			fmt.Printf("      %s\n", d)
+		}
 	}
+	return nil
+}
 
-	if len(results) > 0 {
-		// print matching tasks and result count
-		fmt.Printf("Found %d matching task(s):\n", len(results))
-		todo.Print(&results)
-		return
+func ShowCommand(args []string, showHistory bool, todoList *todo.Todos) error {
+	if len(args) != 1 {
+		return Errorf(InvalidInput, "usage: show <task_id> [--history]")
+	}
+	index, err := taskIndex(args[0], todoList)
+	if err != nil {
+		return err
 	}
+	task := (*todoList)[index]
 
-	fmt.Printf("No tasks found matching '%s'\n", keyword)
+	fmt.Printf("Task %d: %s\n", task.ID, task.Task)
+	fmt.Printf("  Status:    %s\n", todoList.StatusOf(task))
+	fmt.Printf("  List:      %s\n", listOf(task))
+	fmt.Printf("  Priority:  %s\n", task.Priority)
+	fmt.Printf("  Urgency:   %s\n", todo.FormatUrgency(todoList.Urgency(task, todo.Now())))
+	fmt.Printf("  Due Date:  %s\n", task.DueLabel(todo.Now()))
+	if zone := task.ZoneLabel(); zone != "" {
+		fmt.Printf("             %s\n", zone)
+	}
+	fmt.Printf("  Tags:      %s\n", todo.FormatTags(task.Tags))
+	if task.ParentID != 0 {
+		fmt.Printf("  Parent:    %d\n", task.ParentID)
+	}
+	if done, total := todoList.Progress(task.ID); total > 0 {
+		fmt.Printf("  Subtasks:  %d/%d done\n", done, total)
+	}
+	if task.Recur != "" {
+		fmt.Printf("  Repeats:   %s\n", task.Recur)
+	}
+	if series := todoList.Series(task.SeriesID); len(series) > 1 {
+		ids := make([]int, len(series))
+		for i, occurrence := range series {
+			ids[i] = occurrence.ID
+		}
+		fmt.Printf("  Series:    %s\n", todo.FormatIDs(ids))
+	}
+	if len(task.DependsOn) > 0 {
+		fmt.Printf("  Depends:   %s (open: %s)\n", todo.FormatIDs(task.DependsOn), todo.FormatIDs(todoList.OpenDependencies(task)))
+	}
+	fmt.Printf("  Created:   %s\n", formatTimestamp(task.CreatedAt))
+	fmt.Printf("  Updated:   %s\n", formatTimestamp(task.UpdatedAt))
+	fmt.Printf("  Completed: %s\n", formatTimestamp(task.CompletedAt))
+	if task.Notes != "" {
+		fmt.Println("Notes:")
+		for _, line := range strings.Split(task.Notes, "\n") {
+			fmt.Println(strings.TrimRight("  "+line, " "))
+		}
+	}
+	if len(task.Annotations) > 0 {
+		fmt.Println("Annotations:")
+		for _, a := range task.Annotations {
+			fmt.Printf("  %s  %s\n", a.Time.Local().Format("2006-01-02 15:04"), a.Text)
+		}
+	}
+
+	if !showHistory {
+		return nil
+	}
+	fmt.Println("History:")
+	if len(task.History) == 0 {
+		fmt.Println("  No changes recorded.")
+		return nil
+	}
+	for _, change := range task.History {
+		fmt.Printf("  %s  %s: %s -> %s\n", change.Time.Format("2006-01-02 15:04"), change.Field, oneLine(change.Old), oneLine(change.New))
+	}
+	return nil
 }
 
-func VisualizeCommand(todoList *todo.Todos) {
-	fmt.Println(todo.VisualizeTasksByPriority(todoList))
+func VisualizeCommand(todoList *todo.Todos) error {
+	tasks := listed(*todoList, todoList)
+	if Output != "table" {
+		if err := todo.WriteStats(os.Stdout, Output, tasks.Stats()); err != nil {
+			return Errorf(Failure, "%w", err)
+		}
+		return nil
+	}
+	if len(tasks) == 0 {
+		fmt.Println("No tasks available.")
+		return nil
+	}
+	fmt.Println(todo.VisualizeTasksByPriority(&tasks))
 	fmt.Println()
-	fmt.Println(todo.VisualizeOverallProgress(todoList))
+	fmt.Println(todo.VisualizeOverallProgress(&tasks))
+	return nil
+}
+
+// UndoCommand undoes up to steps operations, stopping early once there is
+// nothing left to undo. A conflicting operation fails the whole command.
+func UndoCommand(steps int, journal *todo.Journal, todoList *todo.Todos) error {
+	if journal.Cursor == 0 {
+		return Errorf(InvalidInput, "nothing to undo")
+	}
+	for i := 0; i < steps && journal.Cursor > 0; i++ {
+		op, err := journal.Undo(todoList, &Store.Meta().ListSet)
+		if err != nil {
+			return journalError(err)
+		}
+		fmt.Printf("Undid #%d: %s\n", op.Seq, op.Command)
+	}
+	return nil
+}
+
+// RedoCommand is the counterpart of UndoCommand.
+func RedoCommand(steps int, journal *todo.Journal, todoList *todo.Todos) error {
+	if journal.Cursor == len(journal.Ops) {
+		return Errorf(InvalidInput, "nothing to redo")
+	}
+	for i := 0; i < steps && journal.Cursor < len(journal.Ops); i++ {
+		op, err := journal.Redo(todoList, &Store.Meta().ListSet)
+		if err != nil {
+			return journalError(err)
+		}
+		fmt.Printf("Redid #%d: %s\n", op.Seq, op.Command)
+	}
+	return nil
+}
+
+func journalError(err error) error {
+	if errors.Is(err, todo.ErrConflict) {
+		return Errorf(Conflict, "%w", err)
+	}
+	return Errorf(Failure, "%w", err)
+}
+
+func HistoryCommand(journal *todo.Journal) error {
+	if len(journal.Ops) == 0 {
+		fmt.Println("No changes recorded.")
+		return nil
+	}
+	// Newest first; undone operations are still listed until they are
+	// discarded by the next change.
+	for i := len(journal.Ops) - 1; i >= 0; i-- {
+		op := journal.Ops[i]
+		state := ""
+		if i >= journal.Cursor {
+			state = " (undone)"
+		}
+		fmt.Printf("#%-4d %s  %-40s %d task(s)%s\n", op.Seq, op.Time.Format("2006-01-02 15:04"), op.Command, len(op.Changes), state)
+	}
+	return nil
 }
 
 func contains(slice []string, item string) bool {
@@ -249,16 +493,39 @@ func removeString(slice *[]string, item string) bool {
 	return false
 }
 
-func saveTodoList(todoList *todo.Todos) {
-	if err := todoList.Save(FileToWrite); err != nil {
-		fmt.Fprintln(os.Stderr, "Error saving go-todo-cli list:", err)
+// oneLine shows a value that may span lines, such as notes, on one line.
+func oneLine(s string) string {
+	return strings.ReplaceAll(s, "\n", " / ")
+}
+
+func formatTimestamp(t *time.Time) string {
+	if t == nil {
+		return "N/A"
 	}
+	return t.Local().Format("2006-01-02 15:04")
 }
 
-func parseIndex(input string) int {
-	index, err := strconv.Atoi(strings.TrimSpace(input))
-	if err != nil || index <= 0 {
+func parseID(input string) int {
+	id, err := strconv.Atoi(strings.TrimSpace(input))
+	if err != nil || id <= 0 {
 		return -1 // Return -1 for invalid or non-positive inputs
 	}
-	return index - 1 // Convert to zero-based index
+	return id
+}
+
+// taskIndex resolves a task ID given on the command line to its position in
+// the list. Tasks outside the current list are not found.
+func taskIndex(input string, todoList *todo.Todos) (int, error) {
+	id := parseID(input)
+	if id < 0 {
+		return -1, Errorf(InvalidInput, "invalid task ID: %s", strings.TrimSpace(input))
+	}
+	index := todoList.IndexOf(id)
+	if index < 0 {
+		return -1, Errorf(NotFound, "task %d not found", id)
+	}
+	if !(*todoList)[index].InList(ListName) {
+		return -1, Errorf(NotFound, "task %d not found in list '%s'", id, ListName)
+	}
+	return index, nil
 }
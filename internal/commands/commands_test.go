@@ -2,23 +2,29 @@ package commands
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"go-todo-cli/internal/filter"
 	"go-todo-cli/internal/todo"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 )
 
+const testFile = "test_todos.json"
+
 func TestMain(m *testing.M) {
 	// Set a test file name
-	FileToWrite = "test_todos.json"
+	Store = todo.NewJSONStore(testFile)
 
 	// Run the tests
 	code := m.Run()
 
 	// Clean up the test file
-	os.Remove(FileToWrite)
+	os.Remove(testFile)
 
 	// Exit with the test result code
 	os.Exit(code)
@@ -47,29 +53,35 @@ func TestAddCommand(t *testing.T) {
 }
 
 func TestCompleteCommand(t *testing.T) {
-	todos := &todo.Todos{{Task: "Test task", Completed: false}}
+	todos := &todo.Todos{{ID: 1, Task: "Test task", Completed: false}}
 	CompleteCommand([]string{"1"}, todos)
 	if !(*todos)[0].Completed {
 		t.Error("CompleteCommand failed to mark task as complete")
 	}
 
 	// Test invalid task number
-	CompleteCommand([]string{"2"}, todos)
+	err := CompleteCommand([]string{"2"}, todos)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected not-found error, got %v", err)
+	}
 	if len(*todos) != 1 {
 		t.Error("CompleteCommand should not add new tasks")
 	}
 }
 
 func TestDeleteCommand(t *testing.T) {
-	todos := &todo.Todos{{Task: "Test task"}}
+	todos := &todo.Todos{{ID: 1, Task: "Test task"}}
 	DeleteCommand([]string{"1"}, todos)
 	if len(*todos) != 0 {
 		t.Errorf("Expected 0 todos after deletion, got %d", len(*todos))
 	}
 
 	// Test invalid task number
-	todos = &todo.Todos{{Task: "Test task"}}
-	DeleteCommand([]string{"2"}, todos)
+	todos = &todo.Todos{{ID: 1, Task: "Test task"}}
+	err := DeleteCommand([]string{"2"}, todos)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected not-found error, got %v", err)
+	}
 	if len(*todos) != 1 {
 		t.Error("DeleteCommand should not remove tasks for invalid numbers")
 	}
@@ -104,42 +116,40 @@ func TestClearTasksCommand(t *testing.T) {
 	}
 }
 
-func TestParseIndex(t *testing.T) {
+func TestParseID(t *testing.T) {
 	testCases := []struct {
 		input    string
 		expected int
 	}{
-		{"1", 0},
-		{"5", 4},
+		{"1", 1},
+		{"5", 5},
 		{"0", -1},
 		{"-1", -1},
 		{"abc", -1},
 	}
 
 	for _, tc := range testCases {
-		result := parseIndex(tc.input)
+		result := parseID(tc.input)
 		if result != tc.expected {
-			t.Errorf("parseIndex(%s): expected %d, got %d", tc.input, tc.expected, result)
+			t.Errorf("parseID(%s): expected %d, got %d", tc.input, tc.expected, result)
 		}
 	}
 }
 
-func TestSaveTodoList(t *testing.T) {
-	todos := &todo.Todos{{Task: "Test task"}}
-	saveTodoList(todos)
+func TestCommandsAddressTasksByID(t *testing.T) {
+	todos := &todo.Todos{}
+	todos.Add(nil, "Task 1", nil, todo.Low, nil)
+	todos.Add(nil, "Task 2", nil, todo.Low, nil)
+	todos.Add(nil, "Task 3", nil, todo.Low, nil)
 
-	// Check if file was created
-	if _, err := os.Stat(FileToWrite); os.IsNotExist(err) {
-		t.Error("saveTodoList did not create a file")
-	}
+	DeleteCommand([]string{"1"}, todos)
+	CompleteCommand([]string{"3"}, todos)
 
-	// Verify file contents
-	data, err := os.ReadFile(FileToWrite)
-	if err != nil {
-		t.Errorf("Error reading saved file: %v", err)
+	if len(*todos) != 2 || (*todos)[0].ID != 2 || (*todos)[1].ID != 3 {
+		t.Fatalf("Expected tasks 2 and 3 to remain, got %+v", *todos)
 	}
-	if !bytes.Contains(data, []byte("Test task")) {
-		t.Error("Saved file does not contain expected content")
+	if (*todos)[0].Completed || !(*todos)[1].Completed {
+		t.Errorf("Expected only task 3 to be completed after deleting task 1, got %+v", *todos)
 	}
 }
 
@@ -147,10 +157,13 @@ func TestEditCommand(t *testing.T) {
 	// Setup
 	oldStdin := os.Stdin
 	oldStdout := os.Stdout
+	oldNow := todo.Now
 	defer func() {
 		os.Stdin = oldStdin
 		os.Stdout = oldStdout
+		todo.Now = oldNow
 	}()
+	todo.Now = func() time.Time { return time.Date(2023, 6, 28, 9, 0, 0, 0, time.UTC) }
 
 	tests := []struct {
 		name             string
@@ -168,6 +181,14 @@ func TestEditCommand(t *testing.T) {
 			expectedPriority: todo.High,
 			expectedTags:     []string{"work", "urgent"},
 		},
+		{
+			name:             "Relative due date",
+			input:            "\nnext sat\ninvalid\n\n",
+			expectedTask:     "Original task",
+			expectedDueDate:  "2023-07-01",
+			expectedPriority: todo.Low,
+			expectedTags:     []string{},
+		},
 		{
 			name:             "Keep original values",
 			input:            "\n\n\n\n",
@@ -190,7 +211,7 @@ func TestEditCommand(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Create a new todos slice for each test case
 			todos := &todo.Todos{
-				{Task: "Original task", DueDate: nil, Priority: todo.Low, Tags: []string{}},
+				{ID: 1, Task: "Original task", DueDate: nil, Priority: todo.Low, Tags: []string{}},
 			}
 
 			// Create pipes for input and output
@@ -206,7 +227,7 @@ func TestEditCommand(t *testing.T) {
 			}()
 
 			// Run the command
-			EditCommand(1, todos)
+			WithAnswers(func() error { return EditCommand(1, todos) })
 
 			// Close the write end of the output pipe
 			outW.Close()
@@ -246,7 +267,7 @@ func TestEditCommand(t *testing.T) {
 }
 
 func TestAddTagCommand(t *testing.T) {
-	todos := &todo.Todos{{Task: "Test task", Tags: []string{"existing"}}}
+	todos := &todo.Todos{{ID: 1, Task: "Test task", Tags: []string{"existing"}}}
 
 	// Test adding a new tag
 	AddTagCommand([]string{"1", "newtag"}, todos)
@@ -268,7 +289,7 @@ func TestAddTagCommand(t *testing.T) {
 }
 
 func TestRemoveTagCommand(t *testing.T) {
-	todos := &todo.Todos{{Task: "Test task", Tags: []string{"tag1", "tag2"}}}
+	todos := &todo.Todos{{ID: 1, Task: "Test task", Tags: []string{"tag1", "tag2"}}}
 
 	// Test removing an existing tag
 	RemoveTagCommand([]string{"1", "tag1"}, todos)
@@ -283,7 +304,9 @@ func TestRemoveTagCommand(t *testing.T) {
 	}
 
 	// Test invalid task number
-	RemoveTagCommand([]string{"999", "tag2"}, todos)
+	if err := RemoveTagCommand([]string{"999", "tag2"}, todos); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected not-found error, got %v", err)
+	}
 	if len((*todos)[0].Tags) != 1 {
 		t.Errorf("Expected no change for invalid task number, got %v", (*todos)[0].Tags)
 	}
@@ -335,9 +358,9 @@ func TestFilterByTagCommand(t *testing.T) {
 func TestSearchCommand(t *testing.T) {
 	todoList := &todo.Todos{}
 	now := time.Now()
-	todoList.Add("Buy groceries", &now, todo.Medium, []string{"shopping"})
-	todoList.Add("Finish project", nil, todo.High, []string{"work"})
-	todoList.Add("Call mom", nil, todo.Low, []string{"personal"})
+	todoList.Add(nil, "Buy groceries", &now, todo.Medium, []string{"shopping"})
+	todoList.Add(nil, "Finish project", nil, todo.High, []string{"work"})
+	todoList.Add(nil, "Call mom", nil, todo.Low, []string{"personal"})
 
 	testCases := []struct {
 		args     []string
@@ -371,6 +394,198 @@ func TestSearchCommand(t *testing.T) {
 	}
 }
 
+func TestSentinelErrorMessages(t *testing.T) {
+	sentinels := map[*Error]string{
+		ErrInvalidInput: "invalid input",
+		ErrNotFound:     "not found",
+		ErrStorage:      "storage failure",
+		ErrConflict:     "conflict",
+	}
+	for sentinel, want := range sentinels {
+		if got := sentinel.Error(); got != want {
+			t.Errorf("Expected %q, got %q", want, got)
+		}
+	}
+	if got := fmt.Errorf("saving: %w", ErrStorage).Error(); got != "saving: storage failure" {
+		t.Errorf("Expected a wrapped sentinel to print its kind, got %q", got)
+	}
+}
+
+func TestCommandErrorKinds(t *testing.T) {
+	todos := &todo.Todos{{ID: 1, Task: "Test task", Tags: []string{"work"}}}
+
+	testCases := []struct {
+		name     string
+		run      func() error
+		expected error
+	}{
+		{"complete unknown ID", func() error { return CompleteCommand([]string{"7"}, todos) }, ErrNotFound},
+		{"complete malformed ID", func() error { return CompleteCommand([]string{"abc"}, todos) }, ErrInvalidInput},
+		{"delete without ID", func() error { return DeleteCommand(nil, todos) }, ErrInvalidInput},
+		{"edit unknown ID", func() error { return EditCommand(7, todos) }, ErrNotFound},
+		{"add empty task", func() error { return AddCommand([]string{" "}, nil, todo.Low, todos, nil) }, ErrInvalidInput},
+		{"add empty tag", func() error { return AddTagCommand([]string{"1", ""}, todos) }, ErrInvalidInput},
+		{"remove missing tag", func() error { return RemoveTagCommand([]string{"1", "home"}, todos) }, ErrNotFound},
+		{"show unknown ID", func() error { return ShowCommand([]string{"7"}, false, todos) }, ErrNotFound},
+		{"undo empty journal", func() error { return UndoCommand(1, &todo.Journal{}, todos) }, ErrInvalidInput},
+		{"search without keyword", func() error { return SearchCommand(nil, todos) }, ErrInvalidInput},
+		{"search invalid regex", func() error { return SearchCommand([]string{"/(/"}, todos) }, ErrInvalidInput},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.run()
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("Expected %v error, got %v", tc.expected.(*Error).Kind, err)
+			}
+		})
+	}
+}
+
+func TestCommandsLeaveSavingToUpdate(t *testing.T) {
+	old := Store
+	filename := filepath.Join(t.TempDir(), "todos.json")
+	Store = todo.NewJSONStore(filename)
+	defer func() { Store = old }()
+
+	todos := &todo.Todos{}
+	captureOutput(func() {
+		AddCommand([]string{"Task"}, nil, todo.Low, todos, nil)
+		CompleteCommand([]string{"1"}, todos)
+	})
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatalf("Expected commands not to write the file themselves, got %v", err)
+	}
+
+	err := Store.Update(func(todoList *todo.Todos) error {
+		captureOutput(func() { AddCommand([]string{"Saved"}, nil, todo.Low, todoList, nil) })
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Store.Update(func(todoList *todo.Todos) error {
+		captureOutput(func() { AddCommand([]string{"Discarded"}, nil, todo.Low, todoList, nil) })
+		return CompleteCommand([]string{"99"}, todoList)
+	})
+	if KindOf(err) != NotFound {
+		t.Fatalf("Expected the failing command's error, got %v", err)
+	}
+	saved, _ := Store.Load()
+	if len(saved) != 1 || saved[0].Task != "Saved" {
+		t.Errorf("Expected only the successful update to be saved, got %+v", saved)
+	}
+}
+
+func TestClearThenAddKeepsCountingIDs(t *testing.T) {
+	old := Store
+	Store = todo.NewJSONStore(filepath.Join(t.TempDir(), "todos.json"))
+	defer func() { Store = old }()
+
+	run := func(command func(todoList *todo.Todos) error) {
+		t.Helper()
+		err := Store.Update(func(todoList *todo.Todos) error {
+			var err error
+			captureOutput(func() { err = command(todoList) })
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	add := func(task string) func(todoList *todo.Todos) error {
+		return func(todoList *todo.Todos) error {
+			return AddCommand([]string{task}, nil, todo.Low, todoList, nil)
+		}
+	}
+
+	run(add("Task 1"))
+	run(add("Task 2"))
+	run(ClearTasksCommand)
+	run(add("Task 3"))
+
+	saved, _ := Store.Load()
+	if len(saved) != 1 || saved[0].ID != 3 {
+		t.Errorf("Expected the task added after clear to get ID 3, got %+v", saved)
+	}
+}
+
+func TestUndoConflictIsConflictError(t *testing.T) {
+	journal := &todo.Journal{}
+	todos := &todo.Todos{}
+	before := todos.Clone()
+	todos.Add(nil, "Task", nil, todo.Low, nil)
+	journal.Record("add", before, *todos)
+	(*todos)[0].Task = "Edited elsewhere"
+
+	if err := UndoCommand(1, journal, todos); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected conflict error, got %v", err)
+	}
+}
+
+func TestShowCommand(t *testing.T) {
+	todos := &todo.Todos{}
+	todos.Add(nil, "Test task", nil, todo.High, nil)
+	AddTagCommand([]string{"1", "work"}, todos)
+	CompleteCommand([]string{"1"}, todos)
+
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	ShowCommand([]string{"1"}, true, todos)
+
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	output := buf.String()
+
+	expectedStrings := []string{"Task 1: Test task", "Done", "High", "work", "Tags: None -> work", "Completed: false -> true"}
+	for _, s := range expectedStrings {
+		if !strings.Contains(output, s) {
+			t.Errorf("Expected output to contain '%s', but got:\n%s", s, output)
+		}
+	}
+}
+
+func TestUndoRedoCommands(t *testing.T) {
+	journal := &todo.Journal{}
+	todos := &todo.Todos{}
+	todos.Add(nil, "Task 1", nil, todo.Low, nil)
+
+	before := todos.Clone()
+	ClearTasksCommand(todos)
+	journal.Record("--clear-tasks", before, *todos)
+
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	UndoCommand(1, journal, todos)
+	HistoryCommand(journal)
+
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	output := buf.String()
+
+	if len(*todos) != 1 {
+		t.Fatalf("Expected undo to restore the cleared task, got %v", *todos)
+	}
+	if !strings.Contains(output, "Undid #1: --clear-tasks") || !strings.Contains(output, "(undone)") {
+		t.Errorf("Unexpected output:\n%s", output)
+	}
+
+	RedoCommand(2, journal, todos)
+	if len(*todos) != 0 {
+		t.Errorf("Expected redo to clear the tasks again, got %v", *todos)
+	}
+}
+
 func stringSlicesEqual(a, b []string) bool {
 	if len(a) != len(b) {
 		return false
@@ -382,3 +597,536 @@ func stringSlicesEqual(a, b []string) bool {
 	}
 	return true
 }
+
+func TestCommandsAreScopedToList(t *testing.T) {
+	oldStore, oldList := Store, ListName
+	Store = todo.NewJSONStore(filepath.Join(t.TempDir(), "todos.json"))
+	defer func() { Store, ListName = oldStore, oldList }()
+
+	todos := &todo.Todos{}
+	AddCommand([]string{"Home task"}, nil, todo.Low, todos, nil)
+	if err := CreateListCommand("work", todos); err != nil {
+		t.Fatalf("Error creating list: %v", err)
+	}
+	ListName = "work"
+	AddCommand([]string{"Work task"}, nil, todo.Low, todos, nil)
+
+	if (*todos)[1].List != "work" {
+		t.Errorf("Expected the task to be added to 'work', got %q", (*todos)[1].List)
+	}
+	if err := CompleteCommand([]string{"1"}, todos); KindOf(err) != NotFound {
+		t.Errorf("Expected tasks of other lists not to be found, got %v", err)
+	}
+	ClearTasksCommand(todos)
+	if len(*todos) != 1 || (*todos)[0].Task != "Home task" {
+		t.Errorf("Expected clear to leave other lists alone, got %v", *todos)
+	}
+
+	ListName = todo.DefaultList
+	if err := MoveCommand([]string{"1", "missing"}, todos); KindOf(err) != NotFound {
+		t.Errorf("Expected moving to a missing list to be not found, got %v", err)
+	}
+	if err := MoveCommand([]string{"1", "work"}, todos); err != nil {
+		t.Fatalf("Error moving task: %v", err)
+	}
+	ListName = "work"
+	if err := CompleteCommand([]string{"1"}, todos); err != nil || !(*todos)[0].Completed {
+		t.Errorf("Expected the moved task to be found in 'work', got %v", err)
+	}
+}
+
+func TestSubtaskCommands(t *testing.T) {
+	oldRules, oldStore := SubtaskRules, Store
+	defer func() { SubtaskRules, Store = oldRules, oldStore }()
+	// A new file, so that IDs start at 1.
+	Store = todo.NewJSONStore(filepath.Join(t.TempDir(), "todos.json"))
+
+	todos := &todo.Todos{}
+	AddCommand([]string{"Release"}, nil, todo.Low, todos, nil)
+	if err := AddSubtaskCommand(1, []string{"Notes"}, nil, todo.Low, todos, nil); err != nil {
+		t.Fatalf("Error adding subtask: %v", err)
+	}
+	if err := AddSubtaskCommand(9, []string{"Nowhere"}, nil, todo.Low, todos, nil); KindOf(err) != NotFound {
+		t.Errorf("Expected a missing parent to be not found, got %v", err)
+	}
+
+	SubtaskRules = todo.SubtaskRules{OnComplete: todo.Block}
+	if err := CompleteCommand([]string{"1"}, todos); KindOf(err) != InvalidInput {
+		t.Errorf("Expected completing a parent with open subtasks to be refused, got %v", err)
+	}
+	SubtaskRules = todo.SubtaskRules{OnComplete: todo.Cascade}
+	if err := CompleteCommand([]string{"1"}, todos); err != nil || !(*todos)[1].Completed {
+		t.Errorf("Expected the subtask to be completed with its parent, got %v", err)
+	}
+}
+
+func TestDependencyCommands(t *testing.T) {
+	todos := &todo.Todos{{ID: 1, Task: "Design"}, {ID: 2, Task: "Build"}}
+	if err := DependCommand(2, 1, todos); err != nil {
+		t.Fatalf("Error adding dependency: %v", err)
+	}
+	if err := DependCommand(1, 2, todos); KindOf(err) != InvalidInput {
+		t.Errorf("Expected a cycle to be invalid input, got %v", err)
+	}
+	if err := UndependCommand(1, 2, todos); KindOf(err) != NotFound {
+		t.Errorf("Expected removing a missing dependency to be not found, got %v", err)
+	}
+
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	ReadyCommand(todos)
+	w.Close()
+	os.Stdout = old
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+
+	if !strings.Contains(buf.String(), "Design") || strings.Contains(buf.String(), "Build") {
+		t.Errorf("Expected only the unblocked task to be ready, got:\n%s", buf.String())
+	}
+}
+
+func TestRecurCommand(t *testing.T) {
+	due, _ := time.Parse("2006-01-02", "2024-03-04")
+	todos := &todo.Todos{{ID: 1, Task: "Standup notes", DueDate: &due}}
+	if err := RecurCommand(1, "every sometimes", todos); KindOf(err) != InvalidInput {
+		t.Errorf("Expected an invalid rule to be invalid input, got %v", err)
+	}
+	if err := RecurCommand(1, "every monday", todos); err != nil {
+		t.Fatalf("Error setting rule: %v", err)
+	}
+	if (*todos)[0].Recur != "FREQ=WEEKLY;BYDAY=MO" {
+		t.Errorf("Expected the rule to be stored as an RRULE, got %s", (*todos)[0].Recur)
+	}
+
+	CompleteCommand([]string{"1"}, todos)
+	if len(*todos) != 2 || todo.FormatDueDate((*todos)[1].DueDate) != "2024-03-11" {
+		t.Errorf("Expected the next occurrence on 2024-03-11, got %v", *todos)
+	}
+}
+
+func TestStatusCommand(t *testing.T) {
+	todos := &todo.Todos{{ID: 1, Task: "Review"}}
+	if err := StatusCommand("1", "waiting", todos); err != nil {
+		t.Fatalf("Error changing status: %v", err)
+	}
+	if err := StatusCommand("1", "someday", todos); KindOf(err) != InvalidInput {
+		t.Errorf("Expected an unknown status to be invalid input, got %v", err)
+	}
+	StatusCommand("1", "cancelled", todos)
+	if err := CompleteCommand([]string{"1"}, todos); KindOf(err) != InvalidInput {
+		t.Errorf("Expected completing a cancelled task to be invalid input, got %v", err)
+	}
+	if err := StatusCommand("1", "done", todos); KindOf(err) != InvalidInput {
+		t.Errorf("Expected a disallowed change to be invalid input, got %v", err)
+	}
+	StatusCommand("1", "todo", todos)
+	if err := StatusCommand("1", "done", todos); err != nil || !(*todos)[0].Completed {
+		t.Errorf("Expected the task to be completed, got %+v (%v)", (*todos)[0], err)
+	}
+	if err := StatusCommand("2", "done", todos); KindOf(err) != NotFound {
+		t.Errorf("Expected a missing task to be not found, got %v", err)
+	}
+}
+
+func TestAgendaCommandIsScopedToList(t *testing.T) {
+	oldNow := todo.Now
+	defer func() { todo.Now = oldNow }()
+	todo.Now = func() time.Time { return time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC) }
+	due, _ := time.Parse("2006-01-02", "2024-03-01")
+	todos := &todo.Todos{
+		{ID: 1, Task: "Pay rent", DueDate: &due},
+		{ID: 2, Task: "Write report", DueDate: &due, List: "work"},
+		{ID: 3, Task: "Call mom"},
+	}
+	output := captureOutput(func() { AgendaCommand(true, todos) })
+	if strings.TrimSpace(output) != "1 overdue, 0 today, 2 open" {
+		t.Errorf("Unexpected summary %q", output)
+	}
+	output = captureOutput(func() { AgendaCommand(false, todos) })
+	if !strings.Contains(output, "Overdue (1)") || strings.Contains(output, "Write report") {
+		t.Errorf("Expected the default list's agenda, got %q", output)
+	}
+}
+
+// captureOutput returns what f prints to stdout.
+func captureOutput(f func()) string {
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	f()
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	return buf.String()
+}
+
+func TestNextCommand(t *testing.T) {
+	todos := &todo.Todos{
+		{ID: 1, Task: "Water plants"},
+		{ID: 2, Task: "Fix the roof", Priority: todo.High, Tags: []string{"urgent"}},
+		{ID: 3, Task: "Plan offsite", Priority: todo.High, Tags: []string{"urgent"}, List: "work"},
+	}
+	output := captureOutput(func() { NextCommand(todos) })
+	if !strings.HasPrefix(output, "Next: task 2") || strings.Contains(output, "Plan offsite") {
+		t.Errorf("Expected task 2 to be next, got %q", output)
+	}
+}
+
+func TestListCommandUsesView(t *testing.T) {
+	defer func() { View = TableView{} }()
+	todos := &todo.Todos{
+		{ID: 1, Task: "Low task", Priority: todo.Low},
+		{ID: 2, Task: "High task", Priority: todo.High},
+	}
+	keys, _ := todo.ParseSort("priority-")
+	View = TableView{Sort: keys, Columns: []string{"id", "task"}}
+
+	output := captureOutput(func() { ListCommand(todos) })
+	if strings.Index(output, "High task") > strings.Index(output, "Low task") {
+		t.Errorf("Expected the high priority task first, got %q", output)
+	}
+	if strings.Contains(output, "Priority") {
+		t.Errorf("Expected only the id and task columns, got %q", output)
+	}
+	if (*todos)[0].ID != 1 {
+		t.Errorf("Expected sorting not to reorder the list itself")
+	}
+}
+
+func TestFilterLimitsListingAndClear(t *testing.T) {
+	defer func() { Filter = nil }()
+	todos := &todo.Todos{
+		{ID: 1, Task: "Write report", Tags: []string{"work"}},
+		{ID: 2, Task: "Buy milk", Tags: []string{"home"}, Completed: true},
+		{ID: 3, Task: "Old note", Tags: []string{"work"}, Completed: true},
+	}
+	var err error
+	if Filter, err = filter.Parse("tag:work", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	output := captureOutput(func() { ListCommand(todos) })
+	if !strings.Contains(output, "Write report") || strings.Contains(output, "Buy milk") {
+		t.Errorf("Expected only work tasks, got %q", output)
+	}
+
+	Filter, _ = filter.Parse("tag:work and status:done", time.Now())
+	captureOutput(func() { ClearTasksCommand(todos) })
+	if len(*todos) != 2 || todos.IndexOf(3) >= 0 {
+		t.Errorf("Expected only the finished work task to be cleared, got %+v", *todos)
+	}
+}
+
+func TestClearAppliesSubtaskPolicy(t *testing.T) {
+	oldRules := SubtaskRules
+	defer func() { SubtaskRules, Filter = oldRules, nil }()
+	newList := func() *todo.Todos {
+		return &todo.Todos{
+			{ID: 1, Task: "Release", Tags: []string{"old"}},
+			{ID: 2, Task: "Notes", ParentID: 1},
+			{ID: 3, Task: "Unrelated"},
+		}
+	}
+	Filter, _ = filter.Parse("tag:old", time.Now())
+
+	SubtaskRules = todo.SubtaskRules{OnDelete: todo.Block}
+	if err := ClearTasksCommand(newList()); KindOf(err) != InvalidInput {
+		t.Errorf("Expected clearing a parent with subtasks to be refused, got %v", err)
+	}
+
+	SubtaskRules = todo.SubtaskRules{OnDelete: todo.Cascade}
+	todos := newList()
+	captureOutput(func() { ClearTasksCommand(todos) })
+	if len(*todos) != 1 || (*todos)[0].ID != 3 {
+		t.Errorf("Expected the subtask to be cleared with its parent, got %+v", *todos)
+	}
+
+	SubtaskRules = todo.SubtaskRules{OnDelete: todo.Orphan}
+	todos = newList()
+	captureOutput(func() { ClearTasksCommand(todos) })
+	if len(*todos) != 2 || (*todos)[0].ID != 2 || (*todos)[0].ParentID != 0 {
+		t.Errorf("Expected the subtask to be kept as a top-level task, got %+v", *todos)
+	}
+
+	Filter = nil
+	SubtaskRules = todo.SubtaskRules{OnDelete: todo.Block}
+	todos = newList()
+	if err := ClearTasksCommand(todos); err != nil || len(*todos) != 0 {
+		t.Errorf("Expected clearing the whole list to include subtasks, got %v, %+v", err, *todos)
+	}
+}
+
+func TestBulkCommands(t *testing.T) {
+	oldConfirm := Confirm
+	defer func() { Confirm, ConfirmAbove = oldConfirm, 5 }()
+	todos := &todo.Todos{}
+	for i := 1; i <= 8; i++ {
+		*todos = append(*todos, todo.Todo{ID: i, Task: fmt.Sprintf("Task %d", i), Tags: []string{"sprint"}})
+	}
+
+	captureOutput(func() { CompleteCommand([]string{"1,3", "5-6"}, todos) })
+	for i, task := range *todos {
+		if want := i == 0 || i == 2 || i == 4 || i == 5; task.Completed != want {
+			t.Errorf("Task %d: expected completed %v, got %v", task.ID, want, task.Completed)
+		}
+	}
+
+	if err := AddTagCommand([]string{"status:open", "carry-over"}, todos); err != nil {
+		t.Fatal(err)
+	}
+	if !contains((*todos)[1].Tags, "carry-over") || contains((*todos)[0].Tags, "carry-over") {
+		t.Errorf("Expected only open tasks to be tagged, got %+v", *todos)
+	}
+
+	asked := ""
+	Confirm = func(question string) bool { asked = question; return false }
+	ConfirmAbove = 2
+	var err error
+	captureOutput(func() { err = WithAnswers(func() error { return DeleteCommand([]string{"1-8"}, todos) }) })
+	if asked != "Delete 8 tasks?" || KindOf(err) != Failure || len(*todos) != 8 {
+		t.Errorf("Expected a declined delete to change nothing, asked %q, got %v", asked, err)
+	}
+
+	// The question is asked with the list unlocked, so the tasks may change
+	// before the answer comes back.
+	Confirm = func(string) bool { return true }
+	var question *Question
+	if err := DeleteCommand([]string{"1-8"}, todos); !errors.As(err, &question) || len(*todos) != 8 {
+		t.Fatalf("Expected a question before deleting anything, got %v", err)
+	}
+	captureOutput(func() { err = question.Ask() })
+	(*todos)[7].Task = "Renamed meanwhile"
+	if err := DeleteCommand([]string{"1-8"}, todos); KindOf(err) != Conflict || len(*todos) != 8 {
+		t.Errorf("Expected a conflict when a task changed after the question, got %v", err)
+	}
+
+	StatusCommand("2", "cancelled", todos)
+	if err := CompleteCommand([]string{"2-4"}, todos); KindOf(err) != InvalidInput || (*todos)[3].Completed {
+		t.Errorf("Expected nothing to be completed when one task cannot be, got %v", err)
+	}
+	for _, spec := range []string{"milk", "4-2", "tag:"} {
+		if err := DeleteCommand([]string{spec}, todos); KindOf(err) != InvalidInput {
+			t.Errorf("Expected %q to be invalid input, got %v", spec, err)
+		}
+	}
+	if err := DeleteCommand([]string{"tag:missing"}, todos); KindOf(err) != NotFound {
+		t.Errorf("Expected a query matching nothing to be not found, got %v", err)
+	}
+}
+
+func TestEditFieldsCommand(t *testing.T) {
+	due := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	todos := &todo.Todos{
+		{ID: 1, Task: "Write report", DueDate: &due, Tags: []string{"work"}},
+		{ID: 2, Task: "Buy milk", DueDate: &due, Tags: []string{"home"}},
+	}
+	high := todo.High
+	captureOutput(func() { EditFieldsCommand("1,2", TaskEdit{Priority: &high, ClearDue: true}, todos) })
+	for _, task := range *todos {
+		if task.Priority != todo.High || task.DueDate != nil {
+			t.Errorf("Expected task %d to be high priority with no due date, got %+v", task.ID, task)
+		}
+	}
+	name := "Write the report"
+	captureOutput(func() { EditFieldsCommand("1", TaskEdit{Task: &name, ClearTags: true}, todos) })
+	if task := (*todos)[0]; task.Task != name || task.Tags != nil || (*todos)[1].Task != "Buy milk" {
+		t.Errorf("Expected only task 1 to be renamed and untagged, got %+v", *todos)
+	}
+	empty := " "
+	if err := EditFieldsCommand("1", TaskEdit{Task: &empty}, todos); KindOf(err) != InvalidInput {
+		t.Errorf("Expected an empty description to be invalid input, got %v", err)
+	}
+}
+
+func TestEditorCommand(t *testing.T) {
+	oldEditor := RunEditor
+	defer func() { RunEditor = oldEditor }()
+	todos := &todo.Todos{{ID: 1, Task: "Write report", Priority: todo.Medium, Tags: []string{"work"}}}
+
+	var shown []string
+	edits := []func(string) string{
+		func(doc string) string {
+			return strings.Replace(doc, `priority = "medium"`, `priority = "urgent"`, 1)
+		},
+		func(doc string) string {
+			doc = strings.Replace(doc, `priority = "urgent"`, `priority = "high"`, 1)
+			doc = strings.Replace(doc, `due = ""`, `due = "2024-07-01"`, 1)
+			return strings.Replace(doc, `tags = ["work"]`, `tags = []`, 1)
+		},
+	}
+	RunEditor = func(path string) error {
+		data, _ := os.ReadFile(path)
+		shown = append(shown, string(data))
+		return os.WriteFile(path, []byte(edits[len(shown)-1](string(data))), 0600)
+	}
+	output := captureOutput(func() { WithAnswers(func() error { return EditorCommand(1, todos) }) })
+
+	if len(shown) != 2 || !strings.Contains(shown[1], "# error: invalid priority: urgent") {
+		t.Fatalf("Expected the document to be reopened with the error, got %q", shown)
+	}
+	task := (*todos)[0]
+	if task.Priority != todo.High || todo.FormatDueDate(task.DueDate) != "2024-07-01" || task.Tags != nil || task.Task != "Write report" {
+		t.Errorf("Expected the edit to be applied, got %+v (%s)", task, output)
+	}
+
+	shown = nil
+	edits = []func(string) string{func(doc string) string {
+		return strings.Replace(doc, `priority = "high"`, `priority = "low"`, 1)
+	}}
+	var question *Question
+	if err := EditorCommand(1, todos); !errors.As(err, &question) {
+		t.Fatalf("Expected the editor to be opened as a question, got %v", err)
+	}
+	captureOutput(func() { question.Ask() })
+	(*todos)[0].Task = "Edited meanwhile"
+	if err := EditorCommand(1, todos); KindOf(err) != Conflict || (*todos)[0].Priority != todo.High {
+		t.Errorf("Expected a conflict when the task changed while it was being edited, got %v", err)
+	}
+}
+
+func TestParseTaskDocument(t *testing.T) {
+	task := todo.Todo{ID: 1, Task: "Write report"}
+	tests := []struct {
+		doc     string
+		problem string
+	}{
+		{"task = \"x\"\ndue = \"\"\npriority = \"low\"\ntags = [\"a\" \"b\"]", `expected "," or "]"`},
+		{"task = x\ndue = \"\"\npriority = \"low\"\ntags = []", `task: expected a "quoted" value`},
+		{"task = \"x\"\ntask = \"y\"\ndue = \"\"\npriority = \"low\"\ntags = []", "task is already set on line 1"},
+		{"task = \"x\"\ndue = \"someday\"\npriority = \"low\"\ntags = []", "invalid due date"},
+		{"task = \"x\"\ndue = \"\"\npriority = \"low\"\ncolor = \"red\"", `unknown key "color"`},
+	}
+	for _, tt := range tests {
+		_, problems := parseTaskDocument(tt.doc, task)
+		var msgs []string
+		for _, p := range problems {
+			msgs = append(msgs, p.msg)
+		}
+		if !strings.Contains(strings.Join(msgs, "; "), tt.problem) {
+			t.Errorf("parseTaskDocument(%q): expected a problem containing %q, got %q", tt.doc, tt.problem, msgs)
+		}
+	}
+}
+
+func TestAnnotateCommand(t *testing.T) {
+	oldNow := todo.Now
+	defer func() { todo.Now = oldNow }()
+	todo.Now = func() time.Time { return time.Date(2024, 7, 1, 14, 5, 0, 0, time.UTC) }
+	todos := &todo.Todos{{ID: 1, Task: "Vendor contract", Notes: "Contact: Jane"}, {ID: 2, Task: "Other"}}
+
+	captureOutput(func() { AnnotateCommand("1", "called vendor", todos) })
+	if a := (*todos)[0].Annotations; len(a) != 1 || a[0].Text != "called vendor" || !a[0].Time.Equal(todo.Now()) {
+		t.Errorf("Expected an annotation, got %+v", a)
+	}
+	if err := AnnotateCommand("1", " ", todos); KindOf(err) != InvalidInput {
+		t.Errorf("Expected an empty annotation to be invalid input, got %v", err)
+	}
+
+	output := captureOutput(func() { SearchCommand([]string{"jane"}, todos) })
+	if !strings.Contains(output, "Found 1 matching task(s)") {
+		t.Errorf("Expected notes to be searched, got %q", output)
+	}
+	output = captureOutput(func() { ShowCommand([]string{"1"}, false, todos) })
+	if !strings.Contains(output, "Notes:\n  Contact: Jane") || !strings.Contains(output, "2024-07-01") {
+		t.Errorf("Expected show to include notes and annotations, got %q", output)
+	}
+}
+
+func TestTaskDocumentRoundTrip(t *testing.T) {
+	for _, notes := range []string{
+		"", "one line", "Contact: Jane\n\n# not a comment\n", `has """ quotes`,
+		`ends in a "quote"`, `""`, "\n\nstarts with blank lines", `C:\reports\q3 and \n as text`, "tab\there, bell\a, cr\r\n",
+	} {
+		task := todo.Todo{ID: 1, Task: "Write report", Tags: []string{"work"}, Notes: "old"}
+		doc := taskDocument(todo.Todo{ID: 1, Task: "Write report", Tags: []string{"work"}, Notes: notes})
+		edit, problems := parseTaskDocument(doc, task)
+		if len(problems) > 0 || edit.Notes == nil || *edit.Notes != notes {
+			t.Errorf("Notes %q: expected them to read back, got %+v %v\n%s", notes, edit, problems, doc)
+		}
+	}
+
+	task := todo.Todo{ID: 1, Task: `Write "Q3" report \ draft`, Tags: []string{`say "hi"`, `a\b`}}
+	doc := taskDocument(task)
+	if !strings.Contains(doc, `task = "Write \"Q3\" report \\ draft"`) {
+		t.Errorf("Expected the task to be escaped as TOML, got\n%s", doc)
+	}
+	edit, problems := parseTaskDocument(doc, todo.Todo{ID: 1, Task: "old"})
+	if len(problems) > 0 || edit.Task == nil || *edit.Task != task.Task || todo.FormatTags(edit.Tags) != todo.FormatTags(task.Tags) {
+		t.Errorf("Expected the task and tags to read back, got %+v %v", edit, problems)
+	}
+}
+
+func TestParseTOMLStrings(t *testing.T) {
+	tests := []struct {
+		value, want, problem string
+	}{
+		{`"caf\u00e9 \U0001F600"`, "café 😀", ""},
+		{`"a\tb" # comment`, "a\tb", ""},
+		{`"C:\temp"`, "C:\temp", ""},
+		{`"C:\data"`, "", `invalid escape \d`},
+		{`"\x41"`, "", `invalid escape \x`},
+		{`"\u00e"`, "", "4 hex digits"},
+		{`"open`, "", `missing the closing "`},
+	}
+	for _, tt := range tests {
+		got, err := parseString(tt.value)
+		if tt.problem == "" && (err != nil || got != tt.want) {
+			t.Errorf("parseString(%s): expected %q, got %q, %v", tt.value, tt.want, got, err)
+		}
+		if tt.problem != "" && (err == nil || !strings.Contains(err.Error(), tt.problem)) {
+			t.Errorf("parseString(%s): expected an error containing %q, got %q, %v", tt.value, tt.problem, got, err)
+		}
+	}
+
+	lines := []string{`notes = """`, `one \`, `    two""""`, `after = "x"`}
+	if got, end, err := parseMultiline("", lines, 0); err != nil || got != `one two"` || end != 2 {
+		t.Errorf("Expected a line-ending backslash and a quote before the closing ones, got %q on line %d, %v", got, end, err)
+	}
+}
+
+func TestListingOutputFormats(t *testing.T) {
+	defer func() { Output = "table" }()
+	todos := &todo.Todos{
+		{ID: 1, Task: "Write report", Priority: todo.High, Tags: []string{"work"}},
+		{ID: 2, Task: "Report expenses", Tags: []string{"work"}},
+		{ID: 3, Task: "Call mom"},
+	}
+
+	Output = "ndjson"
+	output := captureOutput(func() { SearchCommand([]string{"report"}, todos) })
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"score":`) || strings.Contains(output, "Found") {
+		t.Errorf("Expected one JSON line with a score per result, got %q", output)
+	}
+	output = captureOutput(func() { SearchCommand([]string{"nothing"}, todos) })
+	if output != "" {
+		t.Errorf("Expected no lines for no results, got %q", output)
+	}
+
+	Output = "json"
+	output = captureOutput(func() { FilterByTagCommand([]string{"none"}, todos) })
+	if !strings.Contains(output, `"tasks": []`) {
+		t.Errorf("Expected an empty task list rather than a message, got %q", output)
+	}
+
+	Output = "csv"
+	output = captureOutput(func() { ListCommand(todos) })
+	if !strings.HasPrefix(output, "id,task,due,") || !strings.Contains(output, "\n3,Call mom,") {
+		t.Errorf("Expected CSV of every column, got %q", output)
+	}
+	if header, _, _ := strings.Cut(output, "\n"); !strings.Contains(header, ",notes,annotations,") {
+		t.Errorf("Expected notes and annotations among the CSV columns, got %q", header)
+	}
+	output = captureOutput(func() { VisualizeCommand(todos) })
+	if !strings.Contains(output, "priority,high,1,3\n") {
+		t.Errorf("Expected the chart counts as CSV, got %q", output)
+	}
+
+	Output = "markdown"
+	output = captureOutput(func() { ListCommand(todos) })
+	if !strings.HasPrefix(output, "| ID | Task | Due Date | Priority | Urgency | Status | Tags | Notes | Annotations |\n") {
+		t.Errorf("Expected the table's columns plus notes and annotations, got %q", output)
+	}
+}
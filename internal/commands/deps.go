@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"go-todo-cli/internal/todo"
+)
+
+// DependCommand makes task id wait for task dependsOn, which may be in any
+// list.
+func DependCommand(id, dependsOn int, todoList *todo.Todos) error {
+	if _, err := taskIndex(fmt.Sprint(id), todoList); err != nil {
+		return err
+	}
+	if todoList.IndexOf(dependsOn) < 0 {
+		return Errorf(NotFound, "task %d not found", dependsOn)
+	}
+	if err := todoList.AddDependency(id, dependsOn); err != nil {
+		if errors.Is(err, todo.ErrDependencyCycle) {
+			return Errorf(InvalidInput, "%w", err)
+		}
+		return Errorf(Failure, "%w", err)
+	}
+	fmt.Printf("Task %d now depends on task %d.\n", id, dependsOn)
+	return nil
+}
+
+func UndependCommand(id, dependsOn int, todoList *todo.Todos) error {
+	if _, err := taskIndex(fmt.Sprint(id), todoList); err != nil {
+		return err
+	}
+	if !todoList.RemoveDependency(id, dependsOn) {
+		return Errorf(NotFound, "task %d does not depend on task %d", id, dependsOn)
+	}
+	fmt.Printf("Task %d no longer depends on task %d.\n", id, dependsOn)
+	return nil
+}
+
+// ReadyCommand lists the pending tasks of the current list that are not
+// waiting for any other task.
+func ReadyCommand(todoList *todo.Todos) error {
+	ready := listed(todoList.Ready(), todoList)
+	if len(ready) == 0 && Output == "table" {
+		fmt.Println("No tasks are ready to start.")
+		return nil
+	}
+	return printTasks(ready, todoList)
+}
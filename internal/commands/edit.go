@@ -0,0 +1,551 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"go-todo-cli/internal/todo"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// TaskEdit is a change to make to tasks. Fields left nil or false stay as
+// they are.
+type TaskEdit struct {
+	Task      *string
+	Due       *time.Time
+	ClearDue  bool
+	Priority  *todo.Priority
+	Tags      []string
+	ClearTags bool
+	Notes     *string // "" removes the notes
+}
+
+// IsEmpty reports whether the edit changes nothing.
+func (e TaskEdit) IsEmpty() bool {
+	return e.Task == nil && e.Due == nil && !e.ClearDue && e.Priority == nil && e.Tags == nil && !e.ClearTags && e.Notes == nil
+}
+
+// apply makes the edit to task, recording each change.
+func (e TaskEdit) apply(task *todo.Todo) {
+	if e.Task != nil {
+		task.RecordChange("Task", task.Task, *e.Task)
+		task.Task = *e.Task
+	}
+	if e.Due != nil {
+		due := *e.Due
+		task.SetDueDate(&due)
+	} else if e.ClearDue && task.DueDate != nil {
+		task.SetDueDate(nil)
+	}
+	if e.Priority != nil {
+		task.RecordChange("Priority", task.Priority.String(), e.Priority.String())
+		task.Priority = *e.Priority
+	}
+	if e.ClearTags {
+		task.RecordChange("Tags", todo.FormatTags(task.Tags), todo.FormatTags(nil))
+		task.Tags = nil
+	} else if e.Tags != nil {
+		task.RecordChange("Tags", todo.FormatTags(task.Tags), todo.FormatTags(e.Tags))
+		task.Tags = e.Tags
+	}
+	if e.Notes != nil {
+		task.SetNotes(*e.Notes)
+	}
+}
+
+// EditFieldsCommand makes the same edit to the selected tasks; see
+// selectTasks.
+func EditFieldsCommand(spec string, edit TaskEdit, todoList *todo.Todos) error {
+	if edit.Task != nil && strings.TrimSpace(*edit.Task) == "" {
+		return Errorf(InvalidInput, "task description cannot be empty")
+	}
+	for _, tag := range edit.Tags {
+		if tag == "" {
+			return Errorf(InvalidInput, "tag cannot be empty")
+		}
+	}
+	ids, err := selectTasks(spec, todoList)
+	if err != nil {
+		return err
+	}
+	if err := confirmChange("Edit", ids, todoList); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		edit.apply(&(*todoList)[todoList.IndexOf(id)])
+	}
+	fmt.Printf("%s updated.\n", capitalize(pluralTasks(ids)))
+	return nil
+}
+
+// RunEditor opens a file in the user's editor and waits for it to close.
+// Tests replace it.
+var RunEditor = func(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// The editor may come with arguments, as in "code --wait".
+	words := strings.Fields(editor)
+	cmd := exec.Command(words[0], append(words[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// EditorCommand opens a task in $VISUAL or $EDITOR as a small TOML
+// document. A document that does not validate is opened again with the
+// problems noted above the offending lines; saving it unchanged, or empty,
+// gives up without changing the task.
+func EditorCommand(taskID int, todoList *todo.Todos) error {
+	return editTask(taskID, todoList, editInEditor)
+}
+
+// edited holds the change the user last made to a task, until editTask
+// runs again to make it.
+var edited *taskChange
+
+type taskChange struct {
+	before todo.Todo // the task the change was made to
+	edit   *TaskEdit // nil to leave the task as it is
+}
+
+// editTask has ask come up with a change to the task with ID taskID, then
+// makes it. ask gets a copy of the task and runs as a Question, with the
+// list unlocked, so the change is only made if the task is still as it was.
+func editTask(taskID int, todoList *todo.Todos, ask func(task todo.Todo) (*TaskEdit, error)) error {
+	index, err := taskIndex(strconv.Itoa(taskID), todoList)
+	if err != nil {
+		return err
+	}
+	task := &(*todoList)[index]
+	change := edited
+	edited = nil
+	if change == nil || change.before.ID != taskID {
+		before := todo.Todos{*task}.Clone()[0]
+		return &Question{func() error {
+			edit, err := ask(before)
+			if err != nil {
+				return err
+			}
+			edited = &taskChange{before, edit}
+			return nil
+		}}
+	}
+	if change.edit == nil {
+		return nil
+	}
+	if !sameTasks(todo.Todos{change.before}, todo.Todos{*task}) {
+		return Errorf(Conflict, "task %d changed while you were editing it; your edit was not saved", taskID)
+	}
+	change.edit.apply(task)
+	fmt.Println("Task updated successfully.")
+	return nil
+}
+
+// editInEditor has the user edit task in their editor. It returns nil if
+// they gave up.
+func editInEditor(task todo.Todo) (*TaskEdit, error) {
+	file, err := os.CreateTemp("", fmt.Sprintf("todo-%d-*.toml", task.ID))
+	if err != nil {
+		return nil, Errorf(Failure, "%w", err)
+	}
+	path := file.Name()
+	file.Close()
+	defer os.Remove(path)
+
+	original := taskDocument(task)
+	shown := original
+	for {
+		if err := os.WriteFile(path, []byte(shown), 0600); err != nil {
+			return nil, Errorf(Failure, "%w", err)
+		}
+		if err := RunEditor(path); err != nil {
+			return nil, Errorf(Failure, "editor: %w", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, Errorf(Failure, "%w", err)
+		}
+		edited := string(data)
+		switch {
+		case edited == original:
+			fmt.Println("No changes.")
+			return nil, nil
+		case edited == shown, strings.TrimSpace(edited) == "":
+			fmt.Println("Edit cancelled; the task was not changed.")
+			return nil, nil
+		}
+
+		edit, problems := parseTaskDocument(edited, task)
+		if len(problems) == 0 {
+			return &edit, nil
+		}
+		shown = annotate(edited, problems)
+	}
+}
+
+// taskDocument renders the editable fields of a task.
+func taskDocument(task todo.Todo) string {
+	tags := make([]string, len(task.Tags))
+	for i, tag := range task.Tags {
+		tags[i] = quoteTOML(tag)
+	}
+	return fmt.Sprintf(`# Task %d. Lines starting with # are ignored, except in notes.
+# Save and close to apply; save it unchanged or empty to cancel.
+
+task = %s
+# YYYY-MM-DD, tomorrow, fri 17:00, in 3 days, ...; "" for none
+due = %s
+# low, medium or high
+priority = %s
+tags = [%s]
+# Anything between the triple quotes, over as many lines as needed; as in
+# TOML, a backslash starts an escape, so write \\ for one
+notes = %s
+`, task.ID, quoteTOML(task.Task), quoteTOML(dueText(task)), quoteTOML(strings.ToLower(task.Priority.String())), strings.Join(tags, ", "), notesText(task.Notes))
+}
+
+// notesText writes notes as a multi-line string, starting on the line after
+// the opening quotes.
+func notesText(notes string) string {
+	return "\"\"\"\n" + escapeTOML(notes, true) + `"""`
+}
+
+// quoteTOML writes s as a TOML basic string.
+func quoteTOML(s string) string {
+	return `"` + escapeTOML(s, false) + `"`
+}
+
+// escapeTOML escapes s for the inside of a TOML basic string, or of a
+// multi-line one, which keeps line breaks and tabs as they are and only
+// escapes a quote where it could be taken for part of the closing """.
+func escapeTOML(s string, multiline bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '"' && (!multiline || i == len(s)-1 || s[i+1] == '"'):
+			b.WriteString(`\"`)
+		case multiline && (r == '\n' || r == '\t'):
+			b.WriteRune(r)
+		case r == '\b':
+			b.WriteString(`\b`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// dueText writes a task's due date so that ParseDueDate reads it back as
+// the same date, naming the zone of a due time given in another zone.
+func dueText(task todo.Todo) string {
+	due, ok := task.Due()
+	if !ok {
+		return ""
+	}
+	if task.DueZone == "" && !todo.HasDueTime(due) {
+		return due.Format("2006-01-02")
+	}
+	if task.DueZone == "" || task.DueZone == "Local" {
+		return due.Local().Format("2006-01-02 15:04")
+	}
+	return due.Format("2006-01-02 15:04") + " " + task.DueZone
+}
+
+// docProblem is something wrong with an edited document, at a line
+// counted from 1, or 0 for the document as a whole.
+type docProblem struct {
+	line int
+	msg  string
+}
+
+const problemPrefix = "# error: "
+
+// parseTaskDocument reads an edited task document into the changes it makes
+// to task. Only fields whose text changed are set, so that a due date is not
+// moved by being written out and read back.
+func parseTaskDocument(doc string, task todo.Todo) (TaskEdit, []docProblem) {
+	var edit TaskEdit
+	var problems []docProblem
+	problem := func(line int, format string, args ...any) {
+		problems = append(problems, docProblem{line, fmt.Sprintf(format, args...)})
+	}
+
+	seen := map[string]int{}
+	lines := strings.Split(doc, "\n")
+	for i := 0; i < len(lines); i++ {
+		n := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok {
+			problem(n, "expected key = value")
+			continue
+		}
+		if first, dup := seen[key]; dup {
+			problem(n, "%s is already set on line %d", key, first)
+			continue
+		}
+		seen[key] = n
+
+		switch key {
+		case "task", "due", "priority":
+			s, err := parseString(value)
+			if err != nil {
+				problem(n, "%s: %v", key, err)
+				continue
+			}
+			switch key {
+			case "task":
+				if strings.TrimSpace(s) == "" {
+					problem(n, "task cannot be empty")
+				} else if s != task.Task {
+					edit.Task = &s
+				}
+			case "due":
+				if s == dueText(task) {
+					continue
+				}
+				if s == "" {
+					edit.ClearDue = true
+					continue
+				}
+				due, err := todo.ParseDueDate(s, todo.Now())
+				if err != nil {
+					problem(n, "%v", err)
+				} else {
+					edit.Due = &due
+				}
+			case "priority":
+				priority, err := todo.ParsePriority(s)
+				if err != nil {
+					problem(n, "%v (use low, medium or high)", err)
+				} else if priority != task.Priority {
+					edit.Priority = &priority
+				}
+			}
+		case "tags":
+			tags, err := parseStrings(value)
+			if err != nil {
+				problem(n, "tags: %v", err)
+				continue
+			}
+			if todo.FormatTags(tags) != todo.FormatTags(task.Tags) {
+				edit.Tags, edit.ClearTags = tags, len(tags) == 0
+			}
+		case "notes":
+			notes, err := parseString(value)
+			if rest, ok := strings.CutPrefix(value, `"""`); ok {
+				notes, i, err = parseMultiline(rest, lines, i)
+			}
+			if err != nil {
+				problem(n, "notes: %v", err)
+			} else if notes != task.Notes {
+				edit.Notes = &notes
+			}
+		default:
+			problem(n, "unknown key %q (use task, due, priority, tags or notes)", key)
+		}
+	}
+	for _, key := range []string{"task", "due", "priority", "tags", "notes"} {
+		if _, ok := seen[key]; !ok {
+			problem(0, "%s is missing", key)
+		}
+	}
+	return edit, problems
+}
+
+// parseString reads a TOML basic string, such as "Write \"Q3\" report".
+func parseString(value string) (string, error) {
+	s, rest, err := quotedPrefix(value)
+	if err != nil {
+		return "", err
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected %q after the value", rest)
+	}
+	return s, nil
+}
+
+// quotedPrefix reads the basic string value starts with, returning it and
+// what follows it.
+func quotedPrefix(value string) (string, string, error) {
+	rest, ok := strings.CutPrefix(value, `"`)
+	if !ok {
+		return "", "", errors.New(`expected a "quoted" value`)
+	}
+	for i := 0; i < len(rest); i++ {
+		switch rest[i] {
+		case '\\':
+			i++
+		case '"':
+			s, err := unescapeTOML(rest[:i])
+			return s, rest[i+1:], err
+		}
+	}
+	return "", "", errors.New(`missing the closing "`)
+}
+
+// parseMultiline reads a string that started with """ on lines[i], rest
+// being what followed the quotes. It returns the string and the line it
+// ended on. As in TOML, a line break right after the opening quotes is not
+// part of the string, and up to two quotes right before the closing ones
+// are.
+func parseMultiline(rest string, lines []string, i int) (string, int, error) {
+	text := rest
+	for {
+		if end := closingQuotes(text); end >= 0 {
+			if after := strings.TrimSpace(text[end+3:]); after != "" && !strings.HasPrefix(after, "#") {
+				return "", i, fmt.Errorf("unexpected %q after the closing \"\"\"", after)
+			}
+			s, err := unescapeTOML(strings.TrimPrefix(text[:end], "\n"))
+			return s, i, err
+		}
+		if i++; i == len(lines) {
+			return "", i - 1, errors.New(`missing the closing """`)
+		}
+		text += "\n" + lines[i]
+	}
+}
+
+// closingQuotes returns where the """ ending a multi-line string starts in
+// s, or -1.
+func closingQuotes(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case strings.HasPrefix(s[i:], `"""`):
+			run := len(s[i:]) - len(strings.TrimLeft(s[i:], `"`))
+			return i + run - 3
+		}
+	}
+	return -1
+}
+
+// unescapeTOML replaces the escapes in the inside of a TOML basic or
+// multi-line string with what they stand for.
+func unescapeTOML(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i++; i == len(s) {
+			return "", errors.New(`a \ must be followed by an escape; write \\ for a backslash`)
+		}
+		switch c := s[i]; c {
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case '"', '\\':
+			b.WriteByte(c)
+		case 'u', 'U':
+			n := 4
+			if c == 'U' {
+				n = 8
+			}
+			code, err := strconv.ParseUint(s[i+1:min(i+1+n, len(s))], 16, 32)
+			if err != nil || i+n >= len(s) || !utf8.ValidRune(rune(code)) {
+				return "", fmt.Errorf(`invalid escape \%c: expected %d hex digits of a character`, c, n)
+			}
+			b.WriteRune(rune(code))
+			i += n
+		case ' ', '\t', '\r', '\n':
+			// A backslash at the end of a line joins it to the next one,
+			// dropping the whitespace in between.
+			rest := strings.TrimLeft(s[i:], " \t\r\n")
+			if !strings.Contains(s[i:len(s)-len(rest)], "\n") {
+				return "", errors.New(`a \ must be followed by an escape; write \\ for a backslash`)
+			}
+			i = len(s) - len(rest) - 1
+		default:
+			return "", fmt.Errorf(`invalid escape \%c; write \\ for a backslash`, c)
+		}
+	}
+	return b.String(), nil
+}
+
+// parseStrings reads an array of strings such as ["work", "urgent"].
+func parseStrings(value string) ([]string, error) {
+	rest, ok := strings.CutPrefix(value, "[")
+	if !ok {
+		return nil, errors.New(`expected a list such as ["work", "urgent"]`)
+	}
+	strs := []string{}
+	for {
+		rest = strings.TrimSpace(rest)
+		if after, ok := strings.CutPrefix(rest, "]"); ok {
+			if after = strings.TrimSpace(after); after != "" && !strings.HasPrefix(after, "#") {
+				return nil, fmt.Errorf("unexpected %q after the list", after)
+			}
+			return strs, nil
+		}
+		if !strings.HasPrefix(rest, `"`) {
+			return nil, errors.New(`expected "quoted" tags separated by commas and a closing ]`)
+		}
+		s, after, err := quotedPrefix(rest)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(s) == "" {
+			return nil, errors.New("tags cannot be empty")
+		}
+		strs = append(strs, s)
+		rest = strings.TrimSpace(after)
+		if after, ok := strings.CutPrefix(rest, ","); ok {
+			rest = after
+		} else if !strings.HasPrefix(rest, "]") {
+			return nil, errors.New(`expected "," or "]" after a tag`)
+		}
+	}
+}
+
+// annotate notes the problems in a document above the lines they are on,
+// replacing the notes of an earlier attempt.
+func annotate(doc string, problems []docProblem) string {
+	byLine := map[int][]string{}
+	for _, p := range problems {
+		byLine[p.line] = append(byLine[p.line], problemPrefix+p.msg)
+	}
+	var out []string
+	out = append(out, byLine[0]...)
+	for i, line := range strings.Split(doc, "\n") {
+		if strings.HasPrefix(line, problemPrefix) {
+			continue
+		}
+		out = append(out, byLine[i+1]...)
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind classifies why a command failed.
+type Kind int
+
+const (
+	// Failure is anything that does not fit one of the other kinds.
+	Failure Kind = iota
+	// InvalidInput means the arguments were malformed or out of range.
+	InvalidInput
+	// NotFound means a referenced task or tag does not exist.
+	NotFound
+	// Storage means the list could not be read or written.
+	Storage
+	// Conflict means the change clashes with one made elsewhere, such as by
+	// another process holding the lock or an edit undo cannot reconcile.
+	Conflict
+)
+
+func (k Kind) String() string {
+	return [...]string{"failure", "invalid input", "not found", "storage failure", "conflict"}[k]
+}
+
+// Error is the error type returned by commands.
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+// Error returns the wrapped error's message, or the kind for a sentinel.
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Kind.String()
+	}
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Sentinels for errors.Is, e.g. errors.Is(err, commands.ErrNotFound).
+var (
+	ErrInvalidInput = &Error{Kind: InvalidInput}
+	ErrNotFound     = &Error{Kind: NotFound}
+	ErrStorage      = &Error{Kind: Storage}
+	ErrConflict     = &Error{Kind: Conflict}
+)
+
+// Is matches any *Error of the same kind against the sentinels above.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Err == nil && t.Kind == e.Kind
+}
+
+// Errorf returns an *Error of the given kind. As with fmt.Errorf, %w wraps.
+func Errorf(kind Kind, format string, a ...any) error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, a...)}
+}
+
+// KindOf reports the kind of err, or Failure for errors not from a command.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return Failure
+}
+
+// Question is returned by a command that needs an answer from the user
+// before it can go on. Asking must not happen while the list is locked, so
+// the caller asks it once the failed update has let go of the lock, then
+// runs the command again, which finds the answer; see WithAnswers. If the
+// tasks changed in the meantime, the command fails with a Conflict.
+type Question struct {
+	ask func() error
+}
+
+func (q *Question) Error() string {
+	return "the command needs an answer"
+}
+
+// Ask asks the user and keeps the answer for when the command runs again.
+func (q *Question) Ask() error {
+	return q.ask()
+}
+
+// WithAnswers calls run, which runs a command, as many times as it takes to
+// answer the questions the command asks.
+func WithAnswers(run func() error) error {
+	for {
+		err := run()
+		var q *Question
+		if !errors.As(err, &q) {
+			return err
+		}
+		if err := q.Ask(); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"go-todo-cli/internal/todo"
+	"strings"
+)
+
+// ListName is the list commands work on. Tasks in other lists are neither
+// shown nor found by ID.
+var ListName = todo.DefaultList
+
+// ListsCommand prints the lists with their task counts, marking the active
+// one. Archived lists are only shown when showArchived is set.
+func ListsCommand(showArchived bool, todoList *todo.Todos) error {
+	lists := &Store.Meta().ListSet
+	active := lists.ActiveName()
+	for _, list := range lists.All() {
+		if list.Archived && !showArchived {
+			continue
+		}
+		tasks := todoList.InList(list.Name)
+		done := 0
+		for _, task := range tasks {
+			if task.Completed {
+				done++
+			}
+		}
+		marker := " "
+		if list.Name == active {
+			marker = "*"
+		}
+		state := ""
+		if list.Archived {
+			state = " (archived)"
+		}
+		fmt.Printf("%s %-20s %d/%d done%s\n", marker, list.Name, done, len(tasks), state)
+	}
+	return nil
+}
+
+func CreateListCommand(name string, todoList *todo.Todos) error {
+	if err := Store.Meta().Create(name); err != nil {
+		return listError(err)
+	}
+	fmt.Printf("List '%s' created.\n", strings.TrimSpace(name))
+	return nil
+}
+
+// RenameListCommand renames a list; its tasks move with it.
+func RenameListCommand(oldName, newName string, todoList *todo.Todos) error {
+	if err := Store.Meta().Rename(oldName, newName, todoList); err != nil {
+		return listError(err)
+	}
+	if ListName == oldName {
+		ListName = strings.TrimSpace(newName)
+	}
+	fmt.Printf("List '%s' renamed to '%s'.\n", oldName, strings.TrimSpace(newName))
+	return nil
+}
+
+// ArchiveListCommand archives a list, or restores it when archived is false.
+func ArchiveListCommand(name string, archived bool, todoList *todo.Todos) error {
+	if err := Store.Meta().SetArchived(name, archived); err != nil {
+		return listError(err)
+	}
+	if archived {
+		fmt.Printf("List '%s' archived.\n", name)
+	} else {
+		fmt.Printf("List '%s' restored.\n", name)
+	}
+	return nil
+}
+
+// UseListCommand makes name the list commands work on by default.
+func UseListCommand(name string, todoList *todo.Todos) error {
+	if err := Store.Meta().Use(name); err != nil {
+		return listError(err)
+	}
+	fmt.Printf("Switched to list '%s'.\n", name)
+	return nil
+}
+
+// MoveCommand moves the selected tasks of the current list to another list;
+// see selectTasks.
+func MoveCommand(args []string, todoList *todo.Todos) error {
+	if len(args) != 2 {
+		return Errorf(InvalidInput, "usage: move <task_ids|query> <list>")
+	}
+	target := strings.TrimSpace(args[1])
+	list := Store.Meta().Find(target)
+	if list == nil {
+		return listError(fmt.Errorf("%w: %s", todo.ErrNoSuchList, target))
+	}
+	if list.Archived {
+		return Errorf(InvalidInput, "list '%s' is archived", target)
+	}
+	ids, err := selectTasks(args[0], todoList)
+	if err != nil {
+		return err
+	}
+	if ListName == target {
+		fmt.Printf("%s already in list '%s'.\n", alreadyPrefix(ids), target)
+		return nil
+	}
+	if err := confirmChange("Move", ids, todoList); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		// Subtasks go along with their parent, and may have been selected
+		// as well.
+		for _, moved := range append([]int{id}, todoList.Descendants(id)...) {
+			index := todoList.IndexOf(moved)
+			if (*todoList)[index].InList(target) {
+				continue
+			}
+			if err := todoList.Move(index, target); err != nil {
+				return Errorf(Failure, "%w", err)
+			}
+		}
+	}
+	fmt.Printf("%s moved to list '%s'.\n", capitalize(pluralTasks(ids)), target)
+	return nil
+}
+
+func capitalize(s string) string {
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// checkListWritable refuses to add tasks to an archived list.
+func checkListWritable() error {
+	if list := Store.Meta().Find(ListName); list != nil && list.Archived {
+		return Errorf(InvalidInput, "list '%s' is archived", ListName)
+	}
+	return nil
+}
+
+func listError(err error) error {
+	if errors.Is(err, todo.ErrNoSuchList) {
+		return Errorf(NotFound, "%w", err)
+	}
+	return Errorf(InvalidInput, "%w", err)
+}
+
+func listOf(task todo.Todo) string {
+	if task.List == "" {
+		return todo.DefaultList
+	}
+	return task.List
+}
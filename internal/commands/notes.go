@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"fmt"
+	"go-todo-cli/internal/todo"
+	"strings"
+)
+
+// AnnotateCommand adds a timestamped remark to the selected tasks; see
+// selectTasks.
+func AnnotateCommand(spec, text string, todoList *todo.Todos) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Errorf(InvalidInput, "annotation cannot be empty")
+	}
+	ids, err := selectTasks(spec, todoList)
+	if err != nil {
+		return err
+	}
+	if err := confirmChange("Annotate", ids, todoList); err != nil {
+		return err
+	}
+	now := todo.Now()
+	for _, id := range ids {
+		(*todoList)[todoList.IndexOf(id)].Annotate(text, now)
+	}
+	fmt.Printf("Annotated %s.\n", pluralTasks(ids))
+	return nil
+}
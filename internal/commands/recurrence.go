@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"fmt"
+	"go-todo-cli/internal/todo"
+)
+
+// RecurCommand sets the recurrence rule of a task, or removes it when rule
+// is empty.
+func RecurCommand(id int, rule string, todoList *todo.Todos) error {
+	index, err := taskIndex(fmt.Sprint(id), todoList)
+	if err != nil {
+		return err
+	}
+	task := &(*todoList)[index]
+	newRule := ""
+	if rule != "" {
+		parsed, err := todo.ParseRecurrence(rule)
+		if err != nil {
+			return Errorf(InvalidInput, "%w", err)
+		}
+		newRule = parsed.String()
+	}
+	if newRule == task.Recur {
+		fmt.Printf("Task %d is unchanged.\n", id)
+		return nil
+	}
+	task.RecordChange("Recur", formatRule(task.Recur), formatRule(newRule))
+	task.Recur = newRule
+	if newRule == "" {
+		fmt.Printf("Task %d no longer repeats.\n", id)
+	} else {
+		fmt.Printf("Task %d repeats %s.\n", id, newRule)
+	}
+	return nil
+}
+
+func formatRule(rule string) string {
+	if rule == "" {
+		return "None"
+	}
+	return rule
+}
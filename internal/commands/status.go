@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"fmt"
+	"go-todo-cli/internal/todo"
+	"strings"
+)
+
+// StatusCommand moves the selected tasks to another workflow state; see
+// selectTasks. Without a state it shows each task's state and where it can
+// move. Moving to the done state is the same as CompleteCommand. If any task
+// may not make the move, nothing changes.
+func StatusCommand(spec, state string, todoList *todo.Todos) error {
+	ids, err := selectTasks(spec, todoList)
+	if err != nil {
+		return err
+	}
+	workflow := todo.ActiveWorkflow
+	state = strings.ToLower(strings.TrimSpace(state))
+	if state == "" {
+		for _, id := range ids {
+			from := workflow.StateOf((*todoList)[todoList.IndexOf(id)])
+			fmt.Printf("Task %d is %s; it can move to: %s\n", id, from, strings.Join(workflow.Next(from), ", "))
+		}
+		return nil
+	}
+	if state == workflow.Done {
+		return CompleteCommand([]string{spec}, todoList)
+	}
+	if !workflow.Has(state) {
+		return Errorf(InvalidInput, "unknown status %q (use one of: %s)", state, strings.Join(workflow.States, ", "))
+	}
+	var moving []int
+	for _, id := range ids {
+		from := workflow.StateOf((*todoList)[todoList.IndexOf(id)])
+		if from == state {
+			continue
+		}
+		if !workflow.CanMove(from, state) {
+			return Errorf(InvalidInput, "%w: task %d: %s -> %s", todo.ErrTransition, id, from, state)
+		}
+		moving = append(moving, id)
+	}
+	if len(moving) == 0 {
+		fmt.Printf("%s already %s.\n", alreadyPrefix(ids), state)
+		return nil
+	}
+	if err := confirmChange("Change the status of", moving, todoList); err != nil {
+		return err
+	}
+	for _, id := range moving {
+		index := todoList.IndexOf(id)
+		from := workflow.StateOf((*todoList)[index])
+		if err := todoList.SetStatus(Store.Meta(), index, state); err != nil {
+			return Errorf(InvalidInput, "%w", err)
+		}
+		fmt.Printf("Task %d: %s -> %s.\n", id, from, state)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"go-todo-cli/internal/todo"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SubtaskRules decide what completing or deleting a task does to its
+// subtasks; main sets them from the config file.
+var SubtaskRules todo.SubtaskRules
+
+// AddSubtaskCommand adds a task under the task with ID parentID.
+func AddSubtaskCommand(parentID int, args []string, dueDate *time.Time, priority todo.Priority, todoList *todo.Todos, tags []string) error {
+	if len(args) < 1 || strings.TrimSpace(strings.Join(args, " ")) == "" {
+		return Errorf(InvalidInput, "usage: add --parent <task_id> <task>")
+	}
+	if err := checkListWritable(); err != nil {
+		return err
+	}
+	if _, err := taskIndex(strconv.Itoa(parentID), todoList); err != nil {
+		return err
+	}
+	// taskIndex has made sure the parent exists.
+	if err := todoList.AddSubtask(Store.Meta(), parentID, strings.Join(args, " "), dueDate, priority, tags); err != nil {
+		return Errorf(Failure, "%w", err)
+	}
+	fmt.Printf("Subtask added to task %d.\n", parentID)
+	return nil
+}
+
+func subtaskError(err error) error {
+	if errors.Is(err, todo.ErrHasSubtasks) {
+		return Errorf(InvalidInput, "%w (see subtasks in the config file)", err)
+	}
+	return Errorf(Failure, "%w", err)
+}
@@ -0,0 +1,18 @@
+package commands
+
+import (
+	"fmt"
+	"go-todo-cli/internal/todo"
+)
+
+// NextCommand shows the most urgent open task of the current list.
+func NextCommand(todoList *todo.Todos) error {
+	now := todo.Now()
+	task, ok := todoList.MostUrgent(listed(*todoList, todoList), now)
+	if !ok {
+		fmt.Println("Nothing to do.")
+		return nil
+	}
+	fmt.Printf("Next: task %d, urgency %s\n", task.ID, todo.FormatUrgency(todoList.Urgency(task, now)))
+	return printTasks(todo.Todos{task}, todoList)
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"go-todo-cli/internal/filter"
+	"go-todo-cli/internal/todo"
+	"os"
+)
+
+// TableView is how commands print tables of tasks.
+type TableView struct {
+	Sort    []todo.SortKey
+	Columns []string
+}
+
+// View is the layout of task tables, set from --sort, --columns, --view or
+// the config file. Without sort keys tasks are shown in the order they were
+// added.
+var View = TableView{}
+
+// Output is the format listings are printed in, from --output: "table", or
+// one of the other todo.Formats for scripts and documents.
+var Output = "table"
+
+// printTasks prints tasks, a subset of all, the way View and Output say.
+func printTasks(tasks todo.Todos, all *todo.Todos) error {
+	if len(View.Sort) > 0 {
+		tasks = all.Sort(tasks, View.Sort, todo.Now())
+	}
+	return writeTasks(tasks, all, nil)
+}
+
+// writeTasks prints tasks in View's columns and the Output format. scores
+// are search scores by task ID, or nil outside of search.
+func writeTasks(tasks todo.Todos, all *todo.Todos, scores map[int]float64) error {
+	columns := View.Columns
+	if Output == "table" {
+		if len(columns) == 0 {
+			columns = todo.DefaultColumns
+		}
+		todo.PrintTable(&tasks, *all, columns)
+		return nil
+	}
+	if len(columns) == 0 {
+		// Scripts get every column unless they ask for fewer; Markdown is for
+		// people, who get the table's plus the notes and annotations it has
+		// no room for.
+		columns = todo.Columns
+		if Output == "markdown" {
+			columns = append(append([]string(nil), todo.DefaultColumns...), "notes", "annotations")
+		}
+	}
+	if err := todo.WriteTasks(os.Stdout, Output, tasks, *all, columns, scores); err != nil {
+		return Errorf(Failure, "%w", err)
+	}
+	return nil
+}
+
+// Filter limits the tasks listing commands show, from --filter or the query
+// given to ls. Nil shows every task.
+var Filter *filter.Filter
+
+// listed returns the tasks of view that are in the current list and match
+// Filter.
+func listed(view todo.Todos, all *todo.Todos) todo.Todos {
+	return Filter.Apply(view.InList(ListName), *all)
+}
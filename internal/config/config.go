@@ -0,0 +1,177 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const appName = "todo"
+
+// Config holds the user-tunable settings read from the configuration file.
+type Config struct {
+	// Backend selects how the todo list is stored: "json" (default) or "db".
+	Backend string `json:"backend,omitempty"`
+	// File is the data file to use when neither --file nor TODO_FILE is set
+	// and no per-directory list is found. "~/" is expanded.
+	File string `json:"file,omitempty"`
+	// Discover enables looking for a per-directory list (.todos.json) in the
+	// current directory and its parents. It defaults to true.
+	Discover *bool `json:"discover,omitempty"`
+	// Subtasks sets what completing or deleting a task with subtasks does.
+	Subtasks SubtaskConfig `json:"subtasks,omitempty"`
+	// Workflow replaces the default task states and the moves allowed
+	// between them.
+	Workflow *WorkflowConfig `json:"workflow,omitempty"`
+	// Urgency tunes how urgency scores are computed.
+	Urgency *UrgencyConfig `json:"urgency,omitempty"`
+	// Views are named sort orders and column sets for task tables, picked
+	// with "ls --view". The one named "default" applies otherwise.
+	Views map[string]ViewConfig `json:"views,omitempty"`
+	// ConfirmAbove is how many tasks a command may change at once before
+	// asking for confirmation. It defaults to 5.
+	ConfirmAbove *int `json:"confirm_above,omitempty"`
+}
+
+// ViewConfig uses the syntax of the --sort and --columns flags.
+type ViewConfig struct {
+	Sort    string `json:"sort,omitempty"`
+	Columns string `json:"columns,omitempty"`
+}
+
+// UrgencyConfig overrides urgency coefficients; anything left out keeps its
+// default. Priority is keyed by low, medium and high, Tags by tag and Status
+// by workflow state.
+type UrgencyConfig struct {
+	Priority map[string]float64 `json:"priority,omitempty"`
+	Due      *float64           `json:"due,omitempty"`
+	Age      *float64           `json:"age,omitempty"`
+	Blocked  *float64           `json:"blocked,omitempty"`
+	Tags     map[string]float64 `json:"tags,omitempty"`
+	Status   map[string]float64 `json:"status,omitempty"`
+}
+
+// WorkflowConfig describes the task states. Only States is required:
+// Initial defaults to the first state, Done to "done", Started and Cancelled
+// to "in-progress" and "cancelled" if there are such states, Closed to just
+// Done, and leaving out Transitions allows every move.
+type WorkflowConfig struct {
+	States      []string            `json:"states"`
+	Initial     string              `json:"initial,omitempty"`
+	Done        string              `json:"done,omitempty"`
+	Started     string              `json:"started,omitempty"`
+	Cancelled   string              `json:"cancelled,omitempty"`
+	Closed      []string            `json:"closed,omitempty"`
+	Transitions map[string][]string `json:"transitions,omitempty"`
+}
+
+// SubtaskConfig holds one policy, "block" (default), "cascade" or "orphan",
+// per action.
+type SubtaskConfig struct {
+	OnComplete string `json:"on_complete,omitempty"`
+	OnDelete   string `json:"on_delete,omitempty"`
+}
+
+// Path returns the configuration file location:
+// $XDG_CONFIG_HOME/todo/config.json, defaulting to ~/.config/todo/config.json.
+func Path() string {
+	return filepath.Join(xdgDir("XDG_CONFIG_HOME", ".config"), appName, "config.json")
+}
+
+// DataDir returns where lists are kept by default: $XDG_DATA_HOME/todo,
+// defaulting to ~/.local/share/todo.
+func DataDir() string {
+	return filepath.Join(xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share")), appName)
+}
+
+func xdgDir(env, fallback string) string {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fallback
+	}
+	return filepath.Join(home, fallback)
+}
+
+// Load reads the configuration at path. A missing file yields the defaults.
+func Load(path string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return cfg, nil
+		}
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// DataFileName is the name of the default data file for backend.
+func DataFileName(backend string) string {
+	if backend == "db" {
+		return "todos.db"
+	}
+	return "todos.json"
+}
+
+// ResolveDataFile picks the data file, in order of precedence: the --file
+// flag (which go-arg also fills from TODO_FILE), a per-directory list found
+// by walking up from dir, the config file's "file" setting, a todos.json left
+// in dir by earlier versions, and finally the default under DataDir.
+func (c Config) ResolveDataFile(flagFile, dir string) string {
+	if flagFile != "" {
+		return expandHome(flagFile)
+	}
+	if c.Discover == nil || *c.Discover {
+		if found := discover(dir, "."+DataFileName(c.Backend)); found != "" {
+			return found
+		}
+	}
+	if c.File != "" {
+		return expandHome(c.File)
+	}
+	legacy := filepath.Join(dir, DataFileName(c.Backend))
+	if _, err := os.Stat(legacy); err == nil {
+		return legacy
+	}
+	return filepath.Join(DataDir(), DataFileName(c.Backend))
+}
+
+// discover looks for name in dir and each of its parents, like git does for
+// .git, and returns the first match.
+func discover(dir, name string) string {
+	if dir == "" {
+		return ""
+	}
+	for {
+		candidate := filepath.Join(dir, name)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadMissingFile(t *testing.T) {
+	cfg, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.Backend != "" {
+		t.Errorf("Expected default backend, got %q", cfg.Backend)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"backend": "db", "file": "~/lists/todo.db", "discover": false}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.Backend != "db" || cfg.File != "~/lists/todo.db" || cfg.Discover == nil || *cfg.Discover {
+		t.Errorf("Unexpected config %+v", cfg)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"backend": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Expected error for malformed config, got none")
+	}
+}
+
+func TestXDGPaths(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/xdg/config")
+	t.Setenv("XDG_DATA_HOME", "/xdg/data")
+	if Path() != filepath.Join("/xdg/config", "todo", "config.json") {
+		t.Errorf("Unexpected config path %s", Path())
+	}
+	if DataDir() != filepath.Join("/xdg/data", "todo") {
+		t.Errorf("Unexpected data dir %s", DataDir())
+	}
+
+	// Relative XDG paths are invalid per the spec and ignored.
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", "relative")
+	if DataDir() != filepath.Join(home, ".local", "share", "todo") {
+		t.Errorf("Expected fallback data dir, got %s", DataDir())
+	}
+}
+
+func TestResolveDataFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, "data"))
+
+	project := filepath.Join(home, "project")
+	nested := filepath.Join(project, "src", "pkg")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	plain := filepath.Join(home, "plain")
+	if err := os.MkdirAll(plain, 0755); err != nil {
+		t.Fatal(err)
+	}
+	projectList := filepath.Join(project, ".todos.json")
+	if err := os.WriteFile(projectList, []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	off := false
+	testCases := []struct {
+		name     string
+		cfg      Config
+		flag     string
+		dir      string
+		expected string
+	}{
+		{"flag wins", Config{File: "/cfg.json"}, "/flag.json", nested, "/flag.json"},
+		{"flag expands home", Config{}, "~/flag.json", nested, filepath.Join(home, "flag.json")},
+		{"discovered in parent", Config{File: "/cfg.json"}, "", nested, projectList},
+		{"discovery disabled", Config{File: "/cfg.json", Discover: &off}, "", nested, "/cfg.json"},
+		{"config file", Config{File: "~/cfg.json"}, "", plain, filepath.Join(home, "cfg.json")},
+		{"xdg default", Config{}, "", plain, filepath.Join(home, "data", "todo", "todos.json")},
+		{"xdg default for db", Config{Backend: "db"}, "", plain, filepath.Join(home, "data", "todo", "todos.db")},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.cfg.ResolveDataFile(tc.flag, tc.dir); got != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+
+	legacy := filepath.Join(plain, "todos.json")
+	if err := os.WriteFile(legacy, []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := (Config{}).ResolveDataFile("", plain); got != legacy {
+		t.Errorf("Expected existing todos.json in the current directory to be used, got %s", got)
+	}
+}
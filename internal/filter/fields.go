@@ -0,0 +1,247 @@
+package filter
+
+import (
+	"fmt"
+	"go-todo-cli/internal/todo"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field is a field terms can test, with the modifiers it takes; "" stands
+// for none.
+type field struct {
+	modifiers []string
+	parse     func(modifier, value string, now time.Time) (matcher, error)
+}
+
+var fields = map[string]field{
+	"id":       {[]string{""}, parseID},
+	"task":     {[]string{""}, parseTask},
+	"tag":      {[]string{""}, parseTag},
+	"priority": {[]string{"", "above", "below"}, parsePriority},
+	"status":   {[]string{""}, parseStatus},
+	"due":      {[]string{"", "before", "after"}, parseDue},
+	"created":  {[]string{"", "before", "after"}, parseCreated},
+	"list":     {[]string{""}, parseList},
+	"parent":   {[]string{""}, parseParent},
+	"urgency":  {[]string{"above", "below"}, parseUrgency},
+}
+
+func fieldNames() string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// parseTerm turns a word into a matcher: field[.modifier]:value, or a bare
+// word to look for in descriptions and tags.
+func (p *parser) parseTerm(tok token) (matcher, error) {
+	if tok.valuePos < 0 {
+		word := strings.ToLower(tok.text)
+		return func(task todo.Todo, _ todo.Todos) bool {
+			if strings.Contains(strings.ToLower(task.Task), word) {
+				return true
+			}
+			for _, tag := range task.Tags {
+				if strings.Contains(strings.ToLower(tag), word) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+
+	name, value, _ := strings.Cut(tok.text, ":")
+	fieldName, modifier, hasModifier := strings.Cut(strings.ToLower(name), ".")
+	f, ok := fields[fieldName]
+	if !ok {
+		return nil, p.errorAt(tok.pos, "unknown field %q (use one of: %s)", fieldName, fieldNames())
+	}
+	if !todo.ContainsString(f.modifiers, modifier) {
+		if !hasModifier {
+			return nil, p.errorAt(tok.valuePos-1, "%s needs a modifier: %s", fieldName, strings.Join(f.modifiers, ", "))
+		}
+		return nil, p.errorAt(tok.pos+len(fieldName)+1, "%s has no modifier %q (use %s)", fieldName, modifier, describeModifiers(f.modifiers))
+	}
+	if value == "" {
+		return nil, p.errorAt(tok.valuePos, "expected a value after %q", name+":")
+	}
+	match, err := f.parse(modifier, value, p.now)
+	if err != nil {
+		return nil, p.errorAt(tok.valuePos, "%v", err)
+	}
+	return match, nil
+}
+
+func describeModifiers(modifiers []string) string {
+	var names []string
+	for _, m := range modifiers {
+		if m != "" {
+			names = append(names, m)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+func parseID(_, value string, _ time.Time) (matcher, error) {
+	ids := map[int]bool{}
+	for _, part := range strings.Split(value, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid task ID: %s", part)
+		}
+		ids[id] = true
+	}
+	return func(task todo.Todo, _ todo.Todos) bool { return ids[task.ID] }, nil
+}
+
+func parseTask(_, value string, _ time.Time) (matcher, error) {
+	text := strings.ToLower(value)
+	return func(task todo.Todo, _ todo.Todos) bool {
+		return strings.Contains(strings.ToLower(task.Task), text)
+	}, nil
+}
+
+// parseTag matches a tag exactly, ignoring case. "none" and "any" match
+// untagged and tagged tasks.
+func parseTag(_, value string, _ time.Time) (matcher, error) {
+	switch strings.ToLower(value) {
+	case "none":
+		return func(task todo.Todo, _ todo.Todos) bool { return len(task.Tags) == 0 }, nil
+	case "any":
+		return func(task todo.Todo, _ todo.Todos) bool { return len(task.Tags) > 0 }, nil
+	}
+	return func(task todo.Todo, _ todo.Todos) bool {
+		for _, tag := range task.Tags {
+			if strings.EqualFold(tag, value) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func parsePriority(modifier, value string, _ time.Time) (matcher, error) {
+	priority, err := todo.ParsePriority(strings.ToLower(value))
+	if err != nil {
+		return nil, err
+	}
+	return func(task todo.Todo, _ todo.Todos) bool {
+		switch modifier {
+		case "above":
+			return task.Priority > priority
+		case "below":
+			return task.Priority < priority
+		default:
+			return task.Priority == priority
+		}
+	}, nil
+}
+
+// parseStatus matches a workflow state, or "open", "closed" or "blocked".
+func parseStatus(_, value string, _ time.Time) (matcher, error) {
+	state := strings.ToLower(value)
+	switch state {
+	case "open":
+		return func(task todo.Todo, _ todo.Todos) bool { return !task.IsClosed() }, nil
+	case "closed":
+		return func(task todo.Todo, _ todo.Todos) bool { return task.IsClosed() }, nil
+	case "blocked":
+		return func(task todo.Todo, all todo.Todos) bool { return all.IsBlocked(task) }, nil
+	}
+	workflow := todo.ActiveWorkflow
+	if !workflow.Has(state) {
+		return nil, fmt.Errorf("unknown status %q (use one of: %s, open, closed, blocked)", value, strings.Join(workflow.States, ", "))
+	}
+	return func(task todo.Todo, _ todo.Todos) bool { return todo.ActiveWorkflow.StateOf(task) == state }, nil
+}
+
+// parseDue compares due dates by day. "none" and "any" match tasks without
+// and with a due date.
+func parseDue(modifier, value string, now time.Time) (matcher, error) {
+	switch strings.ToLower(value) {
+	case "none", "any":
+		if modifier != "" {
+			return nil, fmt.Errorf("due.%s needs a date", modifier)
+		}
+		want := strings.EqualFold(value, "any")
+		return func(task todo.Todo, _ todo.Todos) bool { return (task.DueDate != nil) == want }, nil
+	}
+	day, err := parseDay(value, now)
+	if err != nil {
+		return nil, err
+	}
+	return func(task todo.Todo, _ todo.Todos) bool {
+		due, ok := task.DueDay(now)
+		return ok && compareDay(modifier, due, day)
+	}, nil
+}
+
+func parseCreated(modifier, value string, now time.Time) (matcher, error) {
+	day, err := parseDay(value, now)
+	if err != nil {
+		return nil, err
+	}
+	return func(task todo.Todo, _ todo.Todos) bool {
+		return task.CreatedAt != nil && compareDay(modifier, todo.CalendarDay(task.CreatedAt.In(now.Location())), day)
+	}, nil
+}
+
+func parseList(_, value string, _ time.Time) (matcher, error) {
+	return func(task todo.Todo, _ todo.Todos) bool { return task.InList(value) }, nil
+}
+
+// parseParent matches the subtasks of a task, or with "none" top-level
+// tasks.
+func parseParent(_, value string, _ time.Time) (matcher, error) {
+	if strings.EqualFold(value, "none") {
+		return func(task todo.Todo, _ todo.Todos) bool { return task.ParentID == 0 }, nil
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		return nil, fmt.Errorf("invalid task ID: %s", value)
+	}
+	return func(task todo.Todo, _ todo.Todos) bool { return task.ParentID == id }, nil
+}
+
+func parseUrgency(modifier, value string, now time.Time) (matcher, error) {
+	limit, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number: %s", value)
+	}
+	return func(task todo.Todo, all todo.Todos) bool {
+		urgency := all.Urgency(task, now)
+		if modifier == "above" {
+			return urgency > limit
+		}
+		return urgency < limit
+	}, nil
+}
+
+// parseDay resolves a date the way --due does and returns its day.
+func parseDay(value string, now time.Time) (time.Time, error) {
+	t, err := todo.ParseDueDate(value, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return todo.CalendarDay(t), nil
+}
+
+func compareDay(modifier string, day, than time.Time) bool {
+	switch modifier {
+	case "before":
+		return day.Before(than)
+	case "after":
+		return day.After(than)
+	default:
+		return day.Equal(than)
+	}
+}
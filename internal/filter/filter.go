@@ -0,0 +1,185 @@
+// Package filter implements the query language used to pick tasks, as in
+//
+//	priority:high and tag:work and due.before:friday and not status:done
+//
+// A query is made of terms combined with "and", "or", "not" and parentheses.
+// Terms next to each other are joined with "and", and "and" binds tighter
+// than "or". A term is either field:value, field.modifier:value, or a bare
+// word, which matches tasks whose description or tags contain it. Values
+// with spaces are quoted: due.before:"next monday".
+package filter
+
+import (
+	"fmt"
+	"go-todo-cli/internal/todo"
+	"strings"
+	"time"
+)
+
+// Error is a query that does not parse, with the position of the problem.
+type Error struct {
+	Query string
+	Pos   int // byte offset into Query
+	Msg   string
+}
+
+// Error reports the column of the problem and points at it under the query.
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid filter at column %d: %s\n  %s\n  %s^", e.Pos+1, e.Msg, e.Query, strings.Repeat(" ", e.Pos))
+}
+
+type matcher func(task todo.Todo, all todo.Todos) bool
+
+// Filter is a parsed query.
+type Filter struct {
+	match matcher
+}
+
+// Parse parses query. Relative dates in it, such as "friday", are resolved
+// against now. An empty query matches every task.
+func Parse(query string, now time.Time) (*Filter, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{query: query, tokens: tokens, now: now}
+	if p.peek().kind == tokEOF {
+		return &Filter{match: func(todo.Todo, todo.Todos) bool { return true }}, nil
+	}
+	match, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorAt(tok.pos, "unexpected %s", describe(tok))
+	}
+	return &Filter{match: match}, nil
+}
+
+// Match reports whether task matches the filter. Blocked state and urgency
+// are worked out from all.
+func (f *Filter) Match(task todo.Todo, all todo.Todos) bool {
+	return f == nil || f.match(task, all)
+}
+
+// Apply returns the tasks of view that match the filter.
+func (f *Filter) Apply(view, all todo.Todos) todo.Todos {
+	matched := todo.Todos{}
+	for _, task := range view {
+		if f.Match(task, all) {
+			matched = append(matched, task)
+		}
+	}
+	return matched
+}
+
+// And returns a filter matching the tasks both f and g match. Either may be
+// nil, which matches everything.
+func (f *Filter) And(g *Filter) *Filter {
+	if f == nil {
+		return g
+	}
+	if g == nil {
+		return f
+	}
+	return &Filter{match: func(task todo.Todo, all todo.Todos) bool {
+		return f.match(task, all) && g.match(task, all)
+	}}
+}
+
+type parser struct {
+	query  string
+	tokens []token
+	next   int
+	now    time.Time
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	tok := p.tokens[p.next]
+	if tok.kind != tokEOF {
+		p.next++
+	}
+	return tok
+}
+
+func (p *parser) errorAt(pos int, format string, args ...any) error {
+	return &Error{Query: p.query, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// parseOr parses: and-expression {"or" and-expression}.
+func (p *parser) parseOr() (matcher, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		p.advance()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(task todo.Todo, all todo.Todos) bool { return l(task, all) || right(task, all) }
+	}
+	return left, nil
+}
+
+// parseAnd parses: unary {["and"] unary}.
+func (p *parser) parseAnd() (matcher, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek().kind {
+		case tokAnd:
+			p.advance()
+		case tokWord, tokNot, tokLParen:
+		default:
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(task todo.Todo, all todo.Todos) bool { return l(task, all) && right(task, all) }
+	}
+}
+
+// parseUnary parses: "not" unary | "(" or-expression ")" | term.
+func (p *parser) parseUnary() (matcher, error) {
+	tok := p.advance()
+	switch tok.kind {
+	case tokNot:
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(task todo.Todo, all todo.Todos) bool { return !inner(task, all) }, nil
+	case tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.advance(); closing.kind != tokRParen {
+			return nil, p.errorAt(closing.pos, "expected \")\" to close the \"(\" at column %d, found %s", tok.pos+1, describe(closing))
+		}
+		return inner, nil
+	case tokWord:
+		return p.parseTerm(tok)
+	default:
+		return nil, p.errorAt(tok.pos, "expected a term, found %s", describe(tok))
+	}
+}
+
+func describe(tok token) string {
+	if tok.kind == tokWord {
+		return fmt.Sprintf("%q", tok.text)
+	}
+	return tok.kind.String()
+}
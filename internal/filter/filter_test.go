@@ -0,0 +1,140 @@
+package filter
+
+import (
+	"errors"
+	"go-todo-cli/internal/todo"
+	"testing"
+	"time"
+)
+
+// now is a Wednesday.
+var now = time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC)
+
+func day(s string) *time.Time {
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return &d
+}
+
+var tasks = todo.Todos{
+	{ID: 1, Task: "Write report", Priority: todo.High, Tags: []string{"work"}, DueDate: day("2024-03-07"), CreatedAt: day("2024-01-10")},
+	{ID: 2, Task: "Buy milk", Tags: []string{"home", "shopping"}, CreatedAt: day("2024-03-01")},
+	{ID: 3, Task: "Plan offsite", Priority: todo.Medium, Tags: []string{"Work"}, DueDate: day("2024-03-12"), List: "work", Status: "in-progress"},
+	{ID: 4, Task: "File taxes", Priority: todo.High, DueDate: day("2024-03-01"), Completed: true},
+	{ID: 5, Task: "Review (draft) report", DueDate: day("2024-03-08"), DependsOn: []int{1}, ParentID: 1},
+}
+
+func TestParseAndMatch(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "1, 2, 3, 4, 5"},
+		{"priority:high", "1, 4"},
+		{"priority:high and tag:work", "1"},
+		{"priority:high and tag:work and due.before:friday and not status:done", "1"},
+		{"tag:work", "1, 3"},
+		{"tag:none", "4, 5"},
+		{"report", "1, 5"},
+		{"milk or taxes", "2, 4"},
+		{"report not id:5", "1"},
+		{"priority.above:low and not status:closed", "1, 3"},
+		{"priority.below:high", "2, 3, 5"},
+		{"status:in-progress", "3"},
+		{"status:todo", "1, 2, 5"},
+		{"status:blocked", "5"},
+		{"status:open due:any", "1, 3, 5"},
+		{"due:none", "2"},
+		{"due:tomorrow", "1"},
+		{"due.after:today and due.before:eow", "1, 5"},
+		{"created.before:2024-02-01", "1"},
+		{"list:work", "3"},
+		{"list:default", "1, 2, 4, 5"},
+		{"parent:1", "5"},
+		{"parent:none and (tag:home or tag:work)", "1, 2, 3"},
+		{"id:2,4", "2, 4"},
+		{`task:"(draft)"`, "5"},
+		{`"buy milk"`, "2"},
+		{"urgency.above:14", "1"},
+		{"NOT Tag:work AND priority:HIGH", "4"},
+		{"tag:work or tag:home and priority:low", "1, 2, 3"},
+		{"(tag:work or tag:home) and priority:low", "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			f, err := Parse(tt.query, now)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var ids []int
+			for _, task := range f.Apply(tasks, tasks) {
+				ids = append(ids, task.ID)
+			}
+			if got := todo.FormatIDs(ids); got != tt.want && !(tt.want == "" && got == "None") {
+				t.Errorf("Expected tasks %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		pos   int
+		msg   string
+	}{
+		{"colour:red", 0, `unknown field "colour"`},
+		{"priority:high and tag:", 22, `expected a value after "tag:"`},
+		{"priority:urgent", 9, "invalid priority: urgent"},
+		{"tag:work and", 12, "expected a term, found end of filter"},
+		{"(tag:work or tag:home", 21, `expected ")" to close the "(" at column 1, found end of filter`},
+		{"tag:work)", 8, `unexpected ")"`},
+		{"and tag:work", 0, `expected a term, found "and"`},
+		{`task:"unfinished`, 5, "unterminated quote"},
+		{"due.soon:friday", 4, `due has no modifier "soon"`},
+		{"urgency:5", 7, "urgency needs a modifier"},
+		{"status:someday", 7, `unknown status "someday"`},
+		{"due.before:someday", 11, "invalid due date"},
+		{"not not", 7, "expected a term, found end of filter"},
+		{"id:x", 3, "invalid task ID: x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := Parse(tt.query, now)
+			var parseErr *Error
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Expected a parse error, got %v", err)
+			}
+			if parseErr.Pos != tt.pos {
+				t.Errorf("Expected the error at %d, got %d (%v)", tt.pos, parseErr.Pos, err)
+			}
+			if len(parseErr.Msg) < len(tt.msg) || parseErr.Msg[:len(tt.msg)] != tt.msg {
+				t.Errorf("Expected a message starting %q, got %q", tt.msg, parseErr.Msg)
+			}
+		})
+	}
+}
+
+func TestErrorPointsAtColumn(t *testing.T) {
+	_, err := Parse("tag:work and colour:red", now)
+	want := "invalid filter at column 14: unknown field \"colour\" (use one of: created, due, id, list, parent, priority, status, tag, task, urgency)\n" +
+		"  tag:work and colour:red\n" +
+		"               ^"
+	if err == nil || err.Error() != want {
+		t.Errorf("Expected\n%s\ngot\n%v", want, err)
+	}
+}
+
+func TestAnd(t *testing.T) {
+	work, _ := Parse("tag:work", now)
+	high, _ := Parse("priority:high", now)
+	var none *Filter
+	if got := work.And(high).Apply(tasks, tasks); len(got) != 1 || got[0].ID != 1 {
+		t.Errorf("Expected only task 1, got %v", got)
+	}
+	if got := none.And(work).Apply(tasks, tasks); len(got) != 2 {
+		t.Errorf("Expected a nil filter to match everything, got %v", got)
+	}
+}
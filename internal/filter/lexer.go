@@ -0,0 +1,102 @@
+package filter
+
+import "strings"
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokEOF:
+		return "end of filter"
+	case tokAnd:
+		return `"and"`
+	case tokOr:
+		return `"or"`
+	case tokNot:
+		return `"not"`
+	case tokLParen:
+		return `"("`
+	case tokRParen:
+		return `")"`
+	default:
+		return "term"
+	}
+}
+
+// token is a lexeme of a query. For words, text has quotes removed and
+// valuePos is where the text after the first colon starts.
+type token struct {
+	kind     tokenKind
+	text     string
+	pos      int
+	valuePos int
+}
+
+// lex splits a query into tokens. Words run up to whitespace or a
+// parenthesis; double quotes, anywhere in a word, group text with spaces or
+// parentheses in it.
+func lex(query string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(query) {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		default:
+			start := i
+			valuePos := -1
+			var text strings.Builder
+			quoted := false
+			for i < len(query) && !strings.ContainsRune(" \t\n()", rune(query[i])) {
+				if query[i] == ':' && valuePos < 0 {
+					text.WriteByte(':')
+					i++
+					valuePos = i
+					continue
+				}
+				if query[i] != '"' {
+					text.WriteByte(query[i])
+					i++
+					continue
+				}
+				quoted = true
+				end := strings.IndexByte(query[i+1:], '"')
+				if end < 0 {
+					return nil, &Error{Query: query, Pos: i, Msg: "unterminated quote"}
+				}
+				text.WriteString(query[i+1 : i+1+end])
+				i += end + 2
+			}
+			tok := token{kind: tokWord, text: text.String(), pos: start, valuePos: valuePos}
+			if !quoted {
+				switch strings.ToLower(tok.text) {
+				case "and":
+					tok.kind = tokAnd
+				case "or":
+					tok.kind = tokOr
+				case "not":
+					tok.kind = tokNot
+				}
+			}
+			tokens = append(tokens, tok)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(query)}), nil
+}
@@ -0,0 +1,430 @@
+// Package search implements full-text search over tasks: their description,
+// tags, notes and annotations. A query is made of words, all of which must
+// match, in any of these forms:
+//
+//	report         the word, a word starting with it or, for words of three
+//	               letters or more, containing it, or a word within a typo
+//	               or two of it
+//	rep*           words starting with "rep", and no others
+//	"final report" the words next to each other, in this order
+//	/q[1-4]/       a regular expression, ignoring case
+//
+// Results are ranked by relevance: rarer words count for more, a match in
+// the description more than one in tags, and those more than one in notes.
+package search
+
+import (
+	"go-todo-cli/internal/todo"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Field weights: where a word is found says how much the task is about it.
+var fieldWeights = map[string]float64{"task": 3, "tags": 2, "notes": 1, "annotation": 1}
+
+// token is a word of a field, lowercased, with where it is in the text.
+type token struct {
+	term       string
+	start, end int
+}
+
+// field is one searchable text of a task.
+type field struct {
+	name   string
+	text   string
+	tokens []token
+}
+
+type document struct {
+	task   todo.Todo
+	fields []field
+}
+
+// posting is an occurrence of a term: the document, the field within it and
+// the token within that.
+type posting struct {
+	doc, field, pos int
+}
+
+// Index is an inverted index of tasks, mapping each word to where it occurs.
+type Index struct {
+	docs     []document
+	postings map[string][]posting
+	terms    []string // sorted, for prefix and fuzzy lookups
+}
+
+// NewIndex indexes tasks.
+func NewIndex(tasks todo.Todos) *Index {
+	ix := &Index{postings: map[string][]posting{}}
+	for d, task := range tasks {
+		doc := document{task: task}
+		doc.fields = append(doc.fields, newField("task", task.Task))
+		if len(task.Tags) > 0 {
+			doc.fields = append(doc.fields, newField("tags", strings.Join(task.Tags, ", ")))
+		}
+		if task.Notes != "" {
+			doc.fields = append(doc.fields, newField("notes", task.Notes))
+		}
+		for _, a := range task.Annotations {
+			doc.fields = append(doc.fields, newField("annotation", a.Text))
+		}
+		for f, fld := range doc.fields {
+			for pos, tok := range fld.tokens {
+				ix.postings[tok.term] = append(ix.postings[tok.term], posting{d, f, pos})
+			}
+		}
+		ix.docs = append(ix.docs, doc)
+	}
+	for term := range ix.postings {
+		ix.terms = append(ix.terms, term)
+	}
+	sort.Strings(ix.terms)
+	return ix
+}
+
+func newField(name, text string) field {
+	return field{name: name, text: text, tokens: tokenize(text)}
+}
+
+// tokenize splits text into words: runs of letters and digits.
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, token{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return tokens
+}
+
+// Result is a task that matched, with its relevance and what matched.
+type Result struct {
+	Task    todo.Todo
+	Score   float64
+	Matches []Match
+}
+
+// Match is a field of a task that matched, with the byte ranges of Text
+// that did, in order and not overlapping.
+type Match struct {
+	Field string // task, tags, notes or annotation
+	Text  string
+	Spans [][2]int
+}
+
+// hit is one place a clause matched, weighted by how closely.
+type hit struct {
+	field      int
+	start, end int
+	weight     float64
+}
+
+// Search returns the tasks matching every clause of q, most relevant first.
+func (ix *Index) Search(q *Query) []Result {
+	if len(q.clauses) == 0 {
+		return nil
+	}
+	scores := map[int]float64{}
+	hits := map[int][]hit{}
+	for i, c := range q.clauses {
+		matched := c.match(ix)
+		for d := range scores {
+			if _, ok := matched[d]; !ok {
+				delete(scores, d)
+			}
+		}
+		idf := math.Log(1 + (float64(len(ix.docs))-float64(len(matched))+0.5)/(float64(len(matched))+0.5))
+		for d, docHits := range matched {
+			if _, ok := scores[d]; !ok && i > 0 {
+				continue
+			}
+			scores[d] += idf * saturate(ix.weigh(d, docHits))
+			hits[d] = append(hits[d], docHits...)
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for d, score := range scores {
+		results = append(results, Result{Task: ix.docs[d].task, Score: score, Matches: ix.matches(d, hits[d])})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Task.ID < results[j].Task.ID
+	})
+	return results
+}
+
+// weigh adds up a clause's hits in a document by field.
+func (ix *Index) weigh(d int, hits []hit) float64 {
+	total := 0.0
+	for _, h := range hits {
+		total += h.weight * fieldWeights[ix.docs[d].fields[h.field].name]
+	}
+	return total
+}
+
+// saturate makes each further hit of the same clause count for less, as in
+// BM25, so that a word repeated in long notes does not drown out the rest.
+func saturate(weight float64) float64 {
+	const k = 1.2
+	return weight * (k + 1) / (weight + k)
+}
+
+// matches groups a document's hits by field, merging overlapping spans.
+func (ix *Index) matches(d int, hits []hit) []Match {
+	byField := map[int][][2]int{}
+	for _, h := range hits {
+		byField[h.field] = append(byField[h.field], [2]int{h.start, h.end})
+	}
+	var matches []Match
+	for f, fld := range ix.docs[d].fields {
+		spans := byField[f]
+		if len(spans) == 0 {
+			continue
+		}
+		sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+		merged := [][2]int{spans[0]}
+		for _, s := range spans[1:] {
+			if last := &merged[len(merged)-1]; s[0] <= last[1] {
+				last[1] = max(last[1], s[1])
+			} else {
+				merged = append(merged, s)
+			}
+		}
+		matches = append(matches, Match{Field: fld.name, Text: fld.text, Spans: merged})
+	}
+	return matches
+}
+
+// Highlight applies mark to the matched spans of text.
+func Highlight(text string, spans [][2]int, mark func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, s := range spans {
+		b.WriteString(text[last:s[0]])
+		b.WriteString(mark(text[s[0]:s[1]]))
+		last = s[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// Fragment returns the line of m.Text holding its first match, cut down to
+// about width bytes around it, with the matches in it highlighted.
+func Fragment(m Match, width int, mark func(string) string) string {
+	first := m.Spans[0]
+	start := strings.LastIndexByte(m.Text[:first[0]], '\n') + 1
+	end := len(m.Text)
+	if nl := strings.IndexByte(m.Text[first[1]:], '\n'); nl >= 0 {
+		end = first[1] + nl
+	}
+	prefix, suffix := "", ""
+	if end-start > width {
+		from := max(start, first[0]-width/3)
+		to := min(end, from+width)
+		from = max(start, min(from, to-width))
+		// Cut between words where there is a space to cut at.
+		if from > start {
+			if sp := strings.IndexByte(m.Text[from:first[0]], ' '); sp >= 0 {
+				from += sp + 1
+			}
+		}
+		if to < end {
+			if sp := strings.LastIndexByte(m.Text[first[1]:to], ' '); sp >= 0 {
+				to = first[1] + sp
+			}
+		}
+		for from > start && !utf8.RuneStart(m.Text[from]) {
+			from--
+		}
+		for to < end && !utf8.RuneStart(m.Text[to]) {
+			to++
+		}
+		if from > start {
+			prefix = "..."
+		}
+		if to < end {
+			suffix = "..."
+		}
+		start, end = from, to
+	}
+
+	var spans [][2]int
+	for _, s := range m.Spans {
+		if s[1] > start && s[0] < end {
+			spans = append(spans, [2]int{max(s[0], start) - start, min(s[1], end) - start})
+		}
+	}
+	return prefix + Highlight(m.Text[start:end], spans, mark) + suffix
+}
+
+// clause kinds.
+const (
+	wordClause = iota
+	prefixClause
+	phraseClause
+	regexClause
+)
+
+type clause struct {
+	kind  int
+	words []string // one for words and prefixes
+	re    *regexp.Regexp
+}
+
+// match finds the documents a clause matches, with the hits in each.
+func (c clause) match(ix *Index) map[int][]hit {
+	matched := map[int][]hit{}
+	addTerm := func(term string, weight float64) {
+		for _, p := range ix.postings[term] {
+			tok := ix.docs[p.doc].fields[p.field].tokens[p.pos]
+			matched[p.doc] = append(matched[p.doc], hit{p.field, tok.start, tok.end, weight})
+		}
+	}
+
+	switch c.kind {
+	case wordClause:
+		word := c.words[0]
+		maxEdits := allowedEdits(word)
+		for _, term := range ix.terms {
+			if weight := wordWeight(word, term, maxEdits); weight > 0 {
+				addTerm(term, weight)
+			}
+		}
+	case prefixClause:
+		prefix := c.words[0]
+		for i := sort.SearchStrings(ix.terms, prefix); i < len(ix.terms) && strings.HasPrefix(ix.terms[i], prefix); i++ {
+			weight := 0.8
+			if ix.terms[i] == prefix {
+				weight = 1
+			}
+			addTerm(ix.terms[i], weight)
+		}
+	case phraseClause:
+		for _, p := range ix.postings[c.words[0]] {
+			tokens := ix.docs[p.doc].fields[p.field].tokens
+			if p.pos+len(c.words) > len(tokens) {
+				continue
+			}
+			ok := true
+			for i, word := range c.words[1:] {
+				if tokens[p.pos+1+i].term != word {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				last := tokens[p.pos+len(c.words)-1]
+				matched[p.doc] = append(matched[p.doc], hit{p.field, tokens[p.pos].start, last.end, 1})
+			}
+		}
+	case regexClause:
+		for d, doc := range ix.docs {
+			for f, fld := range doc.fields {
+				for _, loc := range c.re.FindAllStringIndex(fld.text, -1) {
+					if loc[0] < loc[1] {
+						matched[d] = append(matched[d], hit{f, loc[0], loc[1], 1})
+					}
+				}
+			}
+		}
+	}
+	return matched
+}
+
+// wordWeight is how closely term matches a plain word of a query: fully if
+// they are the same, less if the word starts term, as with rep*, or is
+// within maxEdits typos of it, and least if term merely contains it. Words
+// shorter than three letters are not looked for inside others, which would
+// match nearly everything. It is 0 for no match.
+func wordWeight(word, term string, maxEdits int) float64 {
+	switch {
+	case term == word:
+		return 1
+	case strings.HasPrefix(term, word):
+		return 0.8
+	}
+	weight := 0.0
+	if maxEdits > 0 {
+		if d := distance(word, term, maxEdits); d <= maxEdits {
+			weight = 1 - 0.3*float64(d)
+		}
+	}
+	if weight < 0.5 && utf8.RuneCountInString(word) >= 3 && strings.Contains(term, word) {
+		weight = 0.5
+	}
+	return weight
+}
+
+// allowedEdits is how many typos a word may have and still match: none for
+// short words, which would otherwise match too much.
+func allowedEdits(word string) int {
+	switch n := utf8.RuneCountInString(word); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// distance is the number of single-character insertions, deletions,
+// substitutions and swaps of neighbours that turn a into b, or limit+1 if
+// it is more than limit.
+func distance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > limit {
+		return limit + 1
+	}
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		best := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			best = min(best, cur[j])
+		}
+		if best > limit {
+			return limit + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return min(prev[len(rb)], limit+1)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
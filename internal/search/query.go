@@ -0,0 +1,90 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Query is a parsed search query.
+type Query struct {
+	clauses []clause
+}
+
+// ParseQuery parses a query; see the package documentation.
+func ParseQuery(s string) (*Query, error) {
+	q := &Query{}
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote at column %d", i+1)
+			}
+			q.addWords(s[i+1:i+1+end], false)
+			i += end + 2
+		case c == '/':
+			pattern, next, err := readRegex(s, i)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("invalid regular expression /%s/: %w", pattern, err)
+			}
+			re := regexp.MustCompile("(?i)" + pattern)
+			q.clauses = append(q.clauses, clause{kind: regexClause, re: re})
+			i = next
+		default:
+			end := strings.IndexAny(s[i:], " \t\n")
+			if end < 0 {
+				end = len(s) - i
+			}
+			word := s[i : i+end]
+			prefix := strings.HasSuffix(word, "*")
+			q.addWords(strings.TrimSuffix(word, "*"), prefix)
+			i += end
+		}
+	}
+	if len(q.clauses) == 0 {
+		return nil, fmt.Errorf("nothing to search for in %q", s)
+	}
+	return q, nil
+}
+
+// addWords adds a clause for text: a word, a prefix, or a phrase when it has
+// several words, as "e-mail" does. Text without words adds nothing.
+func (q *Query) addWords(text string, prefix bool) {
+	var words []string
+	for _, tok := range tokenize(text) {
+		words = append(words, tok.term)
+	}
+	switch {
+	case len(words) == 0:
+	case len(words) > 1:
+		q.clauses = append(q.clauses, clause{kind: phraseClause, words: words})
+	case prefix:
+		q.clauses = append(q.clauses, clause{kind: prefixClause, words: words})
+	default:
+		q.clauses = append(q.clauses, clause{kind: wordClause, words: words})
+	}
+}
+
+// readRegex reads a /pattern/ starting at s[start], where \/ stands for a
+// slash, and returns the pattern and where it ended.
+func readRegex(s string, start int) (string, int, error) {
+	var pattern strings.Builder
+	for i := start + 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == '/':
+			pattern.WriteByte('/')
+			i++
+		case s[i] == '/':
+			return pattern.String(), i + 1, nil
+		default:
+			pattern.WriteByte(s[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated regular expression at column %d", start+1)
+}
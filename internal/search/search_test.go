@@ -0,0 +1,99 @@
+package search
+
+import (
+	"go-todo-cli/internal/todo"
+	"reflect"
+	"testing"
+)
+
+func testTasks() todo.Todos {
+	return todo.Todos{
+		{ID: 1, Task: "Write final report", Tags: []string{"work"}},
+		{ID: 2, Task: "Buy milk", Notes: "The report said to buy oat milk"},
+		{ID: 3, Task: "Call vendor", Tags: []string{"reporting"}, Annotations: []todo.Annotation{{Text: "left a voicemail"}}},
+		{ID: 4, Task: "Plan Q3 offsite", Notes: "Budget for q4 as well"},
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ix := NewIndex(testTasks())
+	tests := []struct {
+		query string
+		ids   []int
+	}{
+		{"report", []int{1, 3, 2}},   // the description ranks above tags and notes
+		{"rep", []int{1, 3, 2}},      // words starting with a word match too...
+		{"port", []int{1, 3, 2}},     // ...as do words containing one
+		{"reprot", []int{1, 2}},      // one typo
+		{"rep*", []int{1, 3, 2}},     // prefix, in tags too
+		{`"final report"`, []int{1}}, // phrase
+		{`"report final"`, nil},      // words in the wrong order
+		{"report milk", []int{2}},    // every word must match
+		{"voicemail", []int{3}},      // annotations
+		{"/q[34]/", []int{4}},        // regex, ignoring case
+		{"milk", []int{2}},           // short words need an exact match...
+		{"mlk", nil},                 // ...rather than a typo
+		{"e-mail", nil},              // words joined by punctuation are a phrase
+		{"oat-milk", []int{2}},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", tt.query, err)
+		}
+		var ids []int
+		for _, r := range ix.Search(q) {
+			ids = append(ids, r.Task.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.ids) {
+			t.Errorf("Search(%q) = %v, want %v", tt.query, ids, tt.ids)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, query := range []string{`"final`, "/q[1-4", "/(/", "", "*"} {
+		if _, err := ParseQuery(query); err == nil {
+			t.Errorf("ParseQuery(%q): expected an error", query)
+		}
+	}
+}
+
+func TestHighlightAndFragment(t *testing.T) {
+	mark := func(s string) string { return "[" + s + "]" }
+	q, _ := ParseQuery("milk")
+	results := NewIndex(testTasks()).Search(q)
+	if len(results) != 1 || len(results[0].Matches) != 2 {
+		t.Fatalf("Expected matches in the description and notes, got %+v", results)
+	}
+	if got := Highlight(results[0].Matches[0].Text, results[0].Matches[0].Spans, mark); got != "Buy [milk]" {
+		t.Errorf("Highlight = %q", got)
+	}
+
+	m := Match{Text: "first line\nsome words before the match and a good many words after it\nlast", Spans: [][2]int{{33, 38}}}
+	if got := Fragment(m, 30, mark); got != "...the [match] and a good..." {
+		t.Errorf("Fragment = %q", got)
+	}
+	if got := Fragment(m, 100, mark); got != "some words before the [match] and a good many words after it" {
+		t.Errorf("Fragment = %q", got)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"report", "report", 0},
+		{"report", "reprot", 1}, // swapped neighbours count once
+		{"report", "rport", 1},
+		{"report", "reports", 1},
+		{"report", "rapport", 2},
+		{"report", "vendor", 3},
+	}
+	for _, tt := range tests {
+		if got := distance(tt.a, tt.b, 2); got != tt.want {
+			t.Errorf("distance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package todo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AgendaSection is one group of tasks in the agenda.
+type AgendaSection struct {
+	Name  string
+	Tasks Todos
+}
+
+// Agenda names, in the order Agenda returns them.
+const (
+	AgendaOverdue  = "Overdue"
+	AgendaToday    = "Today"
+	AgendaTomorrow = "Tomorrow"
+	AgendaThisWeek = "This week"
+	AgendaLater    = "Later"
+	AgendaNoDate   = "No date"
+)
+
+// Agenda groups the open tasks by when they are due, relative to now: overdue,
+// today, tomorrow, the rest of the week (which ends on Sunday), later, and
+// without a due date. Every group is returned, even when empty; tasks are
+// sorted by due date within each.
+func (t Todos) Agenda(now time.Time) []AgendaSection {
+	names := []string{AgendaOverdue, AgendaToday, AgendaTomorrow, AgendaThisWeek, AgendaLater, AgendaNoDate}
+	groups := map[string]Todos{}
+	today := CalendarDay(now)
+	endOfWeek := today.AddDate(0, 0, (7-int(today.Weekday()))%7)
+	for _, task := range t {
+		if task.IsClosed() {
+			continue
+		}
+		day, ok := task.DueDay(now)
+		var name string
+		switch {
+		case !ok:
+			name = AgendaNoDate
+		case task.IsOverdue(now):
+			name = AgendaOverdue
+		case day.Equal(today):
+			name = AgendaToday
+		case day.Equal(today.AddDate(0, 0, 1)):
+			name = AgendaTomorrow
+		case !day.After(endOfWeek):
+			name = AgendaThisWeek
+		default:
+			name = AgendaLater
+		}
+		groups[name] = append(groups[name], task)
+	}
+
+	sections := make([]AgendaSection, len(names))
+	for i, name := range names {
+		tasks := groups[name]
+		sort.SliceStable(tasks, func(a, b int) bool {
+			if tasks[a].DueDate == nil || tasks[b].DueDate == nil {
+				return false
+			}
+			return tasks[a].DueDate.Before(*tasks[b].DueDate)
+		})
+		sections[i] = AgendaSection{Name: name, Tasks: tasks}
+	}
+	return sections
+}
+
+// PrintAgenda prints the non-empty sections, one line per task. Whether a task
+// is blocked is worked out from all.
+func PrintAgenda(sections []AgendaSection, all Todos) {
+	now := Now()
+	maxTaskLength := 0
+	empty := true
+	for _, section := range sections {
+		for _, task := range section.Tasks {
+			maxTaskLength = max(maxTaskLength, len(task.Task))
+			empty = false
+		}
+	}
+	if empty {
+		fmt.Println("Nothing to do.")
+		return
+	}
+
+	first := true
+	for _, section := range sections {
+		if len(section.Tasks) == 0 {
+			continue
+		}
+		if !first {
+			fmt.Println()
+		}
+		first = false
+		heading := fmt.Sprintf("%s (%d)", section.Name, len(section.Tasks))
+		switch section.Name {
+		case AgendaOverdue:
+			heading = colorize(ansiRed, heading)
+		case AgendaToday, AgendaTomorrow:
+			heading = colorize(ansiYellow, heading)
+		}
+		fmt.Println(heading)
+		for _, task := range section.Tasks {
+			details := []string{task.Priority.String()}
+			if task.DueDate != nil {
+				details = append([]string{FormatDueDate(task.DueDate)}, details...)
+			}
+			if all.IsBlocked(task) {
+				details = append(details, "blocked")
+			}
+			line := fmt.Sprintf("  %-4d %-*s  %s", task.ID, maxTaskLength, task.Task, strings.Join(details, ", "))
+			fmt.Println(highlight(task, now, line))
+		}
+	}
+}
+
+// AgendaSummary counts the overdue, due today and all open tasks in one
+// short line, for shell prompts.
+func AgendaSummary(sections []AgendaSection) string {
+	open := 0
+	counts := map[string]int{}
+	for _, section := range sections {
+		counts[section.Name] = len(section.Tasks)
+		open += len(section.Tasks)
+	}
+	return fmt.Sprintf("%d overdue, %d today, %d open", counts[AgendaOverdue], counts[AgendaToday], open)
+}
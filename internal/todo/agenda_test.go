@@ -0,0 +1,72 @@
+package todo
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAgenda(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC)
+	day := func(s string) *time.Time { d := date(s); return &d }
+	earlier := time.Date(2024, 3, 6, 9, 0, 0, 0, time.UTC)
+	todos := Todos{
+		{ID: 1, Task: "Later", DueDate: day("2024-03-20")},
+		{ID: 2, Task: "Sunday", DueDate: day("2024-03-10")},
+		{ID: 3, Task: "Tomorrow", DueDate: day("2024-03-07")},
+		{ID: 4, Task: "Today", DueDate: day("2024-03-06")},
+		{ID: 5, Task: "This morning", DueDate: &earlier, DueZone: "UTC"},
+		{ID: 6, Task: "Last week", DueDate: day("2024-02-28")},
+		{ID: 7, Task: "Someday"},
+		{ID: 8, Task: "Done", DueDate: day("2024-03-01"), Completed: true},
+		{ID: 9, Task: "Next Monday", DueDate: day("2024-03-11")},
+	}
+
+	want := map[string][]int{
+		AgendaOverdue:  {6, 5},
+		AgendaToday:    {4},
+		AgendaTomorrow: {3},
+		AgendaThisWeek: {2},
+		AgendaLater:    {9, 1},
+		AgendaNoDate:   {7},
+	}
+	sections := todos.Agenda(now)
+	if len(sections) != 6 || sections[0].Name != AgendaOverdue || sections[5].Name != AgendaNoDate {
+		t.Fatalf("Expected all six sections in order, got %+v", sections)
+	}
+	for _, section := range sections {
+		var ids []int
+		for _, task := range section.Tasks {
+			ids = append(ids, task.ID)
+		}
+		if FormatIDs(ids) != FormatIDs(want[section.Name]) {
+			t.Errorf("%s: expected tasks %v, got %v", section.Name, want[section.Name], ids)
+		}
+	}
+
+	if got := AgendaSummary(sections); got != "2 overdue, 1 today, 8 open" {
+		t.Errorf("Unexpected summary %q", got)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	now := time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC)
+	overdue, soon, later := date("2024-03-05"), date("2024-03-07"), date("2024-03-08")
+	defer func() { Color = false }()
+
+	Color = false
+	if got := highlight(Todo{DueDate: &overdue}, now, "row"); got != "row" {
+		t.Errorf("Expected no color when disabled, got %q", got)
+	}
+	Color = true
+	if got := highlight(Todo{DueDate: &overdue}, now, "row"); !strings.HasPrefix(got, ansiRed) {
+		t.Errorf("Expected overdue tasks in red, got %q", got)
+	}
+	if got := highlight(Todo{DueDate: &soon}, now, "row"); !strings.HasPrefix(got, ansiYellow) {
+		t.Errorf("Expected tasks due tomorrow in yellow, got %q", got)
+	}
+	if got := highlight(Todo{DueDate: &later}, now, "row"); got != "row" {
+		t.Errorf("Expected later tasks uncolored, got %q", got)
+	}
+}
@@ -0,0 +1,38 @@
+package todo
+
+import "time"
+
+// Color turns on ANSI highlighting of overdue and soon due tasks. The CLI sets
+// it when writing to a terminal.
+var Color = false
+
+const (
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiReset  = "\x1b[0m"
+)
+
+func colorize(code, s string) string {
+	if !Color {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+// Emphasize marks s, such as a matched search term, in bold yellow.
+func Emphasize(s string) string {
+	return colorize(ansiBold+ansiYellow, s)
+}
+
+// highlight colors a line showing task: red when it is overdue, yellow when it
+// is due today or tomorrow.
+func highlight(task Todo, now time.Time, line string) string {
+	switch {
+	case task.IsOverdue(now):
+		return colorize(ansiRed, line)
+	case task.IsDueSoon(now):
+		return colorize(ansiYellow, line)
+	}
+	return line
+}
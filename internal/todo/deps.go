@@ -0,0 +1,173 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrDependencyCycle is returned when a dependency would make a task wait,
+// directly or indirectly, on itself.
+var ErrDependencyCycle = errors.New("dependency cycle")
+
+// Warnings is where the todo package reports problems that do not stop an
+// operation, such as completing a task that is still blocked.
+var Warnings io.Writer = os.Stderr
+
+// AddDependency makes the task with ID id wait for the task with ID dependsOn.
+func (t *Todos) AddDependency(id, dependsOn int) error {
+	index := t.IndexOf(id)
+	if index < 0 {
+		return fmt.Errorf("task %d not found", id)
+	}
+	if t.IndexOf(dependsOn) < 0 {
+		return fmt.Errorf("task %d not found", dependsOn)
+	}
+	if id == dependsOn {
+		return fmt.Errorf("%w: task %d cannot depend on itself", ErrDependencyCycle, id)
+	}
+	task := &(*t)[index]
+	for _, dep := range task.DependsOn {
+		if dep == dependsOn {
+			return nil
+		}
+	}
+	if path := t.dependencyPath(dependsOn, id); path != nil {
+		return fmt.Errorf("%w: %s", ErrDependencyCycle, formatPath(append([]int{id}, path...)))
+	}
+	old := FormatIDs(task.DependsOn)
+	task.DependsOn = append(task.DependsOn, dependsOn)
+	task.RecordChange("DependsOn", old, FormatIDs(task.DependsOn))
+	return nil
+}
+
+// RemoveDependency undoes AddDependency. It reports whether there was such a
+// dependency.
+func (t *Todos) RemoveDependency(id, dependsOn int) bool {
+	index := t.IndexOf(id)
+	if index < 0 {
+		return false
+	}
+	task := &(*t)[index]
+	for i, dep := range task.DependsOn {
+		if dep == dependsOn {
+			old := FormatIDs(task.DependsOn)
+			task.DependsOn = append(task.DependsOn[:i:i], task.DependsOn[i+1:]...)
+			task.RecordChange("DependsOn", old, FormatIDs(task.DependsOn))
+			return true
+		}
+	}
+	return false
+}
+
+// dependencyPath returns the chain of dependencies leading from the task with
+// ID from to the task with ID to, both included, or nil if there is none.
+func (t Todos) dependencyPath(from, to int) []int {
+	seen := map[int]bool{}
+	var walk func(id int) []int
+	walk = func(id int) []int {
+		if id == to {
+			return []int{id}
+		}
+		if seen[id] {
+			return nil
+		}
+		seen[id] = true
+		index := t.IndexOf(id)
+		if index < 0 {
+			return nil
+		}
+		for _, dep := range t[index].DependsOn {
+			if path := walk(dep); path != nil {
+				return append([]int{id}, path...)
+			}
+		}
+		return nil
+	}
+	return walk(from)
+}
+
+// OpenDependencies returns the IDs of the tasks task waits for that are not
+// completed yet.
+func (t Todos) OpenDependencies(task Todo) []int {
+	var open []int
+	for _, dep := range task.DependsOn {
+		index := t.IndexOf(dep)
+		if index >= 0 && !t[index].IsClosed() {
+			open = append(open, dep)
+		}
+	}
+	return open
+}
+
+// IsBlocked reports whether task is open and waits for another open task.
+func (t Todos) IsBlocked(task Todo) bool {
+	return !task.IsClosed() && len(t.OpenDependencies(task)) > 0
+}
+
+// Ready returns the open tasks that are not blocked.
+func (t Todos) Ready() Todos {
+	ready := Todos{}
+	for _, task := range t {
+		if !task.IsClosed() && !t.IsBlocked(task) {
+			ready = append(ready, task)
+		}
+	}
+	return ready
+}
+
+// StatusOf describes task for display: its workflow state, or Blocked while
+// it waits for open tasks.
+func (t Todos) StatusOf(task Todo) string {
+	if t.IsBlocked(task) {
+		return "Blocked"
+	}
+	return FormatStatus(ActiveWorkflow.StateOf(task))
+}
+
+// FormatStatus capitalizes a state name for display.
+func FormatStatus(state string) string {
+	if state == "" {
+		return state
+	}
+	return strings.ToUpper(state[:1]) + state[1:]
+}
+
+// dropDependenciesOn removes every dependency on the task with ID id, which
+// is being deleted.
+func (t *Todos) dropDependenciesOn(id int) {
+	for i := range *t {
+		task := &(*t)[i]
+		for j, dep := range task.DependsOn {
+			if dep == id {
+				old := FormatIDs(task.DependsOn)
+				task.DependsOn = append(task.DependsOn[:j:j], task.DependsOn[j+1:]...)
+				task.RecordChange("DependsOn", old, FormatIDs(task.DependsOn))
+				break
+			}
+		}
+	}
+}
+
+// FormatIDs lists task IDs for display, or "None".
+func FormatIDs(ids []int) string {
+	if len(ids) == 0 {
+		return "None"
+	}
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatPath(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, " -> ")
+}
@@ -0,0 +1,82 @@
+package todo
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAddDependencyDetectsCycles(t *testing.T) {
+	todos := Todos{}
+	for _, task := range []string{"Design", "Build", "Ship"} {
+		todos.Add(nil, task, nil, Low, nil)
+	}
+	if err := todos.AddDependency(2, 1); err != nil {
+		t.Fatalf("Error adding dependency: %v", err)
+	}
+	if err := todos.AddDependency(3, 2); err != nil {
+		t.Fatalf("Error adding dependency: %v", err)
+	}
+
+	tests := []struct {
+		id, dependsOn int
+		wantCycle     bool
+	}{
+		{1, 3, true},
+		{1, 1, true},
+		{3, 1, false},
+	}
+	for _, tt := range tests {
+		err := todos.AddDependency(tt.id, tt.dependsOn)
+		if errors.Is(err, ErrDependencyCycle) != tt.wantCycle {
+			t.Errorf("AddDependency(%d, %d): expected cycle %v, got %v", tt.id, tt.dependsOn, tt.wantCycle, err)
+		}
+	}
+	if err := todos.AddDependency(1, 42); err == nil {
+		t.Errorf("Expected an error depending on a missing task")
+	}
+}
+
+func TestBlockedAndReady(t *testing.T) {
+	todos := Todos{}
+	todos.Add(nil, "Design", nil, Low, nil)
+	todos.Add(nil, "Build", nil, Low, nil)
+	todos.AddDependency(2, 1)
+
+	if todos.StatusOf(todos[1]) != "Blocked" {
+		t.Errorf("Expected task 2 to be blocked, got %s", todos.StatusOf(todos[1]))
+	}
+	if ready := todos.Ready(); len(ready) != 1 || ready[0].ID != 1 {
+		t.Errorf("Expected only task 1 to be ready, got %v", ready)
+	}
+
+	todos.Complete(nil, 0)
+	if ready := todos.Ready(); len(ready) != 1 || ready[0].ID != 2 {
+		t.Errorf("Expected task 2 to be ready once task 1 is done, got %v", ready)
+	}
+
+	todos.Delete(0)
+	if len(todos[0].DependsOn) != 0 {
+		t.Errorf("Expected dependencies on a deleted task to be dropped, got %v", todos[0].DependsOn)
+	}
+}
+
+func TestCompleteWarnsAboutOpenDependencies(t *testing.T) {
+	var buf bytes.Buffer
+	old := Warnings
+	Warnings = &buf
+	defer func() { Warnings = old }()
+
+	todos := Todos{}
+	todos.Add(nil, "Design", nil, Low, nil)
+	todos.Add(nil, "Build", nil, Low, nil)
+	todos.AddDependency(2, 1)
+
+	if err := todos.Complete(nil, 1); err != nil || !todos[1].Completed {
+		t.Fatalf("Expected the task to be completed despite the warning, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "task 2 still depends on open task(s) 1") {
+		t.Errorf("Expected a warning, got %q", buf.String())
+	}
+}
@@ -0,0 +1,348 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidDate is returned for due dates ParseDueDate does not understand.
+var ErrInvalidDate = errors.New("invalid due date")
+
+// Now is the clock due dates are worked out from. Tests replace it.
+var Now = time.Now
+
+// dateFormat is how due dates without a time of day are written and stored.
+const dateFormat = "2006-01-02"
+
+// ParseDueDate resolves a due date relative to now. It accepts
+//
+//	2024-07-01
+//	today, tomorrow, yesterday (or tod, tom)
+//	mon ... sun, monday ... sunday, next monday
+//	in 3 days, in a week, in 2 months, 3d, 2w, 1m, 1y
+//	eow, eom, eoy (the last day of this week, month or year)
+//
+// optionally followed by a time of day: "tomorrow 14:00", "fri at 9am". A
+// time on its own means today. A time may be followed by a time zone, as in
+// "fri 17:00 Europe/Berlin"; "today" and the like then mean the date there.
+// A weekday is the next such day after today, with or without "next"; weeks
+// end on Sunday.
+//
+// Dates without a time of day are midnight UTC, the way dates have always
+// been stored. Dates with one are in the given zone, or now's location.
+func ParseDueDate(s string, now time.Time) (time.Time, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return time.Time{}, fmt.Errorf("%w: empty", ErrInvalidDate)
+	}
+	zoned := false
+	if last := fields[len(fields)-1]; strings.Contains(last, "/") || strings.EqualFold(last, "UTC") {
+		if strings.EqualFold(last, "UTC") {
+			last = "UTC"
+		}
+		loc, err := time.LoadLocation(last)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: unknown time zone %q", ErrInvalidDate, last)
+		}
+		now = now.In(loc)
+		fields = fields[:len(fields)-1]
+		zoned = true
+	}
+	words := strings.Fields(strings.ToLower(strings.Join(fields, " ")))
+	if len(words) == 0 {
+		return time.Time{}, fmt.Errorf("%w: %q has no date or time", ErrInvalidDate, s)
+	}
+
+	hour, minute, hasTime := 0, 0, false
+	if h, m, ok := parseTimeOfDay(words[len(words)-1]); ok {
+		hour, minute, hasTime = h, m, true
+		words = words[:len(words)-1]
+		if len(words) > 0 && words[len(words)-1] == "at" {
+			words = words[:len(words)-1]
+		}
+	}
+
+	y, mo, d := now.Date()
+	today := time.Date(y, mo, d, 0, 0, 0, 0, time.UTC)
+	day := today
+	if len(words) > 0 {
+		var ok bool
+		if day, ok = parseDay(strings.Join(words, " "), today); !ok {
+			return time.Time{}, fmt.Errorf("%w: %q (use YYYY-MM-DD, today, tomorrow, fri, next monday, in 3 days, 2w, eow, eom, optionally with a time like 14:00)", ErrInvalidDate, s)
+		}
+	}
+	if !hasTime {
+		if zoned {
+			return time.Time{}, fmt.Errorf("%w: a time zone needs a time of day, as in \"fri 17:00 Europe/Berlin\"", ErrInvalidDate)
+		}
+		return day, nil
+	}
+	y, mo, d = day.Date()
+	return time.Date(y, mo, d, hour, minute, 0, 0, now.Location()), nil
+}
+
+// parseDay resolves the date part of a due date, given today's date at
+// midnight UTC.
+func parseDay(s string, today time.Time) (time.Time, bool) {
+	if day, err := time.Parse(dateFormat, s); err == nil {
+		return day, true
+	}
+	switch s {
+	case "today", "tod":
+		return today, true
+	case "tomorrow", "tom":
+		return today.AddDate(0, 0, 1), true
+	case "yesterday":
+		return today.AddDate(0, 0, -1), true
+	case "eow":
+		return today.AddDate(0, 0, (7-int(today.Weekday()))%7), true
+	case "eom":
+		return time.Date(today.Year(), today.Month()+1, 0, 0, 0, 0, 0, time.UTC), true
+	case "eoy":
+		return time.Date(today.Year(), time.December, 31, 0, 0, 0, 0, time.UTC), true
+	}
+
+	if weekday, ok := weekdayNames[strings.TrimPrefix(s, "next ")]; ok {
+		days := (int(weekday) - int(today.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return today.AddDate(0, 0, days), true
+	}
+
+	var n int
+	var unit string
+	if rest, ok := strings.CutPrefix(s, "in "); ok {
+		count, u, found := strings.Cut(rest, " ")
+		if !found {
+			return time.Time{}, false
+		}
+		if count == "a" || count == "an" {
+			n = 1
+		} else if v, err := strconv.Atoi(count); err == nil && v >= 0 {
+			n = v
+		} else {
+			return time.Time{}, false
+		}
+		unit = strings.TrimSuffix(u, "s")
+	} else {
+		s = strings.TrimPrefix(s, "+")
+		digits := len(s) - len(strings.TrimLeft(s, "0123456789"))
+		if digits == 0 || digits == len(s) {
+			return time.Time{}, false
+		}
+		n, _ = strconv.Atoi(s[:digits])
+		unit = s[digits:]
+	}
+	switch unit {
+	case "d", "day":
+		return today.AddDate(0, 0, n), true
+	case "w", "week":
+		return today.AddDate(0, 0, 7*n), true
+	case "m", "month":
+		return addMonths(today, n), true
+	case "y", "year":
+		return addMonths(today, 12*n), true
+	}
+	return time.Time{}, false
+}
+
+// addMonths adds n months to day, keeping to the last day of shorter months
+// rather than spilling over into the next one.
+func addMonths(day time.Time, n int) time.Time {
+	first := time.Date(day.Year(), day.Month()+time.Month(n), 1, 0, 0, 0, 0, day.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day.Day(), last)-1)
+}
+
+// parseTimeOfDay parses "14:00", "9am" or "2:30pm".
+func parseTimeOfDay(s string) (hour, minute int, ok bool) {
+	pm := strings.HasSuffix(s, "pm")
+	twelveHour := pm || strings.HasSuffix(s, "am")
+	if twelveHour {
+		s = s[:len(s)-2]
+	} else if !strings.Contains(s, ":") {
+		// A bare number is a count, as in "in 3 days", not an hour.
+		return 0, 0, false
+	}
+	h, m, hasMinutes := strings.Cut(s, ":")
+	hour, err := strconv.Atoi(h)
+	if err != nil || len(h) > 2 {
+		return 0, 0, false
+	}
+	if hasMinutes {
+		if minute, err = strconv.Atoi(m); err != nil || len(m) != 2 || minute > 59 {
+			return 0, 0, false
+		}
+	}
+	switch {
+	case twelveHour && (hour < 1 || hour > 12):
+		return 0, 0, false
+	case twelveHour:
+		hour %= 12
+		if pm {
+			hour += 12
+		}
+	case hour > 23:
+		return 0, 0, false
+	}
+	return hour, minute, true
+}
+
+// HasDueTime reports whether a due date includes a time of day. Dates
+// without one are stored as midnight UTC, so a due time of exactly midnight
+// UTC cannot be told from a plain date; Todo.DueZone settles that for tasks.
+func HasDueTime(due time.Time) bool {
+	h, m, s := due.Clock()
+	return h != 0 || m != 0 || s != 0 || due.Location() != time.UTC
+}
+
+// zoneOf names the time zone of a due time, for Todo.DueZone. It is empty
+// for dates without a time of day, and "Local" when the local zone has no
+// name we can find.
+func zoneOf(due *time.Time) string {
+	if due == nil || !HasDueTime(*due) {
+		return ""
+	}
+	if loc := due.Location(); loc != time.Local {
+		// Empty for the unnamed fixed offsets of decoded times.
+		return loc.String()
+	}
+	if name := localZoneName(); name != "" {
+		return name
+	}
+	return "Local"
+}
+
+// localZoneName returns the IANA name of the local time zone, from $TZ or
+// the /etc/localtime link, or "" if neither gives one.
+func localZoneName() string {
+	if tz, ok := os.LookupEnv("TZ"); ok {
+		if tz == "" {
+			return "UTC"
+		}
+		if _, err := time.LoadLocation(tz); err == nil {
+			return tz
+		}
+		return ""
+	}
+	if target, err := os.Readlink("/etc/localtime"); err == nil {
+		if _, name, ok := strings.Cut(target, "zoneinfo/"); ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// SetDueDate changes the task's due date, noting the time zone of a due time.
+func (t *Todo) SetDueDate(due *time.Time) {
+	t.RecordChange("DueDate", FormatDueDate(t.DueDate), FormatDueDate(due))
+	t.DueDate = due
+	t.DueZone = zoneOf(due)
+}
+
+// Due returns the task's due date, a due time in the zone it was given in.
+func (t Todo) Due() (time.Time, bool) {
+	if t.DueDate == nil {
+		return time.Time{}, false
+	}
+	due := *t.DueDate
+	if t.DueZone != "" {
+		if loc, err := time.LoadLocation(t.DueZone); err == nil {
+			due = due.In(loc)
+		}
+	}
+	return due, true
+}
+
+// hasDueTime reports whether the task is due at a time rather than on a day.
+func (t Todo) hasDueTime() bool {
+	return t.DueDate != nil && (t.DueZone != "" || HasDueTime(*t.DueDate))
+}
+
+// IsOverdue reports whether the open task's due date or time has passed. A
+// task due on a day is overdue from the start of the next day in now's
+// location.
+func (t Todo) IsOverdue(now time.Time) bool {
+	due, ok := t.Due()
+	if !ok || t.IsClosed() {
+		return false
+	}
+	if t.hasDueTime() {
+		return now.After(due)
+	}
+	return CalendarDay(now).After(due)
+}
+
+// IsDueToday reports whether the open task is due on now's date, in now's
+// location.
+func (t Todo) IsDueToday(now time.Time) bool {
+	day, ok := t.DueDay(now)
+	return ok && !t.IsClosed() && day.Equal(CalendarDay(now))
+}
+
+// IsDueSoon reports whether the open task is due today or tomorrow and not
+// overdue yet.
+func (t Todo) IsDueSoon(now time.Time) bool {
+	day, ok := t.DueDay(now)
+	if !ok || t.IsClosed() || t.IsOverdue(now) {
+		return false
+	}
+	return !day.After(CalendarDay(now).AddDate(0, 0, 1))
+}
+
+// DueDay returns the day the task is due as midnight UTC of that date, with
+// due times taken in now's location.
+func (t Todo) DueDay(now time.Time) (time.Time, bool) {
+	due, ok := t.Due()
+	if !ok {
+		return time.Time{}, false
+	}
+	if t.hasDueTime() {
+		return CalendarDay(due.In(now.Location())), true
+	}
+	return due, true
+}
+
+// CalendarDay returns the date of t, whatever its location, as midnight UTC.
+// Comparing days this way is unaffected by daylight saving changes, which
+// make some local days 23 or 25 hours long.
+func CalendarDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// DueLabel renders the task's due date in the local zone, the way Print does,
+// marking it when overdue or due today.
+func (t Todo) DueLabel(now time.Time) string {
+	label := FormatDueDate(t.DueDate)
+	if t.hasDueTime() && !HasDueTime(*t.DueDate) {
+		label = t.DueDate.Local().Format(dateFormat + " 15:04")
+	}
+	switch {
+	case t.IsOverdue(now):
+		return label + " (overdue)"
+	case t.IsDueToday(now):
+		return label + " (today)"
+	}
+	return label
+}
+
+// ZoneLabel shows a due time in the zone it was given in, for example
+// "17:00 Europe/Berlin", when that zone is not the local one. It is empty
+// otherwise.
+func (t Todo) ZoneLabel() string {
+	due, ok := t.Due()
+	if !ok || t.DueZone == "" || t.DueZone == "Local" || t.DueZone == localZoneName() {
+		return ""
+	}
+	_, offset := due.Zone()
+	if _, localOffset := due.Local().Zone(); offset == localOffset {
+		return ""
+	}
+	return due.Format(dateFormat+" 15:04") + " " + t.DueZone
+}
@@ -0,0 +1,161 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseDueDate(t *testing.T) {
+	zone := time.FixedZone("UTC+2", 2*60*60)
+	// A Wednesday.
+	now := time.Date(2024, 3, 6, 10, 30, 0, 0, zone)
+	at := func(day string, hour, minute int) time.Time {
+		d := date(day)
+		return time.Date(d.Year(), d.Month(), d.Day(), hour, minute, 0, 0, zone)
+	}
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		{"2024-07-01", date("2024-07-01")},
+		{"today", date("2024-03-06")},
+		{"Tomorrow", date("2024-03-07")},
+		{"tom", date("2024-03-07")},
+		{"yesterday", date("2024-03-05")},
+		{"fri", date("2024-03-08")},
+		{"wed", date("2024-03-13")},
+		{"next monday", date("2024-03-11")},
+		{"in 3 days", date("2024-03-09")},
+		{"in a week", date("2024-03-13")},
+		{"in 2 months", date("2024-05-06")},
+		{"2w", date("2024-03-20")},
+		{"+10d", date("2024-03-16")},
+		{"1y", date("2025-03-06")},
+		{"eow", date("2024-03-10")},
+		{"eom", date("2024-03-31")},
+		{"eoy", date("2024-12-31")},
+		{"tomorrow 14:00", at("2024-03-07", 14, 0)},
+		{"fri at 9am", at("2024-03-08", 9, 0)},
+		{"in 2 days 2:30pm", at("2024-03-08", 14, 30)},
+		{"12am", at("2024-03-06", 0, 0)},
+		{"17:30", at("2024-03-06", 17, 30)},
+	}
+	for _, tt := range tests {
+		got, err := ParseDueDate(tt.input, now)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.input, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "someday", "in x days", "3", "25:00", "13pm", "tomorrow 9:5", "2024-02-30", "in 3 fortnights"} {
+		if _, err := ParseDueDate(input, now); !errors.Is(err, ErrInvalidDate) {
+			t.Errorf("%q: expected ErrInvalidDate, got %v", input, err)
+		}
+	}
+}
+
+func TestParseDueDateClampsMonths(t *testing.T) {
+	now := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	got, err := ParseDueDate("1m", now)
+	if err != nil || !got.Equal(date("2024-02-29")) {
+		t.Errorf("Expected 2024-02-29, got %v (%v)", got, err)
+	}
+}
+
+func TestFormatDueDateShowsTime(t *testing.T) {
+	day := date("2024-03-06")
+	if got := FormatDueDate(&day); got != "2024-03-06" {
+		t.Errorf("Expected just the date, got %q", got)
+	}
+	due := time.Date(2024, 3, 6, 14, 0, 0, 0, time.Local)
+	if got := FormatDueDate(&due); got != "2024-03-06 14:00" {
+		t.Errorf("Expected the date and time, got %q", got)
+	}
+}
+
+func TestParseDueDateWithZone(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	// Still the 6th in UTC, already the 7th in Berlin.
+	now := time.Date(2024, 3, 6, 23, 30, 0, 0, time.UTC)
+	got, err := ParseDueDate("today 17:00 Europe/Berlin", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 3, 7, 17, 0, 0, 0, berlin); !got.Equal(want) || got.Location().String() != "Europe/Berlin" {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	for _, input := range []string{"fri Europe/Berlin", "17:00 Mars/Olympus"} {
+		if _, err := ParseDueDate(input, now); !errors.Is(err, ErrInvalidDate) {
+			t.Errorf("%q: expected ErrInvalidDate, got %v", input, err)
+		}
+	}
+}
+
+func TestOverdueAcrossDaylightSaving(t *testing.T) {
+	newYork, _ := time.LoadLocation("America/New_York")
+	// Clocks in New York went forward on 2024-03-10, a 23-hour day.
+	day := date("2024-03-10")
+	task := Todo{ID: 1, DueDate: &day}
+	tests := []struct {
+		now            time.Time
+		today, overdue bool
+	}{
+		{time.Date(2024, 3, 9, 23, 59, 0, 0, newYork), false, false},
+		{time.Date(2024, 3, 10, 0, 0, 0, 0, newYork), true, false},
+		{time.Date(2024, 3, 10, 23, 59, 0, 0, newYork), true, false},
+		{time.Date(2024, 3, 11, 0, 10, 0, 0, newYork), false, true},
+	}
+	for _, tt := range tests {
+		if got := task.IsDueToday(tt.now); got != tt.today {
+			t.Errorf("%v: IsDueToday = %v, want %v", tt.now, got, tt.today)
+		}
+		if got := task.IsOverdue(tt.now); got != tt.overdue {
+			t.Errorf("%v: IsOverdue = %v, want %v", tt.now, got, tt.overdue)
+		}
+	}
+
+	due := time.Date(2024, 3, 10, 9, 0, 0, 0, newYork)
+	timed := Todo{ID: 2, DueDate: &due, DueZone: "America/New_York"}
+	if before := due.Add(-time.Minute); timed.IsOverdue(before) || !timed.IsDueToday(before) {
+		t.Errorf("Expected a task due at 09:00 to be due today, not overdue, at 08:59")
+	}
+	if !timed.IsOverdue(due.Add(time.Minute)) {
+		t.Errorf("Expected a task due at 09:00 to be overdue at 09:01")
+	}
+	timed.Completed = true
+	if timed.IsOverdue(due.Add(time.Hour)) {
+		t.Errorf("Expected a completed task not to be overdue")
+	}
+}
+
+func TestRecurringDueTimeKeepsWallClock(t *testing.T) {
+	newYork, _ := time.LoadLocation("America/New_York")
+	todos := Todos{}
+	due := time.Date(2024, 3, 8, 9, 0, 0, 0, newYork)
+	todos.Add(nil, "Standup", &due, Low, nil)
+	todos[0].Recur = "FREQ=WEEKLY"
+	if todos[0].DueZone != "America/New_York" {
+		t.Fatalf("Expected the zone to be recorded, got %q", todos[0].DueZone)
+	}
+
+	// Saved and loaded, the due time only keeps its UTC offset.
+	data, _ := json.Marshal(todos)
+	var loaded Todos
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Complete(nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	next, _ := loaded[1].Due()
+	if want := time.Date(2024, 3, 15, 9, 0, 0, 0, newYork); !next.Equal(want) {
+		t.Errorf("Expected the next occurrence at 09:00 New York time, got %v", next)
+	}
+}
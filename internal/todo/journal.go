@@ -0,0 +1,303 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// MaxJournalOps is how many operations the journal keeps for undo.
+var MaxJournalOps = 100
+
+// ErrConflict is returned when undo or redo would overwrite a change that was
+// made outside the journal, for example by editing the file by hand.
+var ErrConflict = errors.New("tasks changed since the operation was recorded")
+
+// Journal records every change made to a list so it can be undone and redone.
+// Ops[:Cursor] have been applied; Ops[Cursor:] were undone and can be redone
+// until a new operation is recorded.
+type Journal struct {
+	Path   string      `json:"-"`
+	Ops    []Operation `json:"ops"`
+	Cursor int         `json:"cursor"`
+
+	// saved is the journal as last read or written, so that Save can skip
+	// writing one that did not change.
+	saved []byte
+}
+
+// Operation is one mutating command and the tasks and lists it changed.
+type Operation struct {
+	Seq     int          `json:"seq"`
+	Time    time.Time    `json:"time"`
+	Command string       `json:"command"`
+	Changes []TaskChange `json:"changes"`
+	Lists   *ListChange  `json:"lists,omitempty"`
+}
+
+// ListChange holds the set of lists before and after an operation that
+// created, renamed, archived or switched lists.
+type ListChange struct {
+	Before ListSet `json:"before"`
+	After  ListSet `json:"after"`
+}
+
+// TaskChange holds a task as it was before and after an operation. Before is
+// nil for added tasks and After is nil for deleted ones; the indexes record
+// where the task sat in the list so undo and redo can put it back there.
+type TaskChange struct {
+	ID          int   `json:"id"`
+	Before      *Todo `json:"before,omitempty"`
+	BeforeIndex int   `json:"before_index"`
+	After       *Todo `json:"after,omitempty"`
+	AfterIndex  int   `json:"after_index"`
+}
+
+// LoadJournal reads the journal at path. A missing file is an empty journal.
+func LoadJournal(path string) (*Journal, error) {
+	j := &Journal{Path: path}
+	data, err := os.ReadFile(path)
+	switch {
+	case isNotExist(err):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, j); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if j.Cursor < 0 || j.Cursor > len(j.Ops) {
+		j.Cursor = len(j.Ops)
+	}
+	j.saved, err = json.MarshalIndent(j, "", "  ")
+	return j, err
+}
+
+// Save writes the journal, unless it is unchanged since it was loaded.
+func (j *Journal) Save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil || bytes.Equal(data, j.saved) {
+		return err
+	}
+	if err := writeFileAtomic(j.Path, data, 0644); err != nil {
+		return err
+	}
+	j.saved = data
+	return nil
+}
+
+// Record adds an operation turning before into after. Nothing is recorded if
+// the command changed no tasks. Recording discards any undone operations.
+func (j *Journal) Record(command string, before, after Todos) error {
+	return j.record(command, before, after, nil)
+}
+
+// RecordLists is Record for commands that may also have changed the set of
+// lists.
+func (j *Journal) RecordLists(command string, before, after Todos, listsBefore, listsAfter ListSet) error {
+	same, err := sameJSON(listsBefore, listsAfter)
+	if err != nil {
+		return err
+	}
+	if same {
+		return j.record(command, before, after, nil)
+	}
+	return j.record(command, before, after, &ListChange{Before: listsBefore.Clone(), After: listsAfter.Clone()})
+}
+
+func (j *Journal) record(command string, before, after Todos, lists *ListChange) error {
+	changes, err := diffTodos(before, after)
+	if err != nil || (len(changes) == 0 && lists == nil) {
+		return err
+	}
+
+	seq := 1
+	if len(j.Ops) > 0 {
+		seq = j.Ops[len(j.Ops)-1].Seq + 1
+	}
+	j.Ops = append(j.Ops[:j.Cursor], Operation{Seq: seq, Time: time.Now(), Command: command, Changes: changes, Lists: lists})
+	if len(j.Ops) > MaxJournalOps {
+		j.Ops = j.Ops[len(j.Ops)-MaxJournalOps:]
+	}
+	j.Cursor = len(j.Ops)
+	return nil
+}
+
+// Undo reverts the last applied operation on todos and lists and returns it.
+func (j *Journal) Undo(todos *Todos, lists *ListSet) (*Operation, error) {
+	if j.Cursor == 0 {
+		return nil, fmt.Errorf("nothing to undo")
+	}
+	op := &j.Ops[j.Cursor-1]
+	if err := op.revert(todos, lists, false); err != nil {
+		return nil, fmt.Errorf("cannot undo #%d (%s): %w", op.Seq, op.Command, err)
+	}
+	j.Cursor--
+	return op, nil
+}
+
+// Redo reapplies the most recently undone operation on todos and lists and
+// returns it.
+func (j *Journal) Redo(todos *Todos, lists *ListSet) (*Operation, error) {
+	if j.Cursor == len(j.Ops) {
+		return nil, fmt.Errorf("nothing to redo")
+	}
+	op := &j.Ops[j.Cursor]
+	if err := op.revert(todos, lists, true); err != nil {
+		return nil, fmt.Errorf("cannot redo #%d (%s): %w", op.Seq, op.Command, err)
+	}
+	j.Cursor++
+	return op, nil
+}
+
+func (op *Operation) revert(todos *Todos, lists *ListSet, forward bool) error {
+	if op.Lists == nil {
+		return todos.revert(op.Changes, forward)
+	}
+	from, to := op.Lists.After, op.Lists.Before
+	if forward {
+		from, to = to, from
+	}
+	same, err := sameJSON(*lists, from)
+	if err != nil {
+		return err
+	}
+	if !same {
+		return fmt.Errorf("%w: lists were modified", ErrConflict)
+	}
+	if err := todos.revert(op.Changes, forward); err != nil {
+		return err
+	}
+	*lists = to.Clone()
+	return nil
+}
+
+// revert moves todos from one side of changes to the other: from After to
+// Before, or from Before to After when forward is set. It fails without
+// touching todos if any task no longer matches the side it starts from.
+func (t *Todos) revert(changes []TaskChange, forward bool) error {
+	type side struct {
+		from, to *Todo
+		toIndex  int
+		id       int
+	}
+	sides := make([]side, len(changes))
+	for i, c := range changes {
+		if forward {
+			sides[i] = side{from: c.Before, to: c.After, toIndex: c.AfterIndex, id: c.ID}
+		} else {
+			sides[i] = side{from: c.After, to: c.Before, toIndex: c.BeforeIndex, id: c.ID}
+		}
+	}
+
+	for _, s := range sides {
+		index := t.IndexOf(s.id)
+		if s.from == nil {
+			if index >= 0 {
+				return fmt.Errorf("%w: task %d exists again", ErrConflict, s.id)
+			}
+			continue
+		}
+		if index < 0 {
+			return fmt.Errorf("%w: task %d no longer exists", ErrConflict, s.id)
+		}
+		same, err := sameTask((*t)[index], *s.from)
+		if err != nil {
+			return err
+		}
+		if !same {
+			return fmt.Errorf("%w: task %d was modified", ErrConflict, s.id)
+		}
+	}
+
+	var inserts []side
+	for _, s := range sides {
+		index := t.IndexOf(s.id)
+		switch {
+		case s.to == nil:
+			*t = append((*t)[:index], (*t)[index+1:]...)
+		case index >= 0:
+			(*t)[index] = *s.to
+		default:
+			inserts = append(inserts, s)
+		}
+	}
+	sort.Slice(inserts, func(a, b int) bool { return inserts[a].toIndex < inserts[b].toIndex })
+	for _, s := range inserts {
+		index := s.toIndex
+		if index > len(*t) {
+			index = len(*t)
+		}
+		*t = append(*t, Todo{})
+		copy((*t)[index+1:], (*t)[index:])
+		(*t)[index] = *s.to
+	}
+	return nil
+}
+
+// Clone returns a deep copy of todos.
+func (t Todos) Clone() Todos {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return append(Todos(nil), t...)
+	}
+	var clone Todos
+	json.Unmarshal(data, &clone)
+	return clone
+}
+
+func diffTodos(before, after Todos) ([]TaskChange, error) {
+	var changes []TaskChange
+	afterIndex := make(map[int]int, len(after))
+	for i, task := range after {
+		afterIndex[task.ID] = i
+	}
+	beforeIDs := make(map[int]bool, len(before))
+
+	for i, old := range before {
+		beforeIDs[old.ID] = true
+		j, ok := afterIndex[old.ID]
+		if !ok {
+			changes = append(changes, TaskChange{ID: old.ID, Before: copyTask(old), BeforeIndex: i})
+			continue
+		}
+		same, err := sameTask(old, after[j])
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			changes = append(changes, TaskChange{ID: old.ID, Before: copyTask(old), BeforeIndex: i, After: copyTask(after[j]), AfterIndex: j})
+		}
+	}
+	for j, task := range after {
+		if !beforeIDs[task.ID] {
+			changes = append(changes, TaskChange{ID: task.ID, After: copyTask(task), AfterIndex: j})
+		}
+	}
+	return changes, nil
+}
+
+func sameTask(a, b Todo) (bool, error) {
+	return sameJSON(a, b)
+}
+
+func sameJSON(a, b any) (bool, error) {
+	encodedA, err := json.Marshal(a)
+	if err != nil {
+		return false, err
+	}
+	encodedB, err := json.Marshal(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(encodedA, encodedB), nil
+}
+
+func copyTask(task Todo) *Todo {
+	clone := Todos{task}.Clone()
+	return &clone[0]
+}
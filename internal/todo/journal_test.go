@@ -0,0 +1,158 @@
+package todo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func taskIDs(todos Todos) []int {
+	ids := []int{}
+	for _, todo := range todos {
+		ids = append(ids, todo.ID)
+	}
+	return ids
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestJournalUndoRedo(t *testing.T) {
+	journal := &Journal{Path: filepath.Join(t.TempDir(), "todos.json.journal")}
+	todos := Todos{}
+
+	step := func(command string, fn func()) {
+		before := todos.Clone()
+		fn()
+		if err := journal.Record(command, before, todos); err != nil {
+			t.Fatalf("Unexpected error recording %s: %v", command, err)
+		}
+	}
+	step("add 1", func() { todos.Add(nil, "Task 1", nil, Low, nil) })
+	step("add 2", func() { todos.Add(nil, "Task 2", nil, Low, nil) })
+	step("add 3", func() { todos.Add(nil, "Task 3", nil, Low, nil) })
+	step("complete 2", func() { todos.Complete(nil, 1) })
+	step("list", func() {})
+	step("clear", func() { todos = Todos{} })
+
+	if len(journal.Ops) != 5 {
+		t.Fatalf("Expected 5 operations (no-op commands are skipped), got %d", len(journal.Ops))
+	}
+
+	if _, err := journal.Undo(&todos, &ListSet{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !equalIDs(taskIDs(todos), []int{1, 2, 3}) || !todos[1].Completed {
+		t.Fatalf("Expected clear to be undone in order, got %+v", todos)
+	}
+
+	op, err := journal.Undo(&todos, &ListSet{})
+	if err != nil || op.Command != "complete 2" {
+		t.Fatalf("Expected to undo 'complete 2', got %v, %v", op, err)
+	}
+	if todos[1].Completed {
+		t.Error("Expected task 2 to be pending again")
+	}
+
+	if _, err := journal.Redo(&todos, &ListSet{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !todos[1].Completed {
+		t.Error("Expected redo to complete task 2 again")
+	}
+
+	if err := journal.Save(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	reloaded, err := LoadJournal(journal.Path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if reloaded.Cursor != 4 || len(reloaded.Ops) != 5 {
+		t.Errorf("Expected cursor 4 of 5 after reload, got %d of %d", reloaded.Cursor, len(reloaded.Ops))
+	}
+
+	before := todos.Clone()
+	todos.Delete(0)
+	journal.Record("delete 1", before, todos)
+	if _, err := journal.Redo(&todos, &ListSet{}); err == nil {
+		t.Error("Expected recording a new operation to discard undone ones")
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := journal.Undo(&todos, &ListSet{}); err != nil {
+			t.Fatalf("Unexpected error on undo %d: %v", i+1, err)
+		}
+	}
+	if len(todos) != 0 {
+		t.Errorf("Expected every operation to be undone, got %+v", todos)
+	}
+	if _, err := journal.Undo(&todos, &ListSet{}); err == nil {
+		t.Error("Expected error when there is nothing to undo")
+	}
+}
+
+func TestJournalUndoConflict(t *testing.T) {
+	journal := &Journal{}
+	todos := Todos{}
+	before := todos.Clone()
+	todos.Add(nil, "Task 1", nil, Low, nil)
+	journal.Record("add", before, todos)
+
+	todos[0].Task = "Edited by hand"
+	if _, err := journal.Undo(&todos, &ListSet{}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+	if len(todos) != 1 || journal.Cursor != 1 {
+		t.Error("Expected a conflicting undo to change nothing")
+	}
+}
+
+func TestJournalKeepsMaxOps(t *testing.T) {
+	old := MaxJournalOps
+	MaxJournalOps = 3
+	defer func() { MaxJournalOps = old }()
+
+	journal := &Journal{}
+	todos := Todos{}
+	for i := 0; i < 5; i++ {
+		before := todos.Clone()
+		todos.Add(nil, "Task", nil, Low, nil)
+		journal.Record("add", before, todos)
+	}
+	if len(journal.Ops) != 3 || journal.Ops[0].Seq != 3 || journal.Cursor != 3 {
+		t.Errorf("Expected the 3 newest operations, got %+v", journal.Ops)
+	}
+}
+
+func TestJournalSaveSkipsUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.journal")
+	journal, err := LoadJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Expected an unchanged journal not to be written, got %v", err)
+	}
+
+	journal.Record("add", Todos{}, Todos{{ID: 1, Task: "Task"}})
+	if err := journal.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected a changed journal to be written, got %v", err)
+	}
+}
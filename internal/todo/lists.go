@@ -0,0 +1,174 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultList is the list tasks belong to unless they were put in another.
+// Tasks with an empty List field are in it.
+const DefaultList = "default"
+
+// ErrNoSuchList is returned for list names that do not exist.
+var ErrNoSuchList = errors.New("no such list")
+
+// ListInfo describes one named list.
+type ListInfo struct {
+	Name      string     `json:"name"`
+	Archived  bool       `json:"archived,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// ListSet is the set of named lists in a file and which one is active. The
+// default list always exists, even if it is not recorded yet.
+type ListSet struct {
+	Lists  []ListInfo `json:"lists,omitempty"`
+	Active string     `json:"active_list,omitempty"`
+}
+
+// InList reports whether the task belongs to the named list.
+func (t Todo) InList(name string) bool {
+	return t.List == name || (t.List == "" && name == DefaultList)
+}
+
+// InList returns the tasks that belong to the named list.
+func (t Todos) InList(name string) Todos {
+	tasks := Todos{}
+	for _, todo := range t {
+		if todo.InList(name) {
+			tasks = append(tasks, todo)
+		}
+	}
+	return tasks
+}
+
+// ActiveName returns the name of the active list.
+func (s *ListSet) ActiveName() string {
+	if s.Active == "" {
+		return DefaultList
+	}
+	return s.Active
+}
+
+// All returns every list, archived or not.
+func (s *ListSet) All() []ListInfo {
+	s.ensureDefault()
+	return s.Lists
+}
+
+// Find returns the named list, or nil if there is none.
+func (s *ListSet) Find(name string) *ListInfo {
+	s.ensureDefault()
+	for i := range s.Lists {
+		if s.Lists[i].Name == name {
+			return &s.Lists[i]
+		}
+	}
+	return nil
+}
+
+func (s *ListSet) ensureDefault() {
+	for _, l := range s.Lists {
+		if l.Name == DefaultList {
+			return
+		}
+	}
+	if s.Active != "" && s.Active != DefaultList {
+		// The default list was renamed; don't resurrect it.
+		return
+	}
+	s.Lists = append([]ListInfo{{Name: DefaultList}}, s.Lists...)
+}
+
+// Clone returns a copy of s that shares no memory with it.
+func (s ListSet) Clone() ListSet {
+	s.Lists = append([]ListInfo(nil), s.Lists...)
+	return s
+}
+
+func (s *ListSet) Create(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("list name cannot be empty")
+	}
+	if s.Find(name) != nil {
+		return fmt.Errorf("list '%s' already exists", name)
+	}
+	now := time.Now()
+	s.Lists = append(s.Lists, ListInfo{Name: name, CreatedAt: &now})
+	return nil
+}
+
+// Rename renames a list and moves its tasks with it.
+func (s *ListSet) Rename(oldName, newName string, todos *Todos) error {
+	newName = strings.TrimSpace(newName)
+	list := s.Find(oldName)
+	if list == nil {
+		return fmt.Errorf("%w: %s", ErrNoSuchList, oldName)
+	}
+	if newName == "" {
+		return fmt.Errorf("list name cannot be empty")
+	}
+	if s.Find(newName) != nil {
+		return fmt.Errorf("list '%s' already exists", newName)
+	}
+	list.Name = newName
+	if s.ActiveName() == oldName {
+		s.Active = newName
+	}
+	for i := range *todos {
+		task := &(*todos)[i]
+		if task.InList(oldName) {
+			task.RecordChange("List", oldName, newName)
+			task.List = newName
+		}
+	}
+	return nil
+}
+
+// SetArchived archives or restores a list. The active list cannot be
+// archived.
+func (s *ListSet) SetArchived(name string, archived bool) error {
+	list := s.Find(name)
+	if list == nil {
+		return fmt.Errorf("%w: %s", ErrNoSuchList, name)
+	}
+	if archived && s.ActiveName() == name {
+		return fmt.Errorf("list '%s' is active; switch to another list before archiving it", name)
+	}
+	list.Archived = archived
+	return nil
+}
+
+// Use makes the named list the active one.
+func (s *ListSet) Use(name string) error {
+	list := s.Find(name)
+	if list == nil {
+		return fmt.Errorf("%w: %s", ErrNoSuchList, name)
+	}
+	if list.Archived {
+		return fmt.Errorf("list '%s' is archived", name)
+	}
+	s.Active = name
+	return nil
+}
+
+// Move puts the task at index into the named list.
+func (t *Todos) Move(index int, list string) error {
+	if index < 0 || index >= len(*t) {
+		return fmt.Errorf("index out of range")
+	}
+	task := &(*t)[index]
+	old := task.List
+	if old == "" {
+		old = DefaultList
+	}
+	task.RecordChange("List", old, list)
+	task.List = list
+	if list == DefaultList {
+		task.List = ""
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package todo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListSetRenameMovesTasks(t *testing.T) {
+	lists := &ListSet{}
+	if err := lists.Create("work"); err != nil {
+		t.Fatalf("Error creating list: %v", err)
+	}
+	if err := lists.Create("work"); err == nil {
+		t.Errorf("Expected an error creating a duplicate list")
+	}
+	todos := Todos{{ID: 1, Task: "Home"}, {ID: 2, Task: "Report", List: "work"}}
+
+	if err := lists.Rename(DefaultList, "inbox", &todos); err != nil {
+		t.Fatalf("Error renaming list: %v", err)
+	}
+	if todos[0].List != "inbox" || todos[1].List != "work" {
+		t.Errorf("Expected only the default list's task to move, got %+v", todos)
+	}
+	if lists.ActiveName() != "inbox" || lists.Find(DefaultList) != nil {
+		t.Errorf("Expected the renamed list to stay active, got %+v", lists)
+	}
+	if err := lists.Rename("missing", "other", &todos); !errors.Is(err, ErrNoSuchList) {
+		t.Errorf("Expected ErrNoSuchList, got %v", err)
+	}
+}
+
+func TestListSetArchive(t *testing.T) {
+	lists := &ListSet{}
+	lists.Create("old")
+	if err := lists.SetArchived(DefaultList, true); err == nil {
+		t.Errorf("Expected an error archiving the active list")
+	}
+	if err := lists.SetArchived("old", true); err != nil {
+		t.Fatalf("Error archiving list: %v", err)
+	}
+	if err := lists.Use("old"); err == nil {
+		t.Errorf("Expected an error switching to an archived list")
+	}
+	if err := lists.SetArchived("old", false); err != nil {
+		t.Fatalf("Error restoring list: %v", err)
+	}
+	if err := lists.Use("old"); err != nil || lists.ActiveName() != "old" {
+		t.Errorf("Expected 'old' to be active, got %q (%v)", lists.ActiveName(), err)
+	}
+}
+
+func TestLoadMigratesNamedLists(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "todos.json")
+	v1 := `{"version": 1, "tasks": [{"ID": 1, "Task": "Old"}], "meta": {}}`
+	if err := os.WriteFile(filename, []byte(v1), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewJSONStore(filename)
+	todos, err := store.Load()
+	if err != nil {
+		t.Fatalf("Error loading todos: %v", err)
+	}
+	meta := store.Meta()
+	if meta.ActiveName() != DefaultList || meta.Find(DefaultList) == nil || len(meta.Lists) != 1 {
+		t.Errorf("Expected the default list to be recorded and active, got %+v", meta.ListSet)
+	}
+	if !todos[0].InList(DefaultList) {
+		t.Errorf("Expected existing tasks in the default list, got %+v", todos[0])
+	}
+}
+
+func TestStoresPersistListChanges(t *testing.T) {
+	for _, backend := range []string{BackendJSON, BackendDB} {
+		t.Run(backend, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "todos")
+			store, _ := OpenStore(backend, filename)
+			err := store.Update(func(todos *Todos) error {
+				todos.Add(store.Meta(), "Task", nil, Low, nil)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Only the meta changes, which the log store has to append on its
+			// own.
+			err = store.Update(func(todos *Todos) error {
+				if err := store.Meta().Create("work"); err != nil {
+					return err
+				}
+				return store.Meta().Use("work")
+			})
+			if err != nil {
+				t.Fatalf("Error updating lists: %v", err)
+			}
+
+			reopened, _ := OpenStore(backend, filename)
+			if _, err := reopened.Load(); err != nil {
+				t.Fatal(err)
+			}
+			if reopened.Meta().Find("work") == nil || reopened.Meta().ActiveName() != "work" {
+				t.Errorf("Expected list changes to be saved, got %+v", reopened.Meta().ListSet)
+			}
+		})
+	}
+}
+
+func TestJournalUndoesListChanges(t *testing.T) {
+	journal := &Journal{}
+	todos := Todos{{ID: 1, Task: "Task"}}
+	lists := ListSet{}
+	lists.Find(DefaultList)
+
+	before, listsBefore := todos.Clone(), lists.Clone()
+	lists.Create("work")
+	lists.Rename(DefaultList, "inbox", &todos)
+	if err := journal.RecordLists("lists rename", before, todos, listsBefore, lists); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := journal.Undo(&todos, &lists); err != nil {
+		t.Fatalf("Error undoing: %v", err)
+	}
+	if todos[0].List != "" || lists.Find("work") != nil || lists.ActiveName() != DefaultList {
+		t.Errorf("Expected tasks and lists to be restored, got %+v and %+v", todos, lists)
+	}
+
+	lists.Create("other")
+	if _, err := journal.Redo(&todos, &lists); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict redoing over changed lists, got %v", err)
+	}
+}
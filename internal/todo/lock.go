@@ -0,0 +1,54 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrLocked is returned when another process holds the lock on a todo file
+// for longer than LockTimeout.
+var ErrLocked = errors.New("todo file is locked by another process")
+
+// LockTimeout is how long AcquireLock waits for another process to finish.
+var LockTimeout = 5 * time.Second
+
+const lockRetryInterval = 50 * time.Millisecond
+
+// Lock is an advisory, inter-process lock on a todo file. It is held on a
+// separate "<file>.lock" file so the data file itself can be replaced while
+// the lock is held.
+type Lock struct {
+	file *os.File
+	path string
+}
+
+// AcquireLock locks filename, retrying until timeout elapses.
+func AcquireLock(filename string, timeout time.Duration) (*Lock, error) {
+	path := filename + ".lock"
+	deadline := time.Now().Add(timeout)
+	for {
+		l, err := tryLock(path)
+		if err == nil {
+			return l, nil
+		}
+		if !errors.Is(err, errWouldBlock) {
+			return nil, fmt.Errorf("locking %s: %w", filename, err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: gave up on %s after %s", ErrLocked, filename, timeout)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// withLock runs fn while holding the lock on filename.
+func withLock(filename string, fn func() error) error {
+	l, err := AcquireLock(filename, LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+	return fn()
+}
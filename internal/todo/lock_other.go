@@ -0,0 +1,28 @@
+//go:build !unix
+
+package todo
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+var errWouldBlock = errors.New("lock file exists")
+
+// Without flock, the lock is the existence of the lock file itself.
+func tryLock(path string) (*Lock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return nil, errWouldBlock
+		}
+		return nil, err
+	}
+	return &Lock{file: f, path: path}, nil
+}
+
+func (l *Lock) Release() error {
+	l.file.Close()
+	return os.Remove(l.path)
+}
@@ -0,0 +1,87 @@
+package todo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquireLock(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "todos.json")
+
+	lock, err := AcquireLock(filename, time.Second)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	start := time.Now()
+	_, err = AcquireLock(filename, 100*time.Millisecond)
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("Expected ErrLocked while the lock is held, got %v", err)
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Error("Expected AcquireLock to wait for the timeout before giving up")
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Unexpected error releasing lock: %v", err)
+	}
+	lock, err = AcquireLock(filename, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Expected lock to be free after release, got %v", err)
+	}
+	lock.Release()
+}
+
+func TestUpdateHonoursLock(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "todos.json")
+	store := NewJSONStore(filename)
+
+	lock, err := AcquireLock(filename, time.Second)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer lock.Release()
+
+	old := LockTimeout
+	LockTimeout = 100 * time.Millisecond
+	defer func() { LockTimeout = old }()
+
+	called := false
+	err = store.Update(func(todos *Todos) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked, got %v", err)
+	}
+	if called {
+		t.Error("Expected Update not to run while another process holds the lock")
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "todos.json")
+	if err := os.WriteFile(filename, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(filename, []byte("new"), 0600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil || string(data) != "new" {
+		t.Errorf("Expected file to contain 'new', got %q (%v)", data, err)
+	}
+	if info, _ := os.Stat(filename); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary files left behind, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,33 @@
+//go:build unix
+
+package todo
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var errWouldBlock = syscall.EWOULDBLOCK
+
+func tryLock(path string) (*Lock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EAGAIN) {
+			return nil, errWouldBlock
+		}
+		return nil, err
+	}
+	return &Lock{file: f, path: path}, nil
+}
+
+// Release unlocks the file. The lock file itself is left in place, since
+// removing it could let two processes lock different files of the same name.
+func (l *Lock) Release() error {
+	syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	return l.file.Close()
+}
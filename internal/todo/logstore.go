@@ -0,0 +1,330 @@
+package todo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+const (
+	opMeta   = "meta"
+	opPut    = "put"
+	opDelete = "del"
+
+	// compactSlack is how many superseded records the log may carry before
+	// Save rewrites it with one record per live task.
+	compactSlack = 64
+)
+
+// logRecord is one line of a LogStore file. A meta record carries the schema
+// version and file metadata; put and del records write and remove one task.
+type logRecord struct {
+	Op      string          `json:"op"`
+	ID      int             `json:"id,omitempty"`
+	Task    json.RawMessage `json:"task,omitempty"`
+	Version int             `json:"version,omitempty"`
+	Meta    *Meta           `json:"meta,omitempty"`
+}
+
+// LogStore is a small embedded database kept in a single append-only file.
+// Each line records one task being written or deleted, so saving a list only
+// appends the tasks that changed since it was last loaded instead of
+// rewriting every task. The log is compacted, atomically, once superseded
+// records pile up.
+type LogStore struct {
+	Path string
+
+	// synced holds the encoded form of each task as last read or written,
+	// which is what Save diffs against.
+	synced map[int][]byte
+	meta   Meta
+	// syncedMeta is the encoded meta as last read or written.
+	syncedMeta []byte
+	records    int
+	// rewrite is set when Load dropped a partially written last record or
+	// migrated an older schema; the next Save rewrites the whole file rather
+	// than appending to it.
+	rewrite bool
+}
+
+func NewLogStore(path string) *LogStore {
+	return &LogStore{Path: path}
+}
+
+func (s *LogStore) Load() (Todos, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		if isNotExist(err) {
+			s.meta = Meta{}
+			s.remember(nil, 0)
+			s.rewrite = false
+		}
+		return nil, err
+	}
+
+	// Logs written before the schema was versioned carry no meta record and
+	// hold version 1 tasks.
+	version := 1
+	meta := map[string]any{}
+	var order []int
+	tasks := map[int]json.RawMessage{}
+	records := 0
+	torn := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var rec struct {
+			logRecord
+			Meta json.RawMessage `json:"meta,omitempty"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// A record cut short by a crash mid-append is the last line and
+			// has no trailing newline; everything before it is intact.
+			if !bytes.HasSuffix(data, []byte("\n")) && bytes.HasSuffix(data, scanner.Bytes()) {
+				torn = true
+				break
+			}
+			return nil, fmt.Errorf("%s: line %d: %w", s.Path, line, err)
+		}
+		records++
+
+		switch rec.Op {
+		case opMeta:
+			version = rec.Version
+			meta = map[string]any{}
+			if len(rec.Meta) > 0 {
+				if err := unmarshalRaw(rec.Meta, &meta); err != nil {
+					return nil, fmt.Errorf("%s: line %d: %w", s.Path, line, err)
+				}
+			}
+		case opPut:
+			if _, ok := tasks[rec.ID]; !ok {
+				order = append(order, rec.ID)
+			}
+			tasks[rec.ID] = rec.Task
+		case opDelete:
+			if _, ok := tasks[rec.ID]; ok {
+				delete(tasks, rec.ID)
+				for i, id := range order {
+					if id == rec.ID {
+						order = append(order[:i], order[i+1:]...)
+						break
+					}
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	raw := make([]json.RawMessage, 0, len(order))
+	for _, id := range order {
+		raw = append(raw, tasks[id])
+	}
+	envelope, err := json.Marshal(map[string]any{"version": version, "tasks": raw, "meta": meta})
+	if err != nil {
+		return nil, err
+	}
+	doc, migrated, err := readDocument(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.Path, err)
+	}
+	if migrated {
+		// compact backs up the old log when it rewrites it.
+		torn = true
+	}
+
+	s.meta = doc.Meta
+	if err := s.remember(doc.Tasks, records); err != nil {
+		return nil, err
+	}
+	s.rewrite = torn
+	return doc.Tasks, nil
+}
+
+func (s *LogStore) Save(todos Todos) error {
+	if s.synced == nil {
+		if _, err := s.Load(); err != nil && !isNotExist(err) {
+			return err
+		}
+	}
+
+	tasks := append(Todos(nil), todos...)
+	if _, err := tasks.assignIDs(&s.meta.NextID); err != nil {
+		return err
+	}
+
+	if s.rewrite || s.records == 0 {
+		return s.compact(tasks)
+	}
+
+	var changes []logRecord
+	live := make(map[int]bool, len(tasks))
+	for _, task := range tasks {
+		live[task.ID] = true
+		encoded, err := json.Marshal(task)
+		if err != nil {
+			return err
+		}
+		if old, ok := s.synced[task.ID]; ok && bytes.Equal(old, encoded) {
+			continue
+		}
+		changes = append(changes, logRecord{Op: opPut, ID: task.ID, Task: encoded})
+	}
+	for id := range s.synced {
+		if !live[id] {
+			changes = append(changes, logRecord{Op: opDelete, ID: id})
+		}
+	}
+	encodedMeta, err := json.Marshal(s.meta)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(encodedMeta, s.syncedMeta) {
+		meta := s.meta
+		changes = append(changes, logRecord{Op: opMeta, Version: SchemaVersion, Meta: &meta})
+	}
+
+	if s.records+len(changes) > 2*len(tasks)+compactSlack {
+		return s.compact(tasks)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, rec := range changes {
+		if err := writeRecord(&buf, rec); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(s.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return s.remember(tasks, s.records+len(changes))
+}
+
+func (s *LogStore) Meta() *Meta {
+	return &s.meta
+}
+
+func (s *LogStore) migrated() bool {
+	return s.rewrite
+}
+
+func (s *LogStore) Update(fn func(todos *Todos) error, saved ...func() error) error {
+	return withLock(s.Path, func() error {
+		return update(s, fn, saved)
+	})
+}
+
+// compact rewrites the log as a meta record followed by one put record per
+// task.
+func (s *LogStore) compact(tasks Todos) error {
+	if data, err := os.ReadFile(s.Path); err == nil {
+		from, ok := logVersion(data)
+		switch {
+		case ok && from > SchemaVersion:
+			return fmt.Errorf("%s: %w (schema version %d, this binary supports up to %d); refusing to overwrite it", s.Path, ErrNewerSchema, from, SchemaVersion)
+		case ok && from < SchemaVersion:
+			if err := recordMigration(&s.meta, s.Path, data, from); err != nil {
+				return err
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	meta := s.meta
+	if err := writeRecord(&buf, logRecord{Op: opMeta, Version: SchemaVersion, Meta: &meta}); err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		encoded, err := json.Marshal(task)
+		if err != nil {
+			return err
+		}
+		if err := writeRecord(&buf, logRecord{Op: opPut, ID: task.ID, Task: encoded}); err != nil {
+			return err
+		}
+	}
+	if err := writeFileAtomic(s.Path, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	s.rewrite = false
+	return s.remember(tasks, len(tasks)+1)
+}
+
+func (s *LogStore) remember(todos Todos, records int) error {
+	s.synced = make(map[int][]byte, len(todos))
+	for _, task := range todos {
+		encoded, err := json.Marshal(task)
+		if err != nil {
+			return err
+		}
+		s.synced[task.ID] = encoded
+	}
+	encodedMeta, err := json.Marshal(s.meta)
+	if err != nil {
+		return err
+	}
+	s.syncedMeta = encodedMeta
+	s.records = records
+	return nil
+}
+
+func writeRecord(buf *bytes.Buffer, rec logRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	buf.Write(line)
+	buf.WriteByte('\n')
+	return nil
+}
+
+// logVersion reports the schema version of a log: that of its last meta
+// record, or 1 for a log written before the schema was versioned. It is
+// false for an empty log.
+func logVersion(data []byte) (int, bool) {
+	version, ok := 1, false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var rec logRecord
+		if json.Unmarshal(scanner.Bytes(), &rec) != nil {
+			continue
+		}
+		ok = true
+		if rec.Op == opMeta {
+			version = rec.Version
+		}
+	}
+	return version, ok
+}
+
+func isNotExist(err error) bool {
+	return errors.Is(err, fs.ErrNotExist)
+}
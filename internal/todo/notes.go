@@ -0,0 +1,15 @@
+package todo
+
+import "time"
+
+// Annotate adds a remark to the task, made at the given time.
+func (t *Todo) Annotate(text string, at time.Time) {
+	t.Annotations = append(t.Annotations, Annotation{Time: at, Text: text})
+	t.RecordChange("Annotation", "", text)
+}
+
+// SetNotes replaces the task's notes.
+func (t *Todo) SetNotes(notes string) {
+	t.RecordChange("Notes", t.Notes, notes)
+	t.Notes = notes
+}
@@ -0,0 +1,32 @@
+package todo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnnotateAndNotes(t *testing.T) {
+	task := Todo{ID: 1, Task: "Vendor contract", Tags: []string{"work"}}
+	at := time.Date(2024, 7, 1, 14, 5, 0, 0, time.UTC)
+	task.Annotate("called vendor", at)
+	task.SetNotes("Contact: Jane\nhttps://example.com")
+
+	if len(task.Annotations) != 1 || !task.Annotations[0].Time.Equal(at) || task.Annotations[0].Text != "called vendor" {
+		t.Errorf("Expected one annotation, got %+v", task.Annotations)
+	}
+	if len(task.History) != 2 || task.History[0].Field != "Annotation" || task.History[1].Field != "Notes" {
+		t.Errorf("Expected the annotation and notes in the history, got %+v", task.History)
+	}
+}
+
+func TestNotesCarryOverToNextOccurrence(t *testing.T) {
+	due := date("2024-03-04")
+	todos := Todos{{ID: 1, Task: "Report", DueDate: &due, Recur: "FREQ=WEEKLY", Notes: "Template in the wiki"}}
+	todos[0].Annotate("sent late", due)
+	if err := todos.Complete(nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if next := todos[1]; next.Notes != "Template in the wiki" || len(next.Annotations) != 0 {
+		t.Errorf("Expected the notes but not the annotations to carry over, got %+v", next)
+	}
+}
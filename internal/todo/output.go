@@ -0,0 +1,364 @@
+package todo
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats are the output formats of listings. "table" is the one for
+// people; the others are for scripts, except Markdown, which is for pasting
+// into documents.
+var Formats = []string{"table", "json", "ndjson", "csv", "tsv", "markdown"}
+
+// ParseFormat checks an output format name.
+func ParseFormat(s string) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(s))
+	if !ContainsString(Formats, format) {
+		return "", fmt.Errorf("invalid output format: %s (use one of: %s)", s, strings.Join(Formats, ", "))
+	}
+	return format, nil
+}
+
+// OutputVersion is the version of the JSON written for listings. Fields may
+// be added without changing it; it goes up when a field is removed or
+// changes meaning.
+const OutputVersion = 1
+
+// TaskRecord is a task as written in JSON and NDJSON output, including the
+// values worked out from the rest of the list. Optional values are null
+// rather than left out, and lists are empty rather than null.
+type TaskRecord struct {
+	ID          int                `json:"id"`
+	UUID        string             `json:"uuid"`
+	Task        string             `json:"task"`
+	List        string             `json:"list"`
+	Status      string             `json:"status"` // workflow state, e.g. "todo" or "done"
+	Completed   bool               `json:"completed"`
+	Blocked     bool               `json:"blocked"`
+	Overdue     bool               `json:"overdue"`
+	Priority    string             `json:"priority"` // low, medium or high
+	Urgency     float64            `json:"urgency"`
+	Due         *string            `json:"due"`      // "2006-01-02", or RFC 3339 for a due time
+	DueZone     *string            `json:"due_zone"` // IANA zone a due time was given in
+	Tags        []string           `json:"tags"`
+	ParentID    *int               `json:"parent_id"`
+	DependsOn   []int              `json:"depends_on"`
+	Recur       *string            `json:"recur"` // RRULE
+	Notes       string             `json:"notes"`
+	Annotations []AnnotationRecord `json:"annotations"`
+	CreatedAt   *string            `json:"created_at"` // RFC 3339
+	UpdatedAt   *string            `json:"updated_at"`
+	CompletedAt *string            `json:"completed_at"`
+	Score       *float64           `json:"score,omitempty"` // search relevance, for search results only
+}
+
+// AnnotationRecord is an annotation in JSON output.
+type AnnotationRecord struct {
+	Time string `json:"time"` // RFC 3339
+	Text string `json:"text"`
+}
+
+// Record describes task, one of t, for JSON output.
+func (t Todos) Record(task Todo, now time.Time) TaskRecord {
+	r := TaskRecord{
+		ID:          task.ID,
+		UUID:        task.UUID,
+		Task:        task.Task,
+		List:        listName(task),
+		Status:      ActiveWorkflow.StateOf(task),
+		Completed:   task.Completed,
+		Blocked:     t.IsBlocked(task),
+		Overdue:     task.IsOverdue(now),
+		Priority:    strings.ToLower(task.Priority.String()),
+		Urgency:     math.Round(t.Urgency(task, now)*100) / 100,
+		Tags:        append([]string{}, task.Tags...),
+		DependsOn:   append([]int{}, task.DependsOn...),
+		Notes:       task.Notes,
+		Annotations: []AnnotationRecord{},
+		CreatedAt:   timestamp(task.CreatedAt),
+		UpdatedAt:   timestamp(task.UpdatedAt),
+		CompletedAt: timestamp(task.CompletedAt),
+	}
+	if due := dueValue(task); due != "" {
+		r.Due = &due
+	}
+	if task.DueZone != "" {
+		r.DueZone = &task.DueZone
+	}
+	if task.ParentID != 0 {
+		r.ParentID = &task.ParentID
+	}
+	if task.Recur != "" {
+		r.Recur = &task.Recur
+	}
+	for _, a := range task.Annotations {
+		r.Annotations = append(r.Annotations, AnnotationRecord{Time: a.Time.Format(time.RFC3339), Text: a.Text})
+	}
+	return r
+}
+
+// dueValue writes a due date for scripts: the date alone, or a due time in
+// RFC 3339 in the zone it was given in.
+func dueValue(task Todo) string {
+	due, ok := task.Due()
+	switch {
+	case !ok:
+		return ""
+	case task.hasDueTime():
+		return due.Format(time.RFC3339)
+	default:
+		return due.Format(dateFormat)
+	}
+}
+
+func timestamp(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(time.RFC3339)
+	return &s
+}
+
+// WriteTasks writes tasks, a subset of all, in one of the formats other than
+// "table", which is PrintTable's. JSON and NDJSON have every field of
+// TaskRecord; the others have the given columns. scores, if not nil, are
+// search scores by task ID, and add a score to each task.
+func WriteTasks(w io.Writer, format string, tasks, all Todos, columns []string, scores map[int]float64) error {
+	now := Now()
+	switch format {
+	case "json", "ndjson":
+		records := make([]TaskRecord, len(tasks))
+		for i, task := range tasks {
+			records[i] = all.Record(task, now)
+			if score, ok := scores[task.ID]; ok {
+				score = math.Round(score*100) / 100
+				records[i].Score = &score
+			}
+		}
+		if format == "json" {
+			return writeJSON(w, struct {
+				Version int          `json:"version"`
+				Tasks   []TaskRecord `json:"tasks"`
+			}{OutputVersion, records})
+		}
+		return writeNDJSON(w, records)
+	}
+
+	header := append([]string(nil), columns...)
+	if scores != nil {
+		header = append(header, "score")
+	}
+	var rows [][]string
+	if format == "markdown" {
+		// Markdown is for reading, so it keeps subtasks under their parent
+		// and shows values the way the table does.
+		for _, entry := range tasks.tree() {
+			task := tasks[entry.index]
+			row := make([]string, len(columns))
+			for i, name := range columns {
+				row[i] = markdownValue(name, task, entry.depth, all, now)
+			}
+			rows = append(rows, withScore(row, scores, task.ID))
+		}
+		for i, name := range header {
+			header[i] = columnHeader(name)
+		}
+		return writeMarkdown(w, header, rows)
+	}
+	for _, task := range tasks {
+		row := make([]string, len(columns))
+		for i, name := range columns {
+			row[i] = exportValue(name, task, all, now)
+		}
+		rows = append(rows, withScore(row, scores, task.ID))
+	}
+	return writeDelimited(w, format, header, rows)
+}
+
+// WriteStats writes the numbers viz charts in one of the formats other than
+// "table". JSON has the fields of Stats; the others have one row per number:
+// its group (priority, status, progress or subtasks), the key within the
+// group, the count, and the total it is out of.
+func WriteStats(w io.Writer, format string, s Stats) error {
+	if format == "json" {
+		return writeJSON(w, struct {
+			Version int `json:"version"`
+			Stats
+		}{OutputVersion, s})
+	}
+
+	var rows []statRow
+	add := func(group, key string, count, total int) {
+		rows = append(rows, statRow{group, key, count, total})
+	}
+	for _, priority := range []Priority{Low, Medium, High} {
+		key := strings.ToLower(priority.String())
+		add("priority", key, s.ByPriority[key], s.Total)
+	}
+	for _, state := range s.states {
+		add("status", state, s.ByStatus[state], s.Total)
+	}
+	add("progress", "completed", s.Completed, s.Total)
+	for _, sub := range s.Subtasks {
+		add("subtasks", strconv.Itoa(sub.ID), sub.Done, sub.Total)
+	}
+	if format == "ndjson" {
+		return writeNDJSON(w, rows)
+	}
+
+	cells := make([][]string, len(rows))
+	for i, row := range rows {
+		cells[i] = []string{row.Group, row.Key, strconv.Itoa(row.Count), strconv.Itoa(row.Total)}
+	}
+	if format == "markdown" {
+		return writeMarkdown(w, []string{"Group", "Key", "Count", "Total"}, cells)
+	}
+	return writeDelimited(w, format, []string{"group", "key", "count", "total"}, cells)
+}
+
+// statRow is a row of WriteStats' output.
+type statRow struct {
+	Group string `json:"group"`
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+	Total int    `json:"total"`
+}
+
+func withScore(row []string, scores map[int]float64, id int) []string {
+	if scores == nil {
+		return row
+	}
+	return append(row, strconv.FormatFloat(scores[id], 'f', 2, 64))
+}
+
+func columnHeader(name string) string {
+	if header, ok := columnHeaders[name]; ok {
+		return header
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// markdownValue is a column's value for Markdown: the table's, except that
+// notes and annotations are given in full, since a cell can hold line breaks.
+func markdownValue(column string, task Todo, depth int, all Todos, now time.Time) string {
+	switch column {
+	case "notes":
+		return task.Notes
+	case "annotations":
+		return formatAnnotations(task.Annotations, "2006-01-02 15:04", "\n")
+	}
+	return cellValue(column, task, depth, all, now)
+}
+
+// exportValue is a column's value for CSV and TSV: unpadded, in the same
+// forms as JSON, and empty when missing.
+func exportValue(column string, task Todo, all Todos, now time.Time) string {
+	switch column {
+	case "id":
+		return strconv.Itoa(task.ID)
+	case "task":
+		return task.Task
+	case "due":
+		return dueValue(task)
+	case "priority":
+		return strings.ToLower(task.Priority.String())
+	case "urgency":
+		return strconv.FormatFloat(all.Urgency(task, now), 'f', 2, 64)
+	case "status":
+		if all.IsBlocked(task) {
+			return "blocked"
+		}
+		return ActiveWorkflow.StateOf(task)
+	case "tags":
+		return strings.Join(task.Tags, ",")
+	case "list":
+		return listName(task)
+	case "parent":
+		if task.ParentID == 0 {
+			return ""
+		}
+		return strconv.Itoa(task.ParentID)
+	case "notes":
+		return task.Notes
+	case "annotations":
+		return formatAnnotations(task.Annotations, time.RFC3339, "\n")
+	case "created", "updated", "completed":
+		t := map[string]*time.Time{"created": task.CreatedAt, "updated": task.UpdatedAt, "completed": task.CompletedAt}[column]
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	return ""
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeNDJSON[T any](w io.Writer, values []T) error {
+	enc := json.NewEncoder(w)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDelimited writes CSV, or TSV, where tabs, line breaks and
+// backslashes in values are escaped as \t, \n and \\.
+func writeDelimited(w io.Writer, format string, header []string, rows [][]string) error {
+	if format == "csv" {
+		cw := csv.NewWriter(w)
+		cw.Write(header)
+		cw.WriteAll(rows)
+		return cw.Error()
+	}
+	escape := strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+	for _, row := range append([][]string{header}, rows...) {
+		values := make([]string, len(row))
+		for i, v := range row {
+			values[i] = escape.Replace(v)
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(values, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeMarkdown writes a Markdown table, escaping pipes and putting line
+// breaks as <br>.
+func writeMarkdown(w io.Writer, header []string, rows [][]string) error {
+	escape := strings.NewReplacer("|", `\|`, "\n", "<br>")
+	line := func(values []string) string {
+		escaped := make([]string, len(values))
+		for i, v := range values {
+			escaped[i] = escape.Replace(v)
+		}
+		return "| " + strings.Join(escaped, " | ") + " |"
+	}
+	divider := make([]string, len(header))
+	for i := range divider {
+		divider[i] = "---"
+	}
+	if _, err := fmt.Fprintln(w, line(header)); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "|"+strings.Join(divider, "|")+"|")
+	for _, row := range rows {
+		if _, err := fmt.Fprintln(w, line(row)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func outputTasks() Todos {
+	due := date("2024-01-02")
+	created := time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC)
+	return Todos{
+		{ID: 1, UUID: "a1", Task: "Write report", DueDate: &due, Priority: High, Tags: []string{"work", "q3"},
+			Notes: "line one\nline|two\twith tab", CreatedAt: &created, DependsOn: []int{3},
+			Annotations: []Annotation{
+				{Time: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), Text: "called the vendor"},
+				{Time: time.Date(2024, 1, 2, 16, 45, 0, 0, time.UTC), Text: "sent draft\tfor review"},
+			}},
+		{ID: 2, UUID: "b2", Task: `Sub, "quoted"`, ParentID: 1},
+		{ID: 3, UUID: "c3", Task: "Call Bob", Completed: true, Status: "done"},
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if format, err := ParseFormat(" JSON "); err != nil || format != "json" {
+		t.Errorf("Expected json, got %q, %v", format, err)
+	}
+	if _, err := ParseFormat("xml"); err == nil || !strings.Contains(err.Error(), "ndjson") {
+		t.Errorf("Expected an error listing the formats, got %v", err)
+	}
+}
+
+func TestWriteTasksJSON(t *testing.T) {
+	todos := outputTasks()
+	var buf bytes.Buffer
+	if err := WriteTasks(&buf, "json", todos, todos, nil, map[int]float64{1: 1.234}); err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Version int              `json:"version"`
+		Tasks   []map[string]any `json:"tasks"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, buf.String())
+	}
+	if out.Version != OutputVersion || len(out.Tasks) != 3 {
+		t.Fatalf("Expected version %d and 3 tasks, got %s", OutputVersion, buf.String())
+	}
+
+	first, second := out.Tasks[0], out.Tasks[1]
+	checks := []struct {
+		name      string
+		got, want any
+	}{
+		{"id", first["id"], 1.0},
+		{"due", first["due"], "2024-01-02"},
+		{"priority", first["priority"], "high"},
+		{"status", first["status"], "todo"},
+		{"blocked", first["blocked"], false},
+		{"overdue", first["overdue"], true},
+		{"created_at", first["created_at"], "2024-01-01T09:30:00Z"},
+		{"score", first["score"], 1.23},
+		{"parent_id", second["parent_id"], 1.0},
+		{"due of a task without one", second["due"], nil},
+		{"completed_at of an open task", second["completed_at"], nil},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, c.got)
+		}
+	}
+	if tags, ok := second["tags"].([]any); !ok || len(tags) != 0 {
+		t.Errorf("Expected an empty tags list rather than null, got %v", second["tags"])
+	}
+	if _, ok := second["score"]; ok {
+		t.Errorf("Expected no score for a task without one")
+	}
+}
+
+func TestWriteTasksNDJSON(t *testing.T) {
+	todos := outputTasks()
+	var buf bytes.Buffer
+	if err := WriteTasks(&buf, "ndjson", todos, todos, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected one line per task, got %q", buf.String())
+	}
+	var record TaskRecord
+	if err := json.Unmarshal([]byte(lines[2]), &record); err != nil || record.ID != 3 || !record.Completed {
+		t.Errorf("Expected the third line to be task 3, got %+v, %v", record, err)
+	}
+}
+
+func TestWriteTasksCSV(t *testing.T) {
+	todos := outputTasks()
+	var buf bytes.Buffer
+	if err := WriteTasks(&buf, "csv", todos, todos, []string{"id", "task", "tags", "notes", "parent"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"id", "task", "tags", "notes", "parent"},
+		{"1", "Write report", "work,q3", "line one\nline|two\twith tab", ""},
+		{"2", `Sub, "quoted"`, "", "", "1"},
+		{"3", "Call Bob", "", "", ""},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("Expected %d rows, got %q", len(want), rows)
+	}
+	for i := range want {
+		if strings.Join(rows[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("Row %d: expected %q, got %q", i, want[i], rows[i])
+		}
+	}
+}
+
+func TestWriteTasksTSVEscapes(t *testing.T) {
+	todos := outputTasks()
+	var buf bytes.Buffer
+	if err := WriteTasks(&buf, "tsv", todos[:1], todos, []string{"id", "notes"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "id\tnotes\n1\tline one\\nline|two\\twith tab\n"
+	if buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestWriteTasksMarkdown(t *testing.T) {
+	todos := outputTasks()
+	var buf bytes.Buffer
+	if err := WriteTasks(&buf, "markdown", todos, todos, []string{"id", "task", "notes"}, map[int]float64{1: 2, 2: 1, 3: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{
+		"| ID | Task | Notes | Score |",
+		"|---|---|---|---|",
+		"| 1 | Write report | line one<br>line\\|two\twith tab | 2.00 |",
+		`| 2 | - Sub, "quoted" |  | 1.00 |`,
+		`| 3 | Call Bob |  | 0.50 |`,
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected\n%s\ngot\n%s", strings.Join(want, "\n"), buf.String())
+	}
+
+	buf.Reset()
+	WriteTasks(&buf, "markdown", Todos{{ID: 4, Task: "a|b"}}, todos, []string{"task"}, nil)
+	if !strings.Contains(buf.String(), `| a\|b |`) {
+		t.Errorf("Expected pipes to be escaped, got %q", buf.String())
+	}
+}
+
+func TestWriteTasksRoundTripsNotesAndAnnotations(t *testing.T) {
+	todos := outputTasks()
+	want := todos[0]
+	parseAnnotations := func(t *testing.T, value, layout string) []Annotation {
+		t.Helper()
+		var annotations []Annotation
+		for _, line := range strings.Split(value, "\n") {
+			stamp, text, _ := strings.Cut(line, " ")
+			if layout != time.RFC3339 {
+				// Markdown's times have a space in them.
+				clock, rest, _ := strings.Cut(text, " ")
+				stamp, text = stamp+" "+clock, rest
+			}
+			at, err := time.ParseInLocation(layout, stamp, time.Local)
+			if err != nil {
+				t.Fatalf("Invalid annotation %q: %v", line, err)
+			}
+			annotations = append(annotations, Annotation{Time: at, Text: text})
+		}
+		return annotations
+	}
+	check := func(t *testing.T, notes string, annotations []Annotation) {
+		t.Helper()
+		if notes != want.Notes {
+			t.Errorf("Expected notes %q, got %q", want.Notes, notes)
+		}
+		if len(annotations) != len(want.Annotations) {
+			t.Fatalf("Expected %d annotations, got %+v", len(want.Annotations), annotations)
+		}
+		for i, a := range annotations {
+			if !a.Time.Equal(want.Annotations[i].Time) || a.Text != want.Annotations[i].Text {
+				t.Errorf("Annotation %d: expected %+v, got %+v", i, want.Annotations[i], a)
+			}
+		}
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := WriteTasks(&buf, "csv", todos, todos, Columns, nil); err != nil {
+			t.Fatal(err)
+		}
+		rows, err := csv.NewReader(&buf).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields := map[string]string{}
+		for i, name := range rows[0] {
+			fields[name] = rows[1][i]
+		}
+		check(t, fields["notes"], parseAnnotations(t, fields["annotations"], time.RFC3339))
+	})
+
+	t.Run("tsv", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := WriteTasks(&buf, "tsv", todos, todos, Columns, nil); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(buf.String(), "\n")
+		unescape := strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n", `\r`, "\r")
+		header, row := strings.Split(lines[0], "\t"), strings.Split(lines[1], "\t")
+		fields := map[string]string{}
+		for i, name := range header {
+			fields[name] = unescape.Replace(row[i])
+		}
+		check(t, fields["notes"], parseAnnotations(t, fields["annotations"], time.RFC3339))
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := WriteTasks(&buf, "markdown", todos[:1], todos, []string{"notes", "annotations"}, nil); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(buf.String(), "\n")
+		// Hide escaped pipes while splitting the row into cells.
+		row := strings.ReplaceAll(strings.Trim(lines[2], "| "), `\|`, "\x00")
+		unescape := strings.NewReplacer("\x00", "|", "<br>", "\n")
+		cells := strings.Split(row, " | ")
+		check(t, unescape.Replace(cells[0]), parseAnnotations(t, unescape.Replace(cells[1]), "2006-01-02 15:04"))
+	})
+}
+
+func TestWriteStats(t *testing.T) {
+	todos := outputTasks()
+	stats := todos.Stats()
+	if stats.Total != 3 || stats.Completed != 1 || stats.Progress != 33.3 {
+		t.Errorf("Expected 1 of 3 done, got %+v", stats)
+	}
+	if stats.ByPriority["low"] != 2 || stats.ByPriority["medium"] != 0 || stats.ByStatus["done"] != 1 || stats.ByStatus["waiting"] != 0 {
+		t.Errorf("Unexpected counts: %+v", stats)
+	}
+	if len(stats.Subtasks) != 1 || stats.Subtasks[0] != (SubtaskStats{1, "Write report", 0, 1}) {
+		t.Errorf("Expected the subtask progress of task 1, got %+v", stats.Subtasks)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteStats(&buf, "csv", stats); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"group,key,count,total\n", "priority,high,1,3\n", "status,done,1,3\n", "progress,completed,1,3\n", "subtasks,1,0,1\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := WriteStats(&buf, "json", stats); err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil || out["version"] != 1.0 || out["progress"] != 33.3 {
+		t.Errorf("Expected versioned stats, got %s (%v)", buf.String(), err)
+	}
+}
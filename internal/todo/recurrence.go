@@ -0,0 +1,607 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidRecurrence is returned for recurrence rules that cannot be parsed.
+var ErrInvalidRecurrence = errors.New("invalid recurrence rule")
+
+// Frequency is the basic period a recurring task repeats in.
+type Frequency int
+
+const (
+	Daily Frequency = iota + 1
+	Weekly
+	Monthly
+	Yearly
+)
+
+var frequencyNames = map[Frequency]string{
+	Daily:   "DAILY",
+	Weekly:  "WEEKLY",
+	Monthly: "MONTHLY",
+	Yearly:  "YEARLY",
+}
+
+// WeekdayNum is a BYDAY entry: a weekday, optionally limited to its Nth
+// occurrence in the month (N > 0) or the Nth from the end (N < 0).
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+// Recurrence is a recurrence rule, a subset of RFC 5545 RRULE: FREQ,
+// INTERVAL, BYDAY, BYMONTHDAY, BYMONTH, BYSETPOS, COUNT and UNTIL.
+type Recurrence struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []int
+	BySetPos   []int
+	// Count is how many occurrences are left, this one included; 0 means no
+	// limit.
+	Count int
+	// Until is the last time an occurrence may fall on. UntilDate means
+	// UNTIL gave only a date, which lasts until the end of that day in the
+	// series' own zone; Until is then midnight UTC of it.
+	Until     *time.Time
+	UntilDate bool
+}
+
+// maxPeriods bounds the search for the next occurrence of rules that can
+// never match, such as February 30th.
+const maxPeriods = 1000
+
+var weekdayCodes = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+var weekdayNames = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+var businessDays = []WeekdayNum{{Day: time.Monday}, {Day: time.Tuesday}, {Day: time.Wednesday}, {Day: time.Thursday}, {Day: time.Friday}}
+
+// ParseRecurrence parses an RRULE ("FREQ=WEEKLY;BYDAY=MO,FR", optionally
+// prefixed with "RRULE:") or one of these phrases:
+//
+//	daily, weekly, monthly, yearly
+//	every day, every 3 weeks, every other month
+//	every weekday, every monday and thursday, every 2 weeks on mon,fri
+//	first business day of the month, last business day of the month
+//	last day of the month
+func ParseRecurrence(s string) (Recurrence, error) {
+	text := strings.TrimSpace(s)
+	if text == "" {
+		return Recurrence{}, fmt.Errorf("%w: empty rule", ErrInvalidRecurrence)
+	}
+	upper := strings.ToUpper(text)
+	if strings.HasPrefix(upper, "RRULE:") || strings.HasPrefix(upper, "FREQ=") || strings.Contains(upper, ";") {
+		return parseRRule(strings.TrimPrefix(upper, "RRULE:"))
+	}
+	return parseRecurrencePhrase(strings.ToLower(text))
+}
+
+func parseRRule(s string) (Recurrence, error) {
+	var r Recurrence
+	invalid := func(format string, args ...any) (Recurrence, error) {
+		return Recurrence{}, fmt.Errorf("%w: %s", ErrInvalidRecurrence, fmt.Sprintf(format, args...))
+	}
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return invalid("%q is not KEY=VALUE", part)
+		}
+		var err error
+		switch key {
+		case "FREQ":
+			r.Freq = 0
+			for freq, name := range frequencyNames {
+				if name == value {
+					r.Freq = freq
+				}
+			}
+			if r.Freq == 0 {
+				return invalid("unsupported FREQ %s", value)
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err != nil || r.Interval < 1 {
+				return invalid("INTERVAL must be a positive number")
+			}
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				day, err := parseWeekdayNum(code)
+				if err != nil {
+					return invalid("%v", err)
+				}
+				r.ByDay = append(r.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			if r.ByMonthDay, err = parseInts(value, -31, 31); err != nil {
+				return invalid("BYMONTHDAY: %v", err)
+			}
+		case "BYMONTH":
+			if r.ByMonth, err = parseInts(value, 1, 12); err != nil {
+				return invalid("BYMONTH: %v", err)
+			}
+		case "BYSETPOS":
+			if r.BySetPos, err = parseInts(value, -366, 366); err != nil {
+				return invalid("BYSETPOS: %v", err)
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err != nil || r.Count < 1 {
+				return invalid("COUNT must be a positive number")
+			}
+		case "UNTIL":
+			until, dateOnly, err := parseUntil(value)
+			if err != nil {
+				return invalid("UNTIL: %v", err)
+			}
+			r.Until, r.UntilDate = &until, dateOnly
+		case "WKST":
+			if value != "MO" {
+				return invalid("only WKST=MO is supported")
+			}
+		default:
+			return invalid("unsupported part %s", key)
+		}
+	}
+	if r.Freq == 0 {
+		return invalid("FREQ is required")
+	}
+	if r.Count > 0 && r.Until != nil {
+		return invalid("COUNT and UNTIL cannot both be given")
+	}
+	for _, day := range r.ByDay {
+		if day.N != 0 && r.Freq != Monthly && !(r.Freq == Yearly && len(r.ByMonth) > 0) {
+			return invalid("numbered BYDAY needs FREQ=MONTHLY, or FREQ=YEARLY with BYMONTH")
+		}
+	}
+	return r, nil
+}
+
+func parseWeekdayNum(code string) (WeekdayNum, error) {
+	code = strings.TrimSpace(code)
+	if len(code) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", code)
+	}
+	day := -1
+	for i, c := range weekdayCodes {
+		if c == code[len(code)-2:] {
+			day = i
+		}
+	}
+	if day < 0 {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", code)
+	}
+	n := 0
+	if prefix := code[:len(code)-2]; prefix != "" {
+		var err error
+		n, err = strconv.Atoi(strings.TrimPrefix(prefix, "+"))
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", code)
+		}
+	}
+	return WeekdayNum{N: n, Day: time.Weekday(day)}, nil
+}
+
+func parseInts(value string, min, max int) ([]int, error) {
+	var values []int
+	for _, field := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimPrefix(field, "+"))
+		if err != nil || n == 0 || n < min || n > max {
+			return nil, fmt.Errorf("invalid value %q", field)
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
+
+// parseUntil reads an UNTIL time, or a date, reporting which it was.
+func parseUntil(value string) (time.Time, bool, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.Parse("20060102", value); err == nil {
+		return t, true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid date %q", value)
+}
+
+func parseRecurrencePhrase(s string) (Recurrence, error) {
+	s = strings.Join(strings.Fields(s), " ")
+	switch s {
+	case "daily":
+		return Recurrence{Freq: Daily}, nil
+	case "weekly":
+		return Recurrence{Freq: Weekly}, nil
+	case "monthly":
+		return Recurrence{Freq: Monthly}, nil
+	case "yearly", "annually":
+		return Recurrence{Freq: Yearly}, nil
+	case "every weekday", "every business day", "weekdays":
+		return Recurrence{Freq: Weekly, ByDay: businessDays}, nil
+	}
+	if rest, ok := cutOfMonth(s); ok {
+		switch rest {
+		case "first business day", "first weekday":
+			return Recurrence{Freq: Monthly, ByDay: businessDays, BySetPos: []int{1}}, nil
+		case "last business day", "last weekday":
+			return Recurrence{Freq: Monthly, ByDay: businessDays, BySetPos: []int{-1}}, nil
+		case "last day":
+			return Recurrence{Freq: Monthly, ByMonthDay: []int{-1}}, nil
+		}
+	}
+
+	rest, ok := strings.CutPrefix(s, "every ")
+	if !ok {
+		rest, ok = strings.CutPrefix(s, "weekly on ")
+		if !ok {
+			return Recurrence{}, fmt.Errorf("%w: %q", ErrInvalidRecurrence, s)
+		}
+		rest = "week on " + rest
+	}
+	r := Recurrence{Interval: 1}
+	fields := strings.Fields(rest)
+	if n, err := strconv.Atoi(fields[0]); err == nil && n > 0 && len(fields) > 1 {
+		r.Interval = n
+		fields = fields[1:]
+	} else if fields[0] == "other" && len(fields) > 1 {
+		r.Interval = 2
+		fields = fields[1:]
+	}
+
+	unit, days, _ := strings.Cut(strings.Join(fields, " "), " on ")
+	switch strings.TrimSuffix(unit, "s") {
+	case "day":
+		r.Freq = Daily
+	case "week":
+		r.Freq = Weekly
+	case "month":
+		r.Freq = Monthly
+	case "year":
+		r.Freq = Yearly
+	default:
+		// "every monday and thursday"
+		if days != "" || r.Interval != 1 {
+			return Recurrence{}, fmt.Errorf("%w: %q", ErrInvalidRecurrence, s)
+		}
+		r.Freq, days = Weekly, unit
+	}
+	if days != "" {
+		if r.Freq != Weekly {
+			return Recurrence{}, fmt.Errorf("%w: weekdays can only be given for weekly rules", ErrInvalidRecurrence)
+		}
+		for _, name := range strings.FieldsFunc(strings.ReplaceAll(days, " and ", ","), func(c rune) bool { return c == ',' || c == ' ' }) {
+			day, ok := weekdayNames[name]
+			if !ok {
+				return Recurrence{}, fmt.Errorf("%w: unknown weekday %q", ErrInvalidRecurrence, name)
+			}
+			r.ByDay = append(r.ByDay, WeekdayNum{Day: day})
+		}
+	}
+	return r, nil
+}
+
+// cutOfMonth strips "of month" or "of the month" from the end of s.
+func cutOfMonth(s string) (string, bool) {
+	for _, suffix := range []string{" of the month", " of month", " of every month"} {
+		if rest, ok := strings.CutSuffix(s, suffix); ok {
+			return rest, true
+		}
+	}
+	return "", false
+}
+
+// String returns the rule as an RRULE value, without the "RRULE:" prefix.
+func (r Recurrence) String() string {
+	parts := []string{"FREQ=" + frequencyNames[r.Freq]}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinInts(r.ByMonth))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByDay) > 0 {
+		codes := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			codes[i] = weekdayCodes[day.Day]
+			if day.N != 0 {
+				codes[i] = strconv.Itoa(day.N) + codes[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if len(r.BySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinInts(r.BySetPos))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		layout := "20060102T150405Z"
+		if r.UntilDate {
+			layout = "20060102"
+		}
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(layout))
+	}
+	return strings.Join(parts, ";")
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Next returns the first occurrence after from, which is taken to be an
+// occurrence itself: periods are counted from the one containing it, and
+// times of day and days of the month default to its. It reports false once
+// the rule has ended.
+func (r Recurrence) Next(from time.Time) (time.Time, bool) {
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	for k := 0; k < maxPeriods; k++ {
+		for _, candidate := range r.occurrences(r.periodStart(from, k*interval), from) {
+			if !candidate.After(from) {
+				continue
+			}
+			if r.Until != nil && candidate.After(r.end(candidate.Location())) {
+				return time.Time{}, false
+			}
+			return candidate, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// end returns the last time an occurrence may fall on, for a series in loc.
+func (r Recurrence) end(loc *time.Location) time.Time {
+	if !r.UntilDate {
+		return *r.Until
+	}
+	y, m, d := r.Until.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, loc).Add(-time.Nanosecond)
+}
+
+// periodStart returns the first day of the period n periods after the one
+// containing from.
+func (r Recurrence) periodStart(from time.Time, n int) time.Time {
+	y, m, d := from.Date()
+	h, mi, s := from.Clock()
+	loc := from.Location()
+	switch r.Freq {
+	case Weekly:
+		// Weeks start on Monday.
+		offset := (int(from.Weekday()) + 6) % 7
+		return time.Date(y, m, d-offset+7*n, h, mi, s, 0, loc)
+	case Monthly:
+		return time.Date(y, m+time.Month(n), 1, h, mi, s, 0, loc)
+	case Yearly:
+		return time.Date(y+n, time.January, 1, h, mi, s, 0, loc)
+	default:
+		return time.Date(y, m, d+n, h, mi, s, 0, loc)
+	}
+}
+
+// occurrences returns the occurrences in the period starting at start, in
+// order.
+func (r Recurrence) occurrences(start, anchor time.Time) []time.Time {
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		days = []time.Time{start}
+	case Weekly:
+		for i := 0; i < 7; i++ {
+			day := start.AddDate(0, 0, i)
+			if len(r.ByDay) > 0 || day.Weekday() == anchor.Weekday() {
+				days = append(days, day)
+			}
+		}
+	case Monthly:
+		days = r.daysInMonth(start, anchor)
+	case Yearly:
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []int{int(anchor.Month())}
+		}
+		for _, month := range months {
+			days = append(days, r.daysInMonth(start.AddDate(0, month-1, 0), anchor)...)
+		}
+	}
+
+	var matched []time.Time
+	for _, day := range days {
+		if r.matches(day) {
+			matched = append(matched, day)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Before(matched[j]) })
+	if len(r.BySetPos) == 0 {
+		return matched
+	}
+	var picked []time.Time
+	for _, pos := range r.BySetPos {
+		i := pos - 1
+		if pos < 0 {
+			i = len(matched) + pos
+		}
+		if i >= 0 && i < len(matched) {
+			picked = append(picked, matched[i])
+		}
+	}
+	sort.Slice(picked, func(i, j int) bool { return picked[i].Before(picked[j]) })
+	return picked
+}
+
+// daysInMonth returns the candidate days of the month starting at first.
+func (r Recurrence) daysInMonth(first, anchor time.Time) []time.Time {
+	last := first.AddDate(0, 1, -1).Day()
+	var days []time.Time
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, d := range r.ByMonthDay {
+			if d < 0 {
+				d = last + 1 + d
+			}
+			if d >= 1 && d <= last {
+				days = append(days, first.AddDate(0, 0, d-1))
+			}
+		}
+	case len(r.ByDay) > 0:
+		for _, wd := range r.ByDay {
+			var matching []time.Time
+			for d := 0; d < last; d++ {
+				if day := first.AddDate(0, 0, d); day.Weekday() == wd.Day {
+					matching = append(matching, day)
+				}
+			}
+			switch {
+			case wd.N == 0:
+				days = append(days, matching...)
+			case wd.N > 0 && wd.N <= len(matching):
+				days = append(days, matching[wd.N-1])
+			case wd.N < 0 && -wd.N <= len(matching):
+				days = append(days, matching[len(matching)+wd.N])
+			}
+		}
+	default:
+		if d := anchor.Day(); d <= last {
+			days = append(days, first.AddDate(0, 0, d-1))
+		}
+	}
+	return days
+}
+
+// matches applies the BY* filters that limit, rather than expand, the
+// occurrences of a period.
+func (r Recurrence) matches(day time.Time) bool {
+	if len(r.ByMonth) > 0 && !containsInt(r.ByMonth, int(day.Month())) {
+		return false
+	}
+	if len(r.ByDay) > 0 {
+		found := false
+		for _, wd := range r.ByDay {
+			if wd.Day == day.Weekday() {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.ByMonthDay) > 0 && r.Freq != Monthly && r.Freq != Yearly {
+		last := day.AddDate(0, 1, -day.Day()).Day()
+		if !containsInt(r.ByMonthDay, day.Day()) && !containsInt(r.ByMonthDay, day.Day()-last-1) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// spawnNext adds the next occurrence of the recurring task at index, which
+// was just completed. The occurrence is due on the rule's next date after the
+// task's due date, or after today if it had none.
+func (t *Todos) spawnNext(meta *Meta, index int) error {
+	task := &(*t)[index]
+	if task.Recur == "" {
+		return nil
+	}
+	rule, err := ParseRecurrence(task.Recur)
+	if err != nil {
+		return fmt.Errorf("task %d: %w", task.ID, err)
+	}
+	if rule.Count == 1 {
+		return nil
+	}
+	from := Now().UTC().Truncate(24 * time.Hour)
+	if due, ok := task.Due(); ok {
+		// In the task's own zone, so that a due time stays the same wall
+		// clock time across daylight saving changes.
+		from = due
+	}
+	next, ok := rule.Next(from)
+	if !ok {
+		return nil
+	}
+	if rule.Count > 0 {
+		rule.Count--
+	}
+	uuid, err := newUUID()
+	if err != nil {
+		return err
+	}
+	if task.SeriesID == "" {
+		task.SeriesID = task.UUID
+		if task.SeriesID == "" {
+			task.SeriesID = uuid
+		}
+	}
+
+	now := time.Now()
+	spawned := Todo{
+		ID:        meta.newID(*t),
+		UUID:      uuid,
+		Task:      task.Task,
+		DueDate:   &next,
+		DueZone:   task.DueZone,
+		Priority:  task.Priority,
+		Tags:      append([]string(nil), task.Tags...),
+		List:      task.List,
+		ParentID:  task.ParentID,
+		Recur:     rule.String(),
+		SeriesID:  task.SeriesID,
+		Notes:     task.Notes,
+		CreatedAt: &now,
+		UpdatedAt: &now,
+	}
+	*t = append(*t, spawned)
+	return nil
+}
+
+// Series returns the occurrences of the series with the given ID, in list
+// order.
+func (t Todos) Series(id string) Todos {
+	series := Todos{}
+	if id == "" {
+		return series
+	}
+	for _, task := range t {
+		if task.SeriesID == id {
+			series = append(series, task)
+		}
+	}
+	return series
+}
@@ -0,0 +1,139 @@
+package todo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestParseRecurrence(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"daily", "FREQ=DAILY"},
+		{"every 3 weeks", "FREQ=WEEKLY;INTERVAL=3"},
+		{"every other month", "FREQ=MONTHLY;INTERVAL=2"},
+		{"Every Year", "FREQ=YEARLY"},
+		{"every weekday", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"},
+		{"every monday and thursday", "FREQ=WEEKLY;BYDAY=MO,TH"},
+		{"every 2 weeks on mon,fri", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR"},
+		{"weekly on tue", "FREQ=WEEKLY;BYDAY=TU"},
+		{"last business day of the month", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1"},
+		{"last day of month", "FREQ=MONTHLY;BYMONTHDAY=-1"},
+		{"RRULE:FREQ=MONTHLY;BYDAY=2TU;COUNT=5", "FREQ=MONTHLY;BYDAY=2TU;COUNT=5"},
+		{"freq=yearly;bymonth=3;bymonthday=15;until=20301231", "FREQ=YEARLY;BYMONTH=3;BYMONTHDAY=15;UNTIL=20301231"},
+	}
+	for _, tt := range tests {
+		rule, err := ParseRecurrence(tt.input)
+		if err != nil {
+			t.Errorf("ParseRecurrence(%q): unexpected error %v", tt.input, err)
+			continue
+		}
+		if rule.String() != tt.want {
+			t.Errorf("ParseRecurrence(%q) = %s, expected %s", tt.input, rule, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "sometimes", "every 2 fortnights", "FREQ=HOURLY", "FREQ=WEEKLY;BYDAY=2MO", "FREQ=DAILY;COUNT=2;UNTIL=20300101", "every month on monday"} {
+		if _, err := ParseRecurrence(input); !errors.Is(err, ErrInvalidRecurrence) {
+			t.Errorf("ParseRecurrence(%q): expected ErrInvalidRecurrence, got %v", input, err)
+		}
+	}
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	tests := []struct {
+		rule string
+		from string
+		want string
+	}{
+		{"daily", "2024-02-28", "2024-02-29"},
+		{"every 2 weeks", "2024-03-06", "2024-03-20"},
+		{"every monday and thursday", "2024-03-04", "2024-03-07"},
+		{"every monday and thursday", "2024-03-07", "2024-03-11"},
+		{"every 2 weeks on mon,fri", "2024-03-08", "2024-03-18"},
+		{"monthly", "2024-01-31", "2024-03-31"},
+		{"yearly", "2024-02-29", "2028-02-29"},
+		{"last business day of the month", "2024-03-29", "2024-04-30"},
+		{"last business day of the month", "2024-05-31", "2024-06-28"},
+		{"first business day of the month", "2024-06-03", "2024-07-01"},
+		{"last day of month", "2024-01-31", "2024-02-29"},
+		{"FREQ=MONTHLY;BYDAY=-1FR", "2024-03-29", "2024-04-26"},
+		{"FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", "2024-11-28", "2025-11-27"},
+	}
+	for _, tt := range tests {
+		rule, err := ParseRecurrence(tt.rule)
+		if err != nil {
+			t.Fatalf("ParseRecurrence(%q): %v", tt.rule, err)
+		}
+		next, ok := rule.Next(date(tt.from))
+		if !ok || !next.Equal(date(tt.want)) {
+			t.Errorf("%s after %s: expected %s, got %s (%v)", tt.rule, tt.from, tt.want, next.Format("2006-01-02"), ok)
+		}
+	}
+
+	rule, _ := ParseRecurrence("FREQ=DAILY;UNTIL=20240301")
+	if _, ok := rule.Next(date("2024-03-01")); ok {
+		t.Errorf("Expected no occurrence after UNTIL")
+	}
+
+	// A date-only UNTIL takes in the whole of that day where the series is.
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	rule, _ = ParseRecurrence("FREQ=DAILY;UNTIL=20261031")
+	from := time.Date(2026, 10, 30, 9, 0, 0, 0, berlin)
+	if next, ok := rule.Next(from); !ok || !next.Equal(from.AddDate(0, 0, 1)) {
+		t.Errorf("Expected the 09:00 occurrence on the UNTIL date, got %s (%v)", next, ok)
+	}
+	if _, ok := rule.Next(from.AddDate(0, 0, 1)); ok {
+		t.Errorf("Expected no occurrence the day after UNTIL")
+	}
+	if rule.String() != "FREQ=DAILY;UNTIL=20261031" {
+		t.Errorf("Expected the date-only UNTIL to be kept, got %s", rule.String())
+	}
+	rule, _ = ParseRecurrence("FREQ=DAILY;UNTIL=20261031T000000Z")
+	if _, ok := rule.Next(from); ok || rule.String() != "FREQ=DAILY;UNTIL=20261031T000000Z" {
+		t.Errorf("Expected an UNTIL time to be kept exactly, got %s (%v)", rule.String(), ok)
+	}
+}
+
+func TestCompleteSpawnsNextOccurrence(t *testing.T) {
+	due := date("2024-03-04")
+	todos := Todos{}
+	todos.Add(nil, "Standup notes", &due, Medium, []string{"work"})
+	todos[0].Recur = "FREQ=WEEKLY;COUNT=2"
+
+	if err := todos.Complete(nil, 0); err != nil {
+		t.Fatalf("Error completing task: %v", err)
+	}
+	if len(todos) != 2 {
+		t.Fatalf("Expected the next occurrence to be added, got %d tasks", len(todos))
+	}
+	next := todos[1]
+	if next.Completed || !next.DueDate.Equal(date("2024-03-11")) || next.Task != "Standup notes" || next.Priority != Medium {
+		t.Errorf("Unexpected next occurrence %+v", next)
+	}
+	if next.SeriesID == "" || next.SeriesID != todos[0].SeriesID || len(todos.Series(next.SeriesID)) != 2 {
+		t.Errorf("Expected both occurrences in one series, got %q and %q", todos[0].SeriesID, next.SeriesID)
+	}
+	if next.Recur != "FREQ=WEEKLY;COUNT=1" {
+		t.Errorf("Expected the count to go down, got %s", next.Recur)
+	}
+
+	// The last occurrence of a counted series spawns nothing.
+	todos.Complete(nil, 1)
+	if len(todos) != 2 {
+		t.Errorf("Expected the series to end, got %d tasks", len(todos))
+	}
+}
@@ -0,0 +1,267 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// SchemaVersion is the newest on-disk format this binary understands. Bump it
+// together with a new entry in migrations whenever the stored data changes
+// shape; fields that older files simply lack need no migration.
+const SchemaVersion = 3
+
+// ErrNewerSchema is returned for files written by a newer version of todo.
+var ErrNewerSchema = errors.New("file was written by a newer version of todo")
+
+// Document is the top-level object stored on disk.
+type Document struct {
+	Version int   `json:"version"`
+	Tasks   Todos `json:"tasks"`
+	Meta    Meta  `json:"meta"`
+}
+
+// Meta holds information about the file as a whole rather than any one task.
+type Meta struct {
+	Migrations []MigrationRecord `json:"migrations,omitempty"`
+	// NextID is the ID the next new task receives. It only ever grows, so
+	// the ID of a deleted task is never handed out again.
+	NextID int `json:"next_id,omitempty"`
+	ListSet
+}
+
+// MigrationRecord notes that a file was upgraded and where the original went.
+type MigrationRecord struct {
+	From   int       `json:"from"`
+	To     int       `json:"to"`
+	At     time.Time `json:"at"`
+	Backup string    `json:"backup,omitempty"`
+}
+
+// migrations[v] upgrades a decoded document from schema version v to v+1.
+// They work on plain JSON values rather than Go types so that old migrations
+// keep compiling and behaving the same as Todo evolves.
+var migrations = map[int]func(doc map[string]any) error{
+	0: migrateBareArray,
+	1: migrateNamedLists,
+	2: migrateIDCounter,
+}
+
+// migrateBareArray upgrades the original format, a bare array of tasks, which
+// decodeDocument has already wrapped as version 0.
+func migrateBareArray(doc map[string]any) error {
+	if _, ok := doc["meta"]; !ok {
+		doc["meta"] = map[string]any{}
+	}
+	return nil
+}
+
+// migrateNamedLists records the default list, which every existing task
+// belongs to, and makes it the active one.
+func migrateNamedLists(doc map[string]any) error {
+	meta, ok := doc["meta"].(map[string]any)
+	if !ok {
+		meta = map[string]any{}
+		doc["meta"] = meta
+	}
+	if _, ok := meta["lists"]; !ok {
+		meta["lists"] = []any{map[string]any{"name": DefaultList}}
+	}
+	if _, ok := meta["active_list"]; !ok {
+		meta["active_list"] = DefaultList
+	}
+	return nil
+}
+
+// migrateIDCounter starts the ID counter past the highest ID in the file.
+// Tasks without an ID yet get theirs from the counter once decoded.
+func migrateIDCounter(doc map[string]any) error {
+	meta, ok := doc["meta"].(map[string]any)
+	if !ok {
+		meta = map[string]any{}
+		doc["meta"] = meta
+	}
+	next := int64(1)
+	tasks, _ := doc["tasks"].([]any)
+	for _, task := range tasks {
+		fields, _ := task.(map[string]any)
+		id, ok := fields["ID"].(json.Number)
+		if !ok {
+			continue
+		}
+		n, err := id.Int64()
+		if err != nil {
+			return fmt.Errorf("invalid task ID %s", id)
+		}
+		if n >= next {
+			next = n + 1
+		}
+	}
+	meta["next_id"] = next
+	return nil
+}
+
+// decodeDocument parses data written with any schema version up to
+// SchemaVersion, migrates it to SchemaVersion and reports the version it was
+// stored with.
+func decodeDocument(data []byte) (Document, int, error) {
+	var doc Document
+	raw := map[string]any{}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		// An empty file, e.g. one just created with touch, is an empty list.
+		return Document{Version: SchemaVersion, Tasks: Todos{}}, SchemaVersion, nil
+	}
+	if trimmed[0] == '[' {
+		var tasks []any
+		if err := unmarshalRaw(trimmed, &tasks); err != nil {
+			return doc, 0, err
+		}
+		raw["version"] = json.Number("0")
+		raw["tasks"] = tasks
+	} else if err := unmarshalRaw(trimmed, &raw); err != nil {
+		return doc, 0, err
+	}
+
+	number, ok := raw["version"].(json.Number)
+	if !ok {
+		return doc, 0, fmt.Errorf("missing schema version")
+	}
+	version64, err := number.Int64()
+	if err != nil {
+		return doc, 0, fmt.Errorf("invalid schema version %s", number)
+	}
+	version := int(version64)
+	if version > SchemaVersion {
+		return doc, version, fmt.Errorf("%w (schema version %d, this binary supports up to %d)", ErrNewerSchema, version, SchemaVersion)
+	}
+
+	for v := version; v < SchemaVersion; v++ {
+		migrate, ok := migrations[v]
+		if !ok {
+			return doc, version, fmt.Errorf("no migration from schema version %d", v)
+		}
+		if err := migrate(raw); err != nil {
+			return doc, version, fmt.Errorf("migrating from schema version %d: %w", v, err)
+		}
+		raw["version"] = v + 1
+	}
+
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return doc, version, err
+	}
+	if err := json.Unmarshal(encoded, &doc); err != nil {
+		return doc, version, err
+	}
+	if doc.Tasks == nil {
+		doc.Tasks = Todos{}
+	}
+	return doc, version, nil
+}
+
+// readDocument decodes data like decodeDocument and gives tasks stored
+// without an ID or UUID theirs. It reports whether the result differs from
+// what data holds, either migrated or with IDs filled in.
+func readDocument(data []byte) (Document, bool, error) {
+	doc, from, err := decodeDocument(data)
+	if err != nil {
+		return doc, false, err
+	}
+	assigned, err := doc.Tasks.assignIDs(&doc.Meta.NextID)
+	if err != nil {
+		return doc, false, err
+	}
+	return doc, from < SchemaVersion || assigned, nil
+}
+
+func unmarshalRaw(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// loadDocument reads filename, migrating it to the current schema if needed,
+// and reports whether it did. The file itself is left alone until the next
+// save.
+func loadDocument(filename string) (Document, bool, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return Document{}, false, err
+	}
+	doc, migrated, err := readDocument(data)
+	if err != nil {
+		return doc, false, fmt.Errorf("%s: %w", filename, err)
+	}
+	return doc, migrated, nil
+}
+
+// recordMigration backs up the pre-migration contents of filename and notes
+// the migration in meta. It runs when a migrated file is first saved, so
+// that only commands that change the list write files. An existing backup
+// from an earlier, interrupted save is left alone so it keeps the oldest
+// contents.
+func recordMigration(meta *Meta, filename string, original []byte, from int) error {
+	backup := fmt.Sprintf("%s.v%d.bak", filename, from)
+	if _, err := os.Stat(backup); isNotExist(err) {
+		if err := writeFileAtomic(backup, original, 0644); err != nil {
+			return fmt.Errorf("backing up %s before migrating: %w", filename, err)
+		}
+	}
+	meta.Migrations = append(meta.Migrations, MigrationRecord{
+		From:   from,
+		To:     SchemaVersion,
+		At:     time.Now(),
+		Backup: backup,
+	})
+	return nil
+}
+
+// saveDocument writes doc to filename. Saving over a file of an older schema
+// backs it up first; saving over one of a newer schema is refused, since it
+// would silently drop whatever the newer binary stored in it.
+func saveDocument(filename string, doc Document) error {
+	if data, err := os.ReadFile(filename); err == nil {
+		from, ok := storedVersion(data)
+		switch {
+		case ok && from > SchemaVersion:
+			return fmt.Errorf("%s: %w (schema version %d, this binary supports up to %d); refusing to overwrite it", filename, ErrNewerSchema, from, SchemaVersion)
+		case ok && from < SchemaVersion:
+			if err := recordMigration(&doc.Meta, filename, data, from); err != nil {
+				return err
+			}
+		}
+	}
+	doc.Version = SchemaVersion
+	if doc.Tasks == nil {
+		doc.Tasks = Todos{}
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, data, 0644)
+}
+
+// storedVersion reports the schema version of the contents of a JSON file:
+// 0 for a bare array. It is false for an empty or unreadable file.
+func storedVersion(data []byte) (int, bool) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return 0, false
+	}
+	if trimmed[0] == '[' {
+		return 0, true
+	}
+	var header struct {
+		Version *int `json:"version"`
+	}
+	if json.Unmarshal(trimmed, &header) != nil || header.Version == nil {
+		return 0, false
+	}
+	return *header.Version, true
+}
@@ -0,0 +1,166 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveWritesVersionedEnvelope(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "todos.json")
+	todos := &Todos{}
+	todos.Add(nil, "Task 1", nil, Low, nil)
+	if err := todos.Save(filename); err != nil {
+		t.Fatalf("Error saving todos: %v", err)
+	}
+
+	data, _ := os.ReadFile(filename)
+	var envelope struct {
+		Version int               `json:"version"`
+		Tasks   []json.RawMessage `json:"tasks"`
+		Meta    *json.RawMessage  `json:"meta"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		t.Fatalf("Expected a JSON object, got %s", data)
+	}
+	if envelope.Version != SchemaVersion || len(envelope.Tasks) != 1 || envelope.Meta == nil {
+		t.Errorf("Expected version %d envelope with 1 task and meta, got %s", SchemaVersion, data)
+	}
+}
+
+func TestLoadMigratesBareArray(t *testing.T) {
+	for _, backend := range []string{BackendJSON, BackendDB} {
+		t.Run(backend, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "todos")
+			legacy := `[{"Task": "Old 1", "Completed": false}, {"Task": "Old 2", "Completed": true}]`
+			if backend == BackendDB {
+				// A version 0 log is only reachable through its meta record.
+				legacy = `{"op":"meta","version":0}` + "\n" +
+					`{"op":"put","id":1,"task":{"ID":1,"Task":"Old 1","Completed":false}}` + "\n" +
+					`{"op":"put","id":2,"task":{"ID":2,"Task":"Old 2","Completed":true}}` + "\n"
+			}
+			if err := os.WriteFile(filename, []byte(legacy), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			store, _ := OpenStore(backend, filename)
+			todos, err := store.Load()
+			if err != nil {
+				t.Fatalf("Error loading todos: %v", err)
+			}
+			if len(todos) != 2 || todos[1].Task != "Old 2" || !todos[1].Completed {
+				t.Fatalf("Expected migrated tasks, got %+v", todos)
+			}
+
+			if _, err := os.Stat(filename + ".v0.bak"); !os.IsNotExist(err) {
+				t.Errorf("Expected loading alone not to write a backup, got %v", err)
+			}
+
+			if err := store.Save(todos); err != nil {
+				t.Fatalf("Error saving todos: %v", err)
+			}
+			backup, err := os.ReadFile(filename + ".v0.bak")
+			if err != nil || string(backup) != legacy {
+				t.Errorf("Expected backup of the original file, got %q (%v)", backup, err)
+			}
+			data, _ := os.ReadFile(filename)
+			if !strings.Contains(string(data), `"migrations"`) {
+				t.Errorf("Expected the migration to be recorded in meta, got %s", data)
+			}
+		})
+	}
+}
+
+func TestNewerSchemaIsRejected(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "todos.json")
+	future := `{"version": 99, "tasks": [], "meta": {}}`
+	if err := os.WriteFile(filename, []byte(future), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	todos := &Todos{}
+	if err := todos.Load(filename); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("Expected ErrNewerSchema on load, got %v", err)
+	}
+	if err := todos.Save(filename); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("Expected ErrNewerSchema on save, got %v", err)
+	}
+	if data, _ := os.ReadFile(filename); string(data) != future {
+		t.Errorf("Expected newer file to be left untouched, got %s", data)
+	}
+}
+
+func TestDecodeEmptyFile(t *testing.T) {
+	doc, version, err := decodeDocument([]byte("\n"))
+	if err != nil || version != SchemaVersion || len(doc.Tasks) != 0 {
+		t.Errorf("Expected an empty current document, got %+v, %d, %v", doc, version, err)
+	}
+}
+
+func TestDecodeDocumentErrors(t *testing.T) {
+	testCases := []string{
+		`{"tasks": []}`,
+		`{"version": "one", "tasks": []}`,
+		`{"version": -1, "tasks": []}`,
+		`not json`,
+	}
+
+	for _, tc := range testCases {
+		if _, _, err := decodeDocument([]byte(tc)); err == nil {
+			t.Errorf("Expected error decoding %s, but got none", tc)
+		}
+	}
+}
+
+func TestReadOnlyUpdateSavesMigration(t *testing.T) {
+	for _, backend := range []string{BackendJSON, BackendDB} {
+		t.Run(backend, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "todos")
+			legacy := `[{"Task": "Old"}]`
+			if backend == BackendDB {
+				legacy = `{"op":"meta","version":0}` + "\n" + `{"op":"put","id":1,"task":{"Task":"Old"}}` + "\n"
+			}
+			if err := os.WriteFile(filename, []byte(legacy), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			store, _ := OpenStore(backend, filename)
+			var uuids []string
+			var saved []string
+			for i := 0; i < 2; i++ {
+				err := store.Update(func(todos *Todos) error {
+					uuids = append(uuids, (*todos)[0].UUID)
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, _ := os.ReadFile(filename)
+				saved = append(saved, string(data))
+			}
+			if uuids[0] == "" || uuids[0] != uuids[1] {
+				t.Errorf("Expected the UUID made up on the first read to be kept, got %q", uuids)
+			}
+			if saved[0] == legacy || saved[1] != saved[0] {
+				t.Errorf("Expected the first read to save the upgrade and the second to write nothing, got %q", saved)
+			}
+			if backup, err := os.ReadFile(filename + ".v0.bak"); err != nil || string(backup) != legacy {
+				t.Errorf("Expected a backup of the original file, got %q (%v)", backup, err)
+			}
+		})
+	}
+}
+
+func TestMigrationStartsIDCounter(t *testing.T) {
+	data := `{"version": 2, "tasks": [{"ID": 1, "Task": "One"}, {"ID": 5, "Task": "Five"}, {"Task": "No ID"}], "meta": {}}`
+	doc, migrated, err := readDocument([]byte(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !migrated || doc.Tasks[2].ID != 6 || doc.Meta.NextID != 7 {
+		t.Errorf("Expected the task without an ID to get 6 and the counter 7, got %+v, %+v", doc.Tasks, doc.Meta)
+	}
+}
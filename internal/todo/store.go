@@ -0,0 +1,127 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Store persists a todo list. Update runs fn against the current contents and
+// saves the result only if fn succeeds and changed something, or Load had to
+// upgrade what it read, holding the store's lock throughout so concurrent
+// processes cannot interleave their changes. Once the list is saved, or
+// needed no saving, Update runs the saved hooks, still under the lock, for
+// files that must only record changes that reached the list, such as the
+// undo journal. Meta returns the file metadata read by the last Load; changes
+// made to it are written by the next Save.
+type Store interface {
+	Load() (Todos, error)
+	Save(todos Todos) error
+	Update(fn func(todos *Todos) error, saved ...func() error) error
+	Meta() *Meta
+
+	// migrated reports whether the last Load upgraded an older schema or
+	// made up IDs and UUIDs the file lacked, which must be saved so that
+	// they stay the same from one run to the next.
+	migrated() bool
+}
+
+const (
+	BackendJSON = "json"
+	BackendDB   = "db"
+)
+
+// OpenStore returns the store for the named backend, reading and writing path.
+func OpenStore(backend, path string) (Store, error) {
+	switch backend {
+	case "", BackendJSON:
+		return NewJSONStore(path), nil
+	case BackendDB:
+		return NewLogStore(path), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", backend)
+	}
+}
+
+// JSONStore keeps the whole list as an indented JSON document.
+type JSONStore struct {
+	Path string
+
+	meta        Meta
+	wasMigrated bool
+}
+
+func NewJSONStore(path string) *JSONStore {
+	return &JSONStore{Path: path}
+}
+
+func (s *JSONStore) Load() (Todos, error) {
+	doc, migrated, err := loadDocument(s.Path)
+	if err != nil {
+		if isNotExist(err) {
+			s.meta = Meta{}
+			s.wasMigrated = false
+		}
+		return nil, err
+	}
+	s.meta = doc.Meta
+	s.wasMigrated = migrated
+	return doc.Tasks, nil
+}
+
+func (s *JSONStore) Save(todos Todos) error {
+	return saveDocument(s.Path, Document{Tasks: todos, Meta: s.meta})
+}
+
+func (s *JSONStore) Meta() *Meta {
+	return &s.meta
+}
+
+func (s *JSONStore) migrated() bool {
+	return s.wasMigrated
+}
+
+func (s *JSONStore) Update(fn func(todos *Todos) error, saved ...func() error) error {
+	return withLock(s.Path, func() error {
+		return update(s, fn, saved)
+	})
+}
+
+// update runs fn against the stored list and saves the result, then runs the
+// saved hooks. A list that fn left as it was is not saved again, so that
+// read-only commands write nothing, unless Load upgraded it; a list whose
+// file does not exist yet is saved to create it.
+func update(s Store, fn func(todos *Todos) error, saved []func() error) error {
+	todos, err := s.Load()
+	missing := isNotExist(err)
+	if err != nil && !missing {
+		return err
+	}
+	if todos == nil {
+		todos = Todos{}
+	}
+	meta := s.Meta()
+	meta.NextID = max(meta.NextID, todos.nextID())
+	before, err := json.Marshal(Document{Tasks: todos, Meta: *s.Meta()})
+	if err != nil {
+		return err
+	}
+	if err := fn(&todos); err != nil {
+		return err
+	}
+	after, err := json.Marshal(Document{Tasks: todos, Meta: *s.Meta()})
+	if err != nil {
+		return err
+	}
+	if missing || s.migrated() || !bytes.Equal(before, after) {
+		if err := s.Save(todos); err != nil {
+			return err
+		}
+	}
+	for _, hook := range saved {
+		if err := hook(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
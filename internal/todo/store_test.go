@@ -0,0 +1,246 @@
+package todo
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenStore(t *testing.T) {
+	testCases := []struct {
+		backend  string
+		expected Store
+		hasError bool
+	}{
+		{"", &JSONStore{}, false},
+		{BackendJSON, &JSONStore{}, false},
+		{BackendDB, &LogStore{}, false},
+		{"sqlite", nil, true},
+	}
+
+	for _, tc := range testCases {
+		store, err := OpenStore(tc.backend, "todos")
+		if tc.hasError {
+			if err == nil {
+				t.Errorf("Expected error for backend '%s', but got none", tc.backend)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for backend '%s': %v", tc.backend, err)
+			continue
+		}
+		switch tc.expected.(type) {
+		case *JSONStore:
+			if _, ok := store.(*JSONStore); !ok {
+				t.Errorf("Expected JSONStore for backend '%s', got %T", tc.backend, store)
+			}
+		case *LogStore:
+			if _, ok := store.(*LogStore); !ok {
+				t.Errorf("Expected LogStore for backend '%s', got %T", tc.backend, store)
+			}
+		}
+	}
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	stores := map[string]Store{
+		"json": NewJSONStore(filepath.Join(dir, "todos.json")),
+		"db":   NewLogStore(filepath.Join(dir, "todos.db")),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.Load(); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("Expected not-exist error before first save, got %v", err)
+			}
+
+			err := store.Update(func(todos *Todos) error {
+				todos.Add(store.Meta(), "Task 1", nil, High, []string{"work"})
+				todos.Add(store.Meta(), "Task 2", nil, Low, nil)
+				todos.Add(store.Meta(), "Task 3", nil, Low, nil)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			err = store.Update(func(todos *Todos) error {
+				todos.Complete(store.Meta(), todos.IndexOf(1))
+				return todos.Delete(todos.IndexOf(2))
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			failed := errors.New("boom")
+			err = store.Update(func(todos *Todos) error {
+				todos.Delete(0)
+				return failed
+			})
+			if !errors.Is(err, failed) {
+				t.Fatalf("Expected Update to return the callback error, got %v", err)
+			}
+
+			todos, err := store.Load()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(todos) != 2 || todos[0].ID != 1 || todos[1].ID != 3 {
+				t.Fatalf("Expected tasks 1 and 3, got %+v", todos)
+			}
+			if !todos[0].Completed || todos[0].Tags[0] != "work" {
+				t.Errorf("Expected task 1 completed with tag 'work', got %+v", todos[0])
+			}
+		})
+	}
+}
+
+func TestStoreNeverReusesIDs(t *testing.T) {
+	dir := t.TempDir()
+	stores := map[string]Store{
+		"json": NewJSONStore(filepath.Join(dir, "todos.json")),
+		"db":   NewLogStore(filepath.Join(dir, "todos.db")),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			steps := []func(todos *Todos) error{
+				func(todos *Todos) error {
+					todos.Add(store.Meta(), "Task 1", nil, Low, nil)
+					todos.Add(store.Meta(), "Task 2", nil, Low, nil)
+					return nil
+				},
+				func(todos *Todos) error { return todos.Delete(todos.IndexOf(2)) },
+				func(todos *Todos) error { todos.Add(store.Meta(), "Task 3", nil, Low, nil); return nil },
+				func(todos *Todos) error {
+					todos.DeleteMatching(func(Todo) bool { return true })
+					return nil
+				},
+				func(todos *Todos) error { todos.Add(store.Meta(), "Task 4", nil, Low, nil); return nil },
+			}
+			for _, step := range steps {
+				if err := store.Update(step); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+
+			todos, err := store.Load()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(todos) != 1 || todos[0].ID != 4 || todos[0].Task != "Task 4" {
+				t.Errorf("Expected only task 4, got %+v", todos)
+			}
+			if store.Meta().NextID != 5 {
+				t.Errorf("Expected the counter to be at 5, got %d", store.Meta().NextID)
+			}
+		})
+	}
+}
+
+func TestUpdateRunsSavedHooksAfterSaving(t *testing.T) {
+	dir := t.TempDir()
+	stores := map[string]Store{
+		"json": NewJSONStore(filepath.Join(dir, "todos.json")),
+		"db":   NewLogStore(filepath.Join(dir, "todos.db")),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			var seen Todos
+			hook := func() error {
+				var err error
+				seen, err = store.Load()
+				return err
+			}
+			err := store.Update(func(todos *Todos) error {
+				return todos.Add(store.Meta(), "Task", nil, Low, nil)
+			}, hook)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(seen) != 1 {
+				t.Errorf("Expected the hook to run after the list was saved, got %+v", seen)
+			}
+
+			ran := false
+			failed := errors.New("boom")
+			err = store.Update(func(todos *Todos) error { return failed }, func() error {
+				ran = true
+				return nil
+			})
+			if !errors.Is(err, failed) || ran {
+				t.Errorf("Expected no hook after a failed update, got %v, ran %v", err, ran)
+			}
+		})
+	}
+}
+
+func TestLogStoreAppendsOnlyChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.db")
+	store := NewLogStore(path)
+
+	todos := Todos{}
+	for i := 0; i < 10; i++ {
+		todos.Add(nil, "Task", nil, Low, nil)
+	}
+	if err := store.Save(todos); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	before, _ := os.ReadFile(path)
+
+	todos[4].Tags = append(todos[4].Tags, "changed")
+	if err := store.Save(todos); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	after, _ := os.ReadFile(path)
+
+	if !bytes.HasPrefix(after, before) {
+		t.Fatal("Expected save to append to the existing log")
+	}
+	if lines := bytes.Count(after[len(before):], []byte("\n")); lines != 1 {
+		t.Errorf("Expected 1 appended record, got %d", lines)
+	}
+
+	reopened, err := NewLogStore(path).Load()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(reopened) != 10 || len(reopened[4].Tags) != 1 {
+		t.Errorf("Expected 10 tasks with task 5 tagged, got %+v", reopened)
+	}
+}
+
+func TestLogStoreIgnoresTruncatedTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.db")
+	data := `{"op":"put","id":1,"task":{"ID":1,"Task":"Kept","Completed":false}}` + "\n" +
+		`{"op":"put","id":2,"task":{"ID":2,"Ta`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewLogStore(path)
+	todos, err := store.Load()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(todos) != 1 || todos[0].Task != "Kept" {
+		t.Errorf("Expected only the intact record, got %+v", todos)
+	}
+
+	todos.Add(nil, "Added", nil, Low, nil)
+	if err := store.Save(todos); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	todos, err = NewLogStore(path).Load()
+	if err != nil {
+		t.Fatalf("Expected save to repair the torn log, got %v", err)
+	}
+	if len(todos) != 2 || todos[1].Task != "Added" {
+		t.Errorf("Expected the kept and added tasks, got %+v", todos)
+	}
+}
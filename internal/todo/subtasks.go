@@ -0,0 +1,234 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ErrHasSubtasks is returned when a task cannot be completed or deleted
+// because of its subtasks.
+var ErrHasSubtasks = errors.New("task has subtasks")
+
+// SubtaskPolicy decides what completing or deleting a task does to its
+// subtasks.
+type SubtaskPolicy int
+
+const (
+	// Block refuses to complete a task with unfinished subtasks, or to delete
+	// a task with any subtasks.
+	Block SubtaskPolicy = iota
+	// Cascade completes or deletes the subtasks along with the task.
+	Cascade
+	// Orphan leaves the subtasks alone and makes them top-level tasks.
+	Orphan
+)
+
+func (p SubtaskPolicy) String() string {
+	switch p {
+	case Cascade:
+		return "cascade"
+	case Orphan:
+		return "orphan"
+	default:
+		return "block"
+	}
+}
+
+// ParseSubtaskPolicy parses "block", "cascade" or "orphan". The empty string
+// is Block.
+func ParseSubtaskPolicy(s string) (SubtaskPolicy, error) {
+	switch s {
+	case "", "block":
+		return Block, nil
+	case "cascade":
+		return Cascade, nil
+	case "orphan":
+		return Orphan, nil
+	default:
+		return Block, fmt.Errorf("invalid subtask policy: %s (use block, cascade or orphan)", s)
+	}
+}
+
+// SubtaskRules are the policies applied when completing and deleting tasks.
+type SubtaskRules struct {
+	OnComplete SubtaskPolicy
+	OnDelete   SubtaskPolicy
+}
+
+// AddSubtask adds a task under the task with ID parentID, in the same list.
+func (t *Todos) AddSubtask(meta *Meta, parentID int, task string, dueDate *time.Time, priority Priority, tags []string) error {
+	parent := t.IndexOf(parentID)
+	if parent < 0 {
+		return fmt.Errorf("task %d not found", parentID)
+	}
+	list := (*t)[parent].List
+	if err := t.Add(meta, task, dueDate, priority, tags); err != nil {
+		return err
+	}
+	added := &(*t)[len(*t)-1]
+	added.ParentID = parentID
+	added.List = list
+	return nil
+}
+
+// Descendants returns the IDs of the task's subtasks, their subtasks and so
+// on, parents before their children.
+func (t Todos) Descendants(id int) []int {
+	children := t.childIDs()
+	var ids []int
+	seen := map[int]bool{id: true}
+	var walk func(id int)
+	walk = func(id int) {
+		for _, child := range children[id] {
+			if seen[child] {
+				continue
+			}
+			seen[child] = true
+			ids = append(ids, child)
+			walk(child)
+		}
+	}
+	walk(id)
+	return ids
+}
+
+// Progress returns how many of the task's descendants are completed, and how
+// many there are.
+func (t Todos) Progress(id int) (done, total int) {
+	for _, child := range t.Descendants(id) {
+		total++
+		if t[t.IndexOf(child)].Completed {
+			done++
+		}
+	}
+	return done, total
+}
+
+// CompleteTree completes the task at index, applying policy to its subtasks.
+func (t *Todos) CompleteTree(meta *Meta, index int, policy SubtaskPolicy) error {
+	if index < 0 || index >= len(*t) {
+		return fmt.Errorf("index out of range")
+	}
+	descendants := t.Descendants((*t)[index].ID)
+	switch policy {
+	case Block:
+		open := 0
+		for _, id := range descendants {
+			if !(*t)[t.IndexOf(id)].IsClosed() {
+				open++
+			}
+		}
+		if open > 0 {
+			return fmt.Errorf("%w: %d of them are still open", ErrHasSubtasks, open)
+		}
+	case Cascade:
+		for _, id := range descendants {
+			// Cancelled subtasks stay cancelled.
+			if index := t.IndexOf(id); !(*t)[index].IsClosed() {
+				if err := t.Complete(meta, index); err != nil {
+					return err
+				}
+			}
+		}
+	case Orphan:
+		t.orphanChildren((*t)[index].ID)
+	}
+	return t.Complete(meta, index)
+}
+
+// DeleteTree deletes the task at index, applying policy to its subtasks.
+func (t *Todos) DeleteTree(index int, policy SubtaskPolicy) error {
+	if index < 0 || index >= len(*t) {
+		return fmt.Errorf("index out of range")
+	}
+	id := (*t)[index].ID
+	descendants := t.Descendants(id)
+	switch policy {
+	case Block:
+		if len(descendants) > 0 {
+			return fmt.Errorf("%w: delete them first", ErrHasSubtasks)
+		}
+	case Cascade:
+		deleted := map[int]bool{id: true}
+		for _, d := range descendants {
+			deleted[d] = true
+		}
+		t.DeleteMatching(func(task Todo) bool { return deleted[task.ID] })
+		return nil
+	case Orphan:
+		t.orphanChildren(id)
+	}
+	return t.Delete(index)
+}
+
+func (t *Todos) orphanChildren(id int) {
+	for i := range *t {
+		task := &(*t)[i]
+		if task.ParentID == id {
+			task.RecordChange("ParentID", strconv.Itoa(id), "0")
+			task.ParentID = 0
+		}
+	}
+}
+
+// childIDs maps each task ID to the IDs of its direct subtasks, in list
+// order.
+func (t Todos) childIDs() map[int][]int {
+	children := map[int][]int{}
+	for _, task := range t {
+		if task.ParentID != 0 {
+			children[task.ParentID] = append(children[task.ParentID], task.ID)
+		}
+	}
+	return children
+}
+
+// treeEntry is a task's position in the tree of a list.
+type treeEntry struct {
+	index int
+	depth int
+}
+
+// tree orders the tasks so that subtasks follow their parent, and records
+// each one's depth. Tasks whose parent is not in t are shown at the top
+// level.
+func (t Todos) tree() []treeEntry {
+	present := make(map[int]bool, len(t))
+	for _, task := range t {
+		present[task.ID] = true
+	}
+	children := map[int][]int{}
+	var roots []int
+	for i, task := range t {
+		if task.ParentID != 0 && present[task.ParentID] && task.ParentID != task.ID {
+			children[task.ParentID] = append(children[task.ParentID], i)
+		} else {
+			roots = append(roots, i)
+		}
+	}
+
+	entries := make([]treeEntry, 0, len(t))
+	seen := make([]bool, len(t))
+	var walk func(index, depth int)
+	walk = func(index, depth int) {
+		if seen[index] {
+			return
+		}
+		seen[index] = true
+		entries = append(entries, treeEntry{index: index, depth: depth})
+		for _, child := range children[t[index].ID] {
+			walk(child, depth+1)
+		}
+	}
+	for _, index := range roots {
+		walk(index, 0)
+	}
+	// Parent links that form a cycle can only come from a hand-edited file;
+	// show those tasks rather than dropping them.
+	for index := range t {
+		walk(index, 0)
+	}
+	return entries
+}
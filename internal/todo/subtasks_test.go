@@ -0,0 +1,115 @@
+package todo
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// newTree returns: 1 Release > 2 Notes, 3 Build > 4 CI; and 5 Other.
+func newTree(t *testing.T) Todos {
+	todos := Todos{}
+	todos.Add(nil, "Release", nil, Low, nil)
+	for _, sub := range []struct {
+		parent int
+		task   string
+	}{{1, "Notes"}, {1, "Build"}, {3, "CI"}} {
+		if err := todos.AddSubtask(nil, sub.parent, sub.task, nil, Low, nil); err != nil {
+			t.Fatalf("Error adding subtask: %v", err)
+		}
+	}
+	todos.Add(nil, "Other", nil, Low, nil)
+	return todos
+}
+
+func TestDescendantsAndProgress(t *testing.T) {
+	todos := newTree(t)
+	todos.Complete(nil, 3)
+
+	if got := todos.Descendants(1); len(got) != 3 || got[0] != 2 || got[1] != 3 || got[2] != 4 {
+		t.Errorf("Expected descendants [2 3 4], got %v", got)
+	}
+	if done, total := todos.Progress(1); done != 1 || total != 3 {
+		t.Errorf("Expected progress 1/3, got %d/%d", done, total)
+	}
+	if err := todos.AddSubtask(nil, 42, "Orphan", nil, Low, nil); err == nil {
+		t.Errorf("Expected an error adding a subtask to a missing task")
+	}
+}
+
+func TestSubtaskPolicies(t *testing.T) {
+	tests := []struct {
+		name      string
+		complete  bool
+		policy    SubtaskPolicy
+		wantErr   bool
+		wantTasks int
+	}{
+		{"complete block", true, Block, true, 5},
+		{"complete cascade", true, Cascade, false, 5},
+		{"complete orphan", true, Orphan, false, 5},
+		{"delete block", false, Block, true, 5},
+		{"delete cascade", false, Cascade, false, 1},
+		{"delete orphan", false, Orphan, false, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todos := newTree(t)
+			var err error
+			if tt.complete {
+				err = todos.CompleteTree(nil, 0, tt.policy)
+			} else {
+				err = todos.DeleteTree(0, tt.policy)
+			}
+			if tt.wantErr != errors.Is(err, ErrHasSubtasks) {
+				t.Fatalf("Expected ErrHasSubtasks: %v, got %v", tt.wantErr, err)
+			}
+			if len(todos) != tt.wantTasks {
+				t.Errorf("Expected %d tasks, got %d", tt.wantTasks, len(todos))
+			}
+			if tt.policy == Cascade && tt.complete {
+				for _, task := range todos[:4] {
+					if !task.Completed {
+						t.Errorf("Expected task %d to be completed", task.ID)
+					}
+				}
+			}
+			if tt.policy == Orphan {
+				notes := todos[todos.IndexOf(2)]
+				ci := todos[todos.IndexOf(4)]
+				if notes.ParentID != 0 || ci.ParentID != 3 {
+					t.Errorf("Expected only direct subtasks to be orphaned, got %+v and %+v", notes, ci)
+				}
+			}
+		})
+	}
+}
+
+func TestPrintIndentsSubtasks(t *testing.T) {
+	todos := newTree(t)
+	// A subtask listed before its parent still prints under it.
+	todos = Todos{todos[3], todos[0], todos[1], todos[2], todos[4]}
+
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	Print(&todos)
+	w.Close()
+	os.Stdout = old
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+
+	output := buf.String()
+	order := []string{"| Release ", "| - Notes ", "| - Build ", "|   - CI ", "| Other "}
+	last := -1
+	for _, s := range order {
+		i := strings.Index(output, s)
+		if i <= last {
+			t.Fatalf("Expected %q after the previous row, got:\n%s", s, output)
+		}
+		last = i
+	}
+}
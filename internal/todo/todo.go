@@ -1,9 +1,9 @@
 package todo
 
 import (
-	"encoding/json"
+	"crypto/rand"
 	"fmt"
-	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -34,90 +34,246 @@ func ParsePriority(s string) (Priority, error) {
 }
 
 type Todo struct {
-	Task      string
-	Completed bool
-	DueDate   *time.Time `json:",omitempty"`
-	Priority  Priority   `json:",omitempty"`
-	Tags      []string   `json:",omitempty"`
+	ID          int
+	UUID        string `json:",omitempty"`
+	Task        string
+	Completed   bool
+	DueDate     *time.Time   `json:",omitempty"`
+	DueZone     string       `json:",omitempty"` // time zone a due time was given in, e.g. Europe/Berlin
+	Priority    Priority     `json:",omitempty"`
+	Tags        []string     `json:",omitempty"`
+	List        string       `json:",omitempty"`
+	ParentID    int          `json:",omitempty"`
+	DependsOn   []int        `json:",omitempty"`
+	Recur       string       `json:",omitempty"` // RRULE syntax
+	SeriesID    string       `json:",omitempty"` // shared by the occurrences of a recurring task
+	Status      string       `json:",omitempty"` // workflow state unless completed; empty is the initial state
+	CreatedAt   *time.Time   `json:",omitempty"`
+	UpdatedAt   *time.Time   `json:",omitempty"`
+	CompletedAt *time.Time   `json:",omitempty"`
+	History     []Change     `json:",omitempty"`
+	Notes       string       `json:",omitempty"` // free-form, possibly several lines
+	Annotations []Annotation `json:",omitempty"`
+}
+
+// Annotation is a timestamped remark added to a task, such as "called the
+// vendor".
+type Annotation struct {
+	Time time.Time
+	Text string
+}
+
+// Change is one entry in a task's audit trail.
+type Change struct {
+	Field string
+	Old   string
+	New   string
+	Time  time.Time
+}
+
+// RecordChange notes that field changed from oldValue to newValue and bumps
+// UpdatedAt. Callers make the change themselves; nothing is recorded if the
+// value is unchanged.
+func (t *Todo) RecordChange(field, oldValue, newValue string) {
+	if oldValue == newValue {
+		return
+	}
+	now := time.Now()
+	t.History = append(t.History, Change{Field: field, Old: oldValue, New: newValue, Time: now})
+	t.UpdatedAt = &now
+}
+
+// FormatDueDate renders a due date the way Print does, with "N/A" for none.
+func FormatDueDate(dueDate *time.Time) string {
+	if dueDate == nil {
+		return "N/A"
+	}
+	if HasDueTime(*dueDate) {
+		return dueDate.Local().Format(dateFormat + " 15:04")
+	}
+	return dueDate.Format(dateFormat)
+}
+
+// FormatUrgency renders an urgency score the way Print does.
+func FormatUrgency(urgency float64) string {
+	return strconv.FormatFloat(urgency, 'f', 1, 64)
+}
+
+// FormatTags renders tags the way Print does, with "None" for no tags.
+func FormatTags(tags []string) string {
+	if len(tags) == 0 {
+		return "None"
+	}
+	return strings.Join(tags, ", ")
 }
 
 type Todos []Todo
 
-func (t *Todos) Add(task string, dueDate *time.Time, priority Priority, tags []string) {
-	todo := Todo{Task: task, Completed: false, DueDate: dueDate, Priority: priority, Tags: tags}
+// Add appends a task with the next ID from meta's counter; see Meta.newID.
+func (t *Todos) Add(meta *Meta, task string, dueDate *time.Time, priority Priority, tags []string) error {
+	uuid, err := newUUID()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	todo := Todo{ID: meta.newID(*t), UUID: uuid, Task: task, Completed: false, DueDate: dueDate, Priority: priority, Tags: tags, CreatedAt: &now, UpdatedAt: &now}
+	todo.DueZone = zoneOf(dueDate)
 	*t = append(*t, todo)
+	return nil
 }
 
-func (t *Todos) Complete(index int) error {
+// nextID is one past the highest ID in t.
+func (t Todos) nextID() int {
+	next := 1
+	for _, todo := range t {
+		if todo.ID >= next {
+			next = todo.ID + 1
+		}
+	}
+	return next
+}
+
+// newID hands out the ID of the next task added to todos and moves the
+// counter on. The counter only ever grows, so deleting a task, even the
+// newest one, never lets a later task take its ID. A nil meta, for a list not
+// kept in a file, numbers on from the highest ID in todos.
+func (m *Meta) newID(todos Todos) int {
+	if m == nil {
+		return todos.nextID()
+	}
+	id := max(m.NextID, todos.nextID())
+	m.NextID = id + 1
+	return id
+}
+
+// IndexOf returns the position of the task with the given ID, or -1.
+func (t *Todos) IndexOf(id int) int {
+	for i, todo := range *t {
+		if todo.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// assignIDs gives every task loaded from a file written before tasks had IDs
+// a fresh ID from the counter next, and a UUID, keeping the existing order,
+// and reports whether there were any. The counter is left past every ID in
+// the list.
+func (t *Todos) assignIDs(next *int) (bool, error) {
+	*next = max(*next, t.nextID())
+	assigned := false
+	for i := range *t {
+		task := &(*t)[i]
+		if task.ID == 0 {
+			task.ID = *next
+			*next++
+			assigned = true
+		}
+		if task.UUID == "" {
+			uuid, err := newUUID()
+			if err != nil {
+				return false, err
+			}
+			task.UUID = uuid
+			assigned = true
+		}
+	}
+	return assigned, nil
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating a UUID: %w", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// Complete completes the task at index. Completing a recurring task adds its
+// next occurrence, numbered from meta like Add.
+func (t *Todos) Complete(meta *Meta, index int) error {
 	if index < 0 || index >= len(*t) {
 		return fmt.Errorf("index out of range")
 	}
-	(*t)[index].Completed = true
-	return nil
+	task := &(*t)[index]
+	if task.Completed {
+		return nil
+	}
+	if open := t.OpenDependencies(*task); len(open) > 0 {
+		fmt.Fprintf(Warnings, "warning: task %d still depends on open task(s) %s\n", task.ID, FormatIDs(open))
+	}
+	from := ActiveWorkflow.StateOf(*task)
+	task.Completed = true
+	task.RecordChange("Completed", "false", "true")
+	if task.Status != "" {
+		task.RecordChange("Status", from, ActiveWorkflow.Done)
+		task.Status = ""
+	}
+	task.CompletedAt = task.UpdatedAt
+	return t.spawnNext(meta, index)
 }
 
 func (t *Todos) Delete(index int) error {
 	if index < 0 || index >= len(*t) {
 		return fmt.Errorf("index out of range")
 	}
+	id := (*t)[index].ID
 	*t = append((*t)[:index], (*t)[index+1:]...)
+	t.dropDependenciesOn(id)
 	return nil
 }
 
+// DeleteMatching deletes every task match returns true for and returns how
+// many there were.
+func (t *Todos) DeleteMatching(match func(Todo) bool) int {
+	kept := Todos{}
+	var deleted []int
+	for _, task := range *t {
+		if match(task) {
+			deleted = append(deleted, task.ID)
+		} else {
+			kept = append(kept, task)
+		}
+	}
+	*t = kept
+	for _, id := range deleted {
+		t.dropDependenciesOn(id)
+	}
+	return len(deleted)
+}
+
+// Save writes the list to filename, keeping the meta already in the file,
+// such as its named lists and ID counter. A Store saves changed meta too.
 func (t *Todos) Save(filename string) error {
-	data, err := json.MarshalIndent(t, "", "  ")
-	if err != nil {
+	doc, _, err := loadDocument(filename)
+	if err != nil && !isNotExist(err) {
 		return err
 	}
-	return os.WriteFile(filename, data, 0644)
+	doc.Tasks = *t
+	doc.Meta.NextID = max(doc.Meta.NextID, t.nextID())
+	return saveDocument(filename, doc)
 }
 
 func (t *Todos) Load(filename string) error {
-	data, err := os.ReadFile(filename)
+	doc, _, err := loadDocument(filename)
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(data, t)
+	*t = doc.Tasks
+	return nil
 }
 
 func Print(todos *Todos) {
-	if len(*todos) == 0 {
-		fmt.Println("No tasks. Your todo list is empty.")
-		return
-	}
-
-	maxTaskLength := 0
-	for _, todo := range *todos {
-		if len(todo.Task) > maxTaskLength {
-			maxTaskLength = len(todo.Task)
-		}
-	}
-
-	format := "| %-3d | %-*s | %-10s | %-8s | %-6s | %-20s |\n"
-	divider := strings.Repeat("-", maxTaskLength+60)
-
-	fmt.Println(divider)
-	fmt.Printf(format, 0, maxTaskLength, "Task", "Due Date", "Priority", "Status", "Tags")
-	fmt.Println(divider)
-
-	for i, todo := range *todos {
-		status := "Pending"
-		if todo.Completed {
-			status = "Done"
-		}
-
-		dueDate := "N/A"
-		if todo.DueDate != nil {
-			dueDate = todo.DueDate.Format("2006-01-02")
-		}
-
-		tags := "None"
-		if len(todo.Tags) > 0 {
-			tags = strings.Join(todo.Tags, ", ")
-		}
-
-		fmt.Printf(format, i+1, maxTaskLength, todo.Task, dueDate, todo.Priority, status, tags)
-	}
+	PrintView(todos, *todos)
+}
 
-	fmt.Println(divider)
+// PrintView prints the tasks in view, a subset of all, with the default
+// columns. Whether a task is blocked is worked out from all, since it may wait
+// for a task not shown.
+func PrintView(todos *Todos, all Todos) {
+	PrintTable(todos, all, DefaultColumns)
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -13,7 +14,7 @@ func TestAdd(t *testing.T) {
 	todos := &Todos{}
 	dueDate := time.Now().AddDate(0, 0, 1) // Tomorrow
 	tags := []string{"work", "urgent"}
-	todos.Add("Test task", &dueDate, High, tags)
+	todos.Add(nil, "Test task", &dueDate, High, tags)
 	if len(*todos) != 1 {
 		t.Errorf("Expected 1 todo, got %d", len(*todos))
 	}
@@ -33,7 +34,7 @@ func TestAdd(t *testing.T) {
 
 func TestComplete(t *testing.T) {
 	todos := &Todos{{Task: "Test task", Completed: false}}
-	err := todos.Complete(0)
+	err := todos.Complete(nil, 0)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -42,6 +43,33 @@ func TestComplete(t *testing.T) {
 	}
 }
 
+func TestAuditTimestamps(t *testing.T) {
+	todos := &Todos{}
+	todos.Add(nil, "Test task", nil, Low, nil)
+	task := &(*todos)[0]
+	if task.CreatedAt == nil || task.UpdatedAt == nil || task.CompletedAt != nil {
+		t.Fatalf("Expected created and updated timestamps only, got %+v", task)
+	}
+
+	todos.Complete(nil, 0)
+	todos.Complete(nil, 0)
+	if task.CompletedAt == nil || task.UpdatedAt.Before(*task.CreatedAt) {
+		t.Errorf("Expected completion to set CompletedAt and UpdatedAt, got %+v", task)
+	}
+	if len(task.History) != 1 {
+		t.Fatalf("Expected one recorded change, got %+v", task.History)
+	}
+	change := task.History[0]
+	if change.Field != "Completed" || change.Old != "false" || change.New != "true" {
+		t.Errorf("Unexpected change %+v", change)
+	}
+
+	task.RecordChange("Task", "same", "same")
+	if len(task.History) != 1 {
+		t.Error("Expected unchanged values not to be recorded")
+	}
+}
+
 func TestDelete(t *testing.T) {
 	todos := &Todos{{Task: "Test task"}}
 	err := todos.Delete(0)
@@ -75,6 +103,97 @@ func TestSaveAndLoad(t *testing.T) {
 	os.Remove(filename) // Clean up
 }
 
+func TestAddAssignsIncreasingIDs(t *testing.T) {
+	todos := &Todos{}
+	todos.Add(nil, "Task 1", nil, Low, nil)
+	todos.Add(nil, "Task 2", nil, Low, nil)
+	todos.Add(nil, "Task 3", nil, Low, nil)
+
+	if err := todos.Delete(todos.IndexOf(2)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	todos.Add(nil, "Task 4", nil, Low, nil)
+
+	ids := []int{}
+	for _, todo := range *todos {
+		ids = append(ids, todo.ID)
+		if len(todo.UUID) != 36 {
+			t.Errorf("Expected task %d to have a UUID, got %q", todo.ID, todo.UUID)
+		}
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 3 || ids[2] != 4 {
+		t.Errorf("Expected IDs [1 3 4], got %v", ids)
+	}
+	if todos.IndexOf(3) != 1 {
+		t.Errorf("Expected task 3 at index 1, got %d", todos.IndexOf(3))
+	}
+	if todos.IndexOf(2) != -1 {
+		t.Errorf("Expected deleted task 2 to be gone, got index %d", todos.IndexOf(2))
+	}
+}
+
+func TestAddUsesMetaCounter(t *testing.T) {
+	meta := &Meta{NextID: 10}
+	todos := &Todos{{ID: 1, Task: "Task 1"}}
+	todos.Add(meta, "Task 10", nil, Low, nil)
+	todos.Add(meta, "Task 11", nil, Low, nil)
+	if (*todos)[1].ID != 10 || (*todos)[2].ID != 11 || meta.NextID != 12 {
+		t.Errorf("Expected IDs 10 and 11 and the counter at 12, got %+v, %d", *todos, meta.NextID)
+	}
+}
+
+func TestSaveKeepsMeta(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "todos.json")
+	store := NewJSONStore(filename)
+	err := store.Update(func(todos *Todos) error {
+		if err := store.Meta().Create("work"); err != nil {
+			return err
+		}
+		for i := 0; i < 3; i++ {
+			todos.Add(store.Meta(), "Task", nil, Low, nil)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	todos := &Todos{}
+	if err := todos.Load(filename); err != nil {
+		t.Fatal(err)
+	}
+	*todos = (*todos)[:1]
+	if err := todos.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if store.Meta().NextID != 4 || store.Meta().Find("work") == nil {
+		t.Errorf("Expected the counter and lists to be kept, got %+v", *store.Meta())
+	}
+}
+
+func TestLoadAssignsIDsToLegacyFile(t *testing.T) {
+	filename := "test_legacy_todos.json"
+	legacy := `[{"Task": "Old 1", "Completed": false}, {"Task": "Old 2", "Completed": true}]`
+	if err := os.WriteFile(filename, []byte(legacy), 0644); err != nil {
+		t.Fatalf("Error writing legacy file: %v", err)
+	}
+	defer os.Remove(filename)
+
+	todos := &Todos{}
+	if err := todos.Load(filename); err != nil {
+		t.Fatalf("Error loading todos: %v", err)
+	}
+	if (*todos)[0].ID != 1 || (*todos)[1].ID != 2 {
+		t.Errorf("Expected IDs 1 and 2, got %d and %d", (*todos)[0].ID, (*todos)[1].ID)
+	}
+	if (*todos)[0].UUID == "" || (*todos)[0].UUID == (*todos)[1].UUID {
+		t.Errorf("Expected distinct UUIDs, got %q and %q", (*todos)[0].UUID, (*todos)[1].UUID)
+	}
+}
+
 func TestParsePriority(t *testing.T) {
 	testCases := []struct {
 		input    string
@@ -110,7 +229,7 @@ func TestPrint(t *testing.T) {
 		{Task: "Task 2", Completed: true, Priority: Low, Tags: []string{"personal"}},
 	}
 	dueDate := time.Now().AddDate(0, 0, 1)
-	todos.Add("Task 3", &dueDate, Medium, []string{"project"})
+	todos.Add(nil, "Task 3", &dueDate, Medium, []string{"project"})
 
 	// Capture stdout
 	old := os.Stdout
@@ -126,7 +245,7 @@ func TestPrint(t *testing.T) {
 	io.Copy(&buf, r)
 
 	output := buf.String()
-	expectedStrings := []string{"Task 1", "Task 2", "Task 3", "High", "Low", "Medium", "Pending", "Done", dueDate.Format("2006-01-02"), "urgent, work", "personal", "project"}
+	expectedStrings := []string{"Task 1", "Task 2", "Task 3", "High", "Low", "Medium", "Todo", "Done", dueDate.Format("2006-01-02"), "urgent, work", "personal", "project"}
 
 	for _, s := range expectedStrings {
 		if !strings.Contains(output, s) {
@@ -139,11 +258,11 @@ func TestTodosOutOfRange(t *testing.T) {
 	todos := &Todos{{Task: "Test task"}}
 
 	// Test Complete out of range
-	err := todos.Complete(-1)
+	err := todos.Complete(nil, -1)
 	if err == nil {
 		t.Error("Expected error for out of range index in Complete, but got none")
 	}
-	err = todos.Complete(1)
+	err = todos.Complete(nil, 1)
 	if err == nil {
 		t.Error("Expected error for out of range index in Complete, but got none")
 	}
@@ -200,11 +319,11 @@ func TestTodosEmpty(t *testing.T) {
 
 func TestVisualization(t *testing.T) {
 	todos := &Todos{}
-	todos.Add("Task 1", nil, High, nil)
-	todos.Add("Task 2", nil, Medium, nil)
-	todos.Add("Task 3", nil, Low, nil)
-	todos.Add("Task 4", nil, Medium, nil)
-	todos.Complete(0)
+	todos.Add(nil, "Task 1", nil, High, nil)
+	todos.Add(nil, "Task 2", nil, Medium, nil)
+	todos.Add(nil, "Task 3", nil, Low, nil)
+	todos.Add(nil, "Task 4", nil, Medium, nil)
+	todos.Complete(nil, 0)
 
 	priorityViz := VisualizeTasksByPriority(todos)
 	if !strings.Contains(priorityViz, "High") || !strings.Contains(priorityViz, "Medium") || !strings.Contains(priorityViz, "Low") {
@@ -216,3 +335,16 @@ func TestVisualization(t *testing.T) {
 		t.Errorf("Progress visualization doesn't show correct percentage")
 	}
 }
+
+func TestVisualizationOfEmptyList(t *testing.T) {
+	for name, visualize := range map[string]func(*Todos) string{
+		"priority": VisualizeTasksByPriority,
+		"progress": VisualizeOverallProgress,
+		"status":   visualizeStatuses,
+		"subtasks": visualizeSubtaskProgress,
+	} {
+		if got := visualize(&Todos{}); strings.Contains(got, barChar) {
+			t.Errorf("%s: expected no bars for an empty list, got %q", name, got)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package todo
+
+import "time"
+
+// UrgencyCoefficients weigh what makes a task urgent. A task's urgency is the
+// sum of the coefficients that apply to it; Due and Age are scaled by how close
+// the due date is and how old the task is.
+type UrgencyCoefficients struct {
+	Priority map[Priority]float64
+	// Due applies in full to tasks a week or more overdue, tapering to a fifth
+	// for tasks due in two weeks or more.
+	Due float64
+	// Age applies in full to tasks a year old or older.
+	Age     float64
+	Blocked float64
+	// Tags and Status are keyed by tag and workflow state.
+	Tags   map[string]float64
+	Status map[string]float64
+}
+
+// DefaultUrgency is used unless the config file tunes the coefficients.
+var DefaultUrgency = UrgencyCoefficients{
+	Priority: map[Priority]float64{High: 6, Medium: 3.9, Low: 1.8},
+	Due:      12,
+	Age:      2,
+	Blocked:  -5,
+	Tags:     map[string]float64{"urgent": 5},
+	Status:   map[string]float64{"in-progress": 4, "waiting": -3},
+}
+
+// ActiveUrgency are the coefficients tasks are scored with.
+var ActiveUrgency = DefaultUrgency
+
+// maxAge is the age, in days, at which Age applies in full.
+const maxAge = 365
+
+// Urgency scores task at time now. Closed tasks score 0. Whether the task is
+// blocked is worked out from t.
+func (t Todos) Urgency(task Todo, now time.Time) float64 {
+	if task.IsClosed() {
+		return 0
+	}
+	c := ActiveUrgency
+	urgency := c.Priority[task.Priority]
+	urgency += c.Due * dueFactor(task, now)
+	if task.CreatedAt != nil {
+		age := now.Sub(*task.CreatedAt).Hours() / 24
+		urgency += c.Age * min(max(age, 0)/maxAge, 1)
+	}
+	for _, tag := range task.Tags {
+		urgency += c.Tags[tag]
+	}
+	urgency += c.Status[ActiveWorkflow.StateOf(task)]
+	if t.IsBlocked(task) {
+		urgency += c.Blocked
+	}
+	return urgency
+}
+
+// dueFactor is 1 for tasks a week or more overdue, 0.2 for tasks due in two
+// weeks or more, linear in between, and 0 without a due date.
+func dueFactor(task Todo, now time.Time) float64 {
+	var overdue float64
+	if task.hasDueTime() {
+		due, _ := task.Due()
+		overdue = now.Sub(due).Hours() / 24
+	} else if day, ok := task.DueDay(now); ok {
+		// Whole days, counted on the calendar.
+		overdue = CalendarDay(now).Sub(day).Hours() / 24
+	} else {
+		return 0
+	}
+	switch {
+	case overdue >= 7:
+		return 1
+	case overdue <= -14:
+		return 0.2
+	default:
+		return 0.2 + 0.8*(overdue+14)/21
+	}
+}
+
+// ByUrgency returns the tasks of view ordered from most to least urgent, with
+// blocked state worked out from t. Equally urgent tasks keep their order.
+func (t Todos) ByUrgency(view Todos, now time.Time) Todos {
+	return t.Sort(view, []SortKey{{Field: "urgency", Desc: true}}, now)
+}
+
+// MostUrgent returns the open task of view with the highest urgency.
+func (t Todos) MostUrgent(view Todos, now time.Time) (Todo, bool) {
+	for _, task := range t.ByUrgency(view, now) {
+		if !task.IsClosed() {
+			return task, true
+		}
+	}
+	return Todo{}, false
+}
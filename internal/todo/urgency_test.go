@@ -0,0 +1,68 @@
+package todo
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestUrgency(t *testing.T) {
+	now := time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC)
+	day := func(s string) *time.Time { d := date(s); return &d }
+	halfYear := now.AddDate(0, 0, -int(maxAge/2))
+	tests := []struct {
+		name string
+		task Todo
+		want float64
+	}{
+		{"low priority", Todo{ID: 1}, 1.8},
+		{"high priority", Todo{ID: 1, Priority: High}, 6},
+		{"overdue a week", Todo{ID: 1, DueDate: day("2024-02-28")}, 1.8 + 12},
+		{"due today", Todo{ID: 1, DueDate: day("2024-03-06")}, 1.8 + 12*(0.2+0.8*14/21)},
+		{"due in a month", Todo{ID: 1, DueDate: day("2024-04-06")}, 1.8 + 12*0.2},
+		{"half a year old", Todo{ID: 1, CreatedAt: &halfYear}, 1.8 + 2*float64(int(maxAge/2))/maxAge},
+		{"tagged urgent", Todo{ID: 1, Tags: []string{"urgent", "home"}}, 1.8 + 5},
+		{"in progress", Todo{ID: 1, Status: "in-progress"}, 1.8 + 4},
+		{"completed", Todo{ID: 1, Priority: High, Completed: true}, 0},
+	}
+	for _, tt := range tests {
+		got := Todos{tt.task}.Urgency(tt.task, now)
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: got %.3f, want %.3f", tt.name, got, tt.want)
+		}
+	}
+
+	todos := Todos{{ID: 1}, {ID: 2, DependsOn: []int{1}}}
+	if got := todos.Urgency(todos[1], now); math.Abs(got-(1.8-5)) > 1e-9 {
+		t.Errorf("Expected blocked tasks to lose urgency, got %.3f", got)
+	}
+}
+
+func TestUrgencyCoefficientsCanBeTuned(t *testing.T) {
+	defer func() { ActiveUrgency = DefaultUrgency }()
+	ActiveUrgency.Priority = map[Priority]float64{}
+	ActiveUrgency.Tags = map[string]float64{"home": 2}
+	task := Todo{ID: 1, Tags: []string{"home"}}
+	if got := (Todos{task}).Urgency(task, time.Now()); got != 2 {
+		t.Errorf("Expected only the tag coefficient to apply, got %v", got)
+	}
+}
+
+func TestMostUrgent(t *testing.T) {
+	now := time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC)
+	overdue := date("2024-03-01")
+	todos := Todos{
+		{ID: 1, Priority: High},
+		{ID: 2, DueDate: &overdue},
+		{ID: 3, Priority: High, Tags: []string{"urgent"}, Completed: true},
+	}
+	if task, ok := todos.MostUrgent(todos, now); !ok || task.ID != 2 {
+		t.Errorf("Expected task 2, got %+v", task)
+	}
+	if ids := todos.ByUrgency(todos, now); ids[0].ID != 2 || ids[1].ID != 1 || ids[2].ID != 3 {
+		t.Errorf("Unexpected order %+v", ids)
+	}
+	if _, ok := todos.MostUrgent(Todos{todos[2]}, now); ok {
+		t.Errorf("Expected no task when all are closed")
+	}
+}
@@ -0,0 +1,308 @@
+package todo
+
+import (
+	"cmp"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SortKey is one field to order tasks by.
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// SortFields are the fields tasks can be sorted by.
+var SortFields = []string{"id", "task", "priority", "due", "created", "updated", "completed", "status", "tag", "urgency", "list"}
+
+// ParseSort parses a comma-separated list of sort fields, each optionally
+// followed by "-" or ":desc" for descending order, or "+" or ":asc".
+func ParseSort(s string) ([]SortKey, error) {
+	var keys []SortKey
+	for _, part := range strings.Split(s, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		key := SortKey{Desc: strings.HasSuffix(part, ":desc") || strings.HasSuffix(part, "-")}
+		key.Field = strings.TrimRight(strings.TrimSuffix(strings.TrimSuffix(part, ":desc"), ":asc"), "+-")
+		if !ContainsString(SortFields, key.Field) {
+			return nil, fmt.Errorf("invalid sort field: %s (use one of: %s)", key.Field, strings.Join(SortFields, ", "))
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Sort returns the tasks of view ordered by keys, the first key deciding
+// first. Tasks missing a field, such as a due date, come last whichever the
+// direction; tasks equal on every key keep their order. Urgency and blocked
+// state are worked out from t.
+func (t Todos) Sort(view Todos, keys []SortKey, now time.Time) Todos {
+	sorted := view.Clone()
+	urgency := map[int]float64{}
+	for _, key := range keys {
+		if key.Field == "urgency" {
+			for _, task := range sorted {
+				urgency[task.ID] = t.Urgency(task, now)
+			}
+			break
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		for _, key := range keys {
+			if c := compareTasks(key, sorted[i], sorted[j], urgency); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return sorted
+}
+
+// compareTasks compares a and b on one key, with tasks missing the field
+// last.
+func compareTasks(key SortKey, a, b Todo, urgency map[int]float64) int {
+	var c int
+	switch key.Field {
+	case "id":
+		c = cmp.Compare(a.ID, b.ID)
+	case "task":
+		c = cmp.Compare(strings.ToLower(a.Task), strings.ToLower(b.Task))
+	case "priority":
+		c = cmp.Compare(a.Priority, b.Priority)
+	case "due":
+		return compareTimes(key, a.DueDate, b.DueDate)
+	case "created":
+		return compareTimes(key, a.CreatedAt, b.CreatedAt)
+	case "updated":
+		return compareTimes(key, a.UpdatedAt, b.UpdatedAt)
+	case "completed":
+		return compareTimes(key, a.CompletedAt, b.CompletedAt)
+	case "status":
+		c = cmp.Compare(stateIndex(a), stateIndex(b))
+	case "tag":
+		return compareMissingLast(key, firstTag(a), firstTag(b))
+	case "urgency":
+		c = cmp.Compare(urgency[a.ID], urgency[b.ID])
+	case "list":
+		c = cmp.Compare(listName(a), listName(b))
+	}
+	if key.Desc {
+		return -c
+	}
+	return c
+}
+
+func compareTimes(key SortKey, a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	case key.Desc:
+		return -a.Compare(*b)
+	default:
+		return a.Compare(*b)
+	}
+}
+
+// compareMissingLast compares two values where "" means missing.
+func compareMissingLast(key SortKey, a, b string) int {
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	case key.Desc:
+		return -cmp.Compare(a, b)
+	default:
+		return cmp.Compare(a, b)
+	}
+}
+
+// stateIndex orders tasks by their place in the workflow, unknown states
+// last.
+func stateIndex(task Todo) int {
+	state := ActiveWorkflow.StateOf(task)
+	for i, s := range ActiveWorkflow.States {
+		if s == state {
+			return i
+		}
+	}
+	return len(ActiveWorkflow.States)
+}
+
+func firstTag(task Todo) string {
+	first := ""
+	for _, tag := range task.Tags {
+		if tag = strings.ToLower(tag); first == "" || tag < first {
+			first = tag
+		}
+	}
+	return first
+}
+
+func listName(task Todo) string {
+	if task.List == "" {
+		return DefaultList
+	}
+	return task.List
+}
+
+// Columns are the columns PrintTable can show, in their default order.
+var Columns = []string{"id", "task", "due", "priority", "urgency", "status", "tags", "list", "parent", "notes", "annotations", "created", "updated", "completed"}
+
+// DefaultColumns are shown unless a view picks others.
+var DefaultColumns = []string{"id", "task", "due", "priority", "urgency", "status", "tags"}
+
+var columnHeaders = map[string]string{
+	"id": "ID", "task": "Task", "due": "Due Date", "priority": "Priority", "urgency": "Urgency",
+	"status": "Status", "tags": "Tags", "list": "List", "parent": "Parent", "notes": "Notes", "annotations": "Annotations", "created": "Created", "updated": "Updated", "completed": "Completed",
+}
+
+// columnWidths are the minimum widths of some columns, so that the table
+// keeps its shape as values change.
+var columnWidths = map[string]int{"id": 4, "due": len("YYYY-MM-DD"), "priority": 8, "urgency": 7, "tags": 20}
+
+// ParseColumns parses a comma-separated list of column names.
+func ParseColumns(s string) ([]string, error) {
+	var columns []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !ContainsString(Columns, name) {
+			return nil, fmt.Errorf("invalid column: %s (use one of: %s)", name, strings.Join(Columns, ", "))
+		}
+		columns = append(columns, name)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns given")
+	}
+	return columns, nil
+}
+
+// PrintTable prints the tasks in view, a subset of all, as a table of the
+// given columns. Subtasks are indented under their parent. Whether a task is
+// blocked, and its urgency, are worked out from all.
+func PrintTable(todos *Todos, all Todos, columns []string) {
+	if len(*todos) == 0 {
+		fmt.Println("No tasks. Your todo list is empty.")
+		return
+	}
+
+	now := Now()
+	entries := todos.tree()
+	cells := make([][]string, len(entries))
+	widths := make([]int, len(columns))
+	for i, name := range columns {
+		widths[i] = max(columnWidths[name], len(columnHeaders[name]))
+	}
+	for row, entry := range entries {
+		task := (*todos)[entry.index]
+		cells[row] = make([]string, len(columns))
+		for i, name := range columns {
+			cells[row][i] = cellValue(name, task, entry.depth, all, now)
+			widths[i] = max(widths[i], len(cells[row][i]))
+		}
+	}
+
+	format := func(values []string) string {
+		padded := make([]string, len(values))
+		for i, v := range values {
+			padded[i] = fmt.Sprintf("%-*s", widths[i], v)
+		}
+		return "| " + strings.Join(padded, " | ") + " |"
+	}
+	headers := make([]string, len(columns))
+	for i, name := range columns {
+		headers[i] = columnHeaders[name]
+	}
+	header := format(headers)
+	divider := strings.Repeat("-", len(header))
+
+	fmt.Println(divider)
+	fmt.Println(header)
+	fmt.Println(divider)
+	for row, entry := range entries {
+		fmt.Println(highlight((*todos)[entry.index], now, format(cells[row])))
+	}
+	fmt.Println(divider)
+}
+
+func cellValue(column string, task Todo, depth int, all Todos, now time.Time) string {
+	switch column {
+	case "id":
+		return fmt.Sprint(task.ID)
+	case "task":
+		if depth > 0 {
+			return strings.Repeat("  ", depth-1) + "- " + task.Task
+		}
+		return task.Task
+	case "due":
+		return task.DueLabel(now)
+	case "priority":
+		return task.Priority.String()
+	case "urgency":
+		return FormatUrgency(all.Urgency(task, now))
+	case "status":
+		return all.StatusOf(task)
+	case "tags":
+		return FormatTags(task.Tags)
+	case "list":
+		return listName(task)
+	case "parent":
+		if task.ParentID == 0 {
+			return ""
+		}
+		return fmt.Sprint(task.ParentID)
+	case "notes":
+		// Only the first line, so that rows stay one line high.
+		first, _, _ := strings.Cut(task.Notes, "\n")
+		return first
+	case "annotations":
+		return formatAnnotations(task.Annotations, "2006-01-02 15:04", "; ")
+	case "created":
+		return formatDay(task.CreatedAt)
+	case "updated":
+		return formatDay(task.UpdatedAt)
+	case "completed":
+		return formatDay(task.CompletedAt)
+	}
+	return ""
+}
+
+// formatAnnotations renders each annotation as its time in layout followed by
+// its text, separated by sep.
+func formatAnnotations(annotations []Annotation, layout, sep string) string {
+	lines := make([]string, len(annotations))
+	for i, a := range annotations {
+		lines[i] = a.Time.Local().Format(layout) + " " + a.Text
+	}
+	return strings.Join(lines, sep)
+}
+
+func formatDay(t *time.Time) string {
+	if t == nil {
+		return "N/A"
+	}
+	return t.Local().Format(dateFormat)
+}
+
+// ContainsString reports whether s is one of values.
+func ContainsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package todo
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSort(t *testing.T) {
+	keys, err := ParseSort("priority-, due,Created:desc,task:asc,urgency+")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SortKey{{"priority", true}, {"due", false}, {"created", true}, {"task", false}, {"urgency", false}}
+	if len(keys) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, keys)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("Key %d: expected %v, got %v", i, want[i], keys[i])
+		}
+	}
+	if _, err := ParseSort("colour"); err == nil {
+		t.Errorf("Expected an error for an unknown field")
+	}
+}
+
+func TestSort(t *testing.T) {
+	now := time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC)
+	early, late := date("2024-03-01"), date("2024-04-01")
+	todos := Todos{
+		{ID: 1, Task: "b", Priority: Low, DueDate: &late},
+		{ID: 2, Task: "a", Priority: High},
+		{ID: 3, Task: "c", Priority: High, DueDate: &late, Tags: []string{"work"}},
+		{ID: 4, Task: "d", Priority: High, DueDate: &early, Tags: []string{"home", "zoo"}},
+	}
+	ids := func(sorted Todos) string {
+		var ids []int
+		for _, task := range sorted {
+			ids = append(ids, task.ID)
+		}
+		return FormatIDs(ids)
+	}
+	tests := []struct {
+		sort string
+		want string
+	}{
+		{"priority-,due", "4, 3, 2, 1"},
+		{"due", "4, 1, 3, 2"},
+		// Tasks without a due date stay last either way.
+		{"due-", "1, 3, 4, 2"},
+		{"task", "2, 1, 3, 4"},
+		{"tag", "4, 3, 1, 2"},
+		{"id-", "4, 3, 2, 1"},
+		{"urgency-", "4, 3, 2, 1"},
+	}
+	for _, tt := range tests {
+		keys, _ := ParseSort(tt.sort)
+		if got := ids(todos.Sort(todos, keys, now)); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.sort, tt.want, got)
+		}
+	}
+}
+
+func TestPrintTableColumns(t *testing.T) {
+	if _, err := ParseColumns("id,colour"); err == nil {
+		t.Errorf("Expected an error for an unknown column")
+	}
+	columns, err := ParseColumns("task, list,id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	todos := &Todos{{ID: 7, Task: "Write report", List: "work", Priority: High}}
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	PrintTable(todos, *todos, columns)
+	w.Close()
+	os.Stdout = old
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+
+	lines := strings.Split(buf.String(), "\n")
+	if !strings.HasPrefix(lines[1], "| Task ") || !strings.Contains(lines[1], "| List | ID   |") {
+		t.Errorf("Expected the columns in the given order, got %q", lines[1])
+	}
+	if !strings.Contains(lines[3], "| Write report | work | 7    |") || strings.Contains(buf.String(), "High") {
+		t.Errorf("Expected only the chosen columns, got %q", buf.String())
+	}
+}
@@ -2,6 +2,7 @@ package todo
 
 import (
 	"fmt"
+	"math"
 	"strings"
 )
 
@@ -23,15 +24,16 @@ func VisualizeTasksByPriority(todos *Todos) string {
 			maxCount = count
 		}
 	}
+	if maxCount == 0 {
+		return "No tasks available."
+	}
 
 	var result strings.Builder
 	result.WriteString("Task Distribution by Priority:\n\n")
 
 	for _, priority := range []Priority{Low, Medium, High} {
 		count := priorities[priority]
-		barWidth := int(float64(count) / float64(maxCount) * maxBarWidth)
-		bar := strings.Repeat(barChar, barWidth) + strings.Repeat(emptyChar, maxBarWidth-barWidth)
-		result.WriteString(fmt.Sprintf("%-6s |%s| %d\n", priority, bar, count))
+		result.WriteString(fmt.Sprintf("%-6s |%s| %d\n", priority, bar(count, maxCount), count))
 	}
 
 	return result.String()
@@ -51,8 +53,119 @@ func VisualizeOverallProgress(todos *Todos) string {
 	}
 
 	percentage := float64(completed) / float64(total) * 100
-	barWidth := int(percentage / 5) // 20 characters for 100%
-	bar := strings.Repeat(barChar, barWidth) + strings.Repeat(emptyChar, 20-barWidth)
+	result := fmt.Sprintf("Overall Progress:\n\n[%s] %.1f%% (%d/%d tasks completed)", bar(completed, total), percentage, completed, total)
+	return result + visualizeStatuses(todos) + visualizeSubtaskProgress(todos)
+}
+
+// visualizeStatuses breaks the tasks down by workflow state, in the
+// workflow's order, followed by any states it no longer has.
+func visualizeStatuses(todos *Todos) string {
+	if len(*todos) == 0 {
+		return ""
+	}
+	states, counts := statusCounts(*todos)
+
+	width := 0
+	for _, state := range states {
+		if len(state) > width {
+			width = len(state)
+		}
+	}
+	var result strings.Builder
+	result.WriteString("\n\nBy Status:\n")
+	for _, state := range states {
+		result.WriteString(fmt.Sprintf("\n%-*s |%s| %d", width, FormatStatus(state), bar(counts[state], len(*todos)), counts[state]))
+	}
+	return result.String()
+}
+
+// visualizeSubtaskProgress rolls up the progress of every task with
+// subtasks, counting all of its descendants.
+func visualizeSubtaskProgress(todos *Todos) string {
+	var result strings.Builder
+	for _, entry := range todos.tree() {
+		parent := (*todos)[entry.index]
+		done, total := todos.Progress(parent.ID)
+		if total == 0 {
+			continue
+		}
+		if result.Len() == 0 {
+			result.WriteString("\n\nSubtask Progress:\n")
+		}
+		result.WriteString(fmt.Sprintf("\n%s[%s] %d/%d  #%d %s", strings.Repeat("  ", entry.depth), bar(done, total), done, total, parent.ID, parent.Task))
+	}
+	return result.String()
+}
+
+// bar draws n out of total, empty if total is 0.
+func bar(n, total int) string {
+	width := 0
+	if total > 0 {
+		width = n * maxBarWidth / total
+	}
+	return strings.Repeat(barChar, width) + strings.Repeat(emptyChar, maxBarWidth-width)
+}
+
+// statusCounts counts tasks by workflow state. States are in the workflow's
+// order, followed by any states it no longer has.
+func statusCounts(todos Todos) ([]string, map[string]int) {
+	counts := map[string]int{}
+	states := append([]string(nil), ActiveWorkflow.States...)
+	for _, todo := range todos {
+		state := ActiveWorkflow.StateOf(todo)
+		if counts[state] == 0 && !ActiveWorkflow.Has(state) {
+			states = append(states, state)
+		}
+		counts[state]++
+	}
+	return states, counts
+}
+
+// Stats are the numbers the charts above show, for output to scripts.
+type Stats struct {
+	Total      int            `json:"total"`
+	Completed  int            `json:"completed"`
+	Progress   float64        `json:"progress"` // percent completed
+	ByPriority map[string]int `json:"by_priority"`
+	ByStatus   map[string]int `json:"by_status"`
+	Subtasks   []SubtaskStats `json:"subtasks"`
+
+	states []string // keys of ByStatus in workflow order
+}
+
+// SubtaskStats is the progress of a task with subtasks, counting all of its
+// descendants.
+type SubtaskStats struct {
+	ID    int    `json:"id"`
+	Task  string `json:"task"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
 
-	return fmt.Sprintf("Overall Progress:\n\n[%s] %.1f%% (%d/%d tasks completed)", bar, percentage, completed, total)
+// Stats works out the numbers of the charts for the tasks of t.
+func (t Todos) Stats() Stats {
+	s := Stats{Total: len(t), ByPriority: map[string]int{}, Subtasks: []SubtaskStats{}}
+	for _, priority := range []Priority{Low, Medium, High} {
+		s.ByPriority[strings.ToLower(priority.String())] = 0
+	}
+	for _, todo := range t {
+		s.ByPriority[strings.ToLower(todo.Priority.String())]++
+		if todo.Completed {
+			s.Completed++
+		}
+	}
+	if s.Total > 0 {
+		s.Progress = math.Round(float64(s.Completed)/float64(s.Total)*1000) / 10
+	}
+	s.states, s.ByStatus = statusCounts(t)
+	for _, state := range s.states {
+		s.ByStatus[state] += 0
+	}
+	for _, entry := range t.tree() {
+		parent := t[entry.index]
+		if done, total := t.Progress(parent.ID); total > 0 {
+			s.Subtasks = append(s.Subtasks, SubtaskStats{parent.ID, parent.Task, done, total})
+		}
+	}
+	return s
 }
@@ -0,0 +1,219 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrTransition is returned for status changes the workflow does not allow.
+var ErrTransition = errors.New("status change not allowed")
+
+// Workflow is the set of states a task moves through. Completed is kept in
+// step with it: a task is completed exactly when it is in the Done state.
+type Workflow struct {
+	// States lists every state, in display order.
+	States []string
+	// Initial is the state of new tasks, Done the state completing a task
+	// moves it to.
+	Initial string
+	Done    string
+	// Started and Cancelled are the states the start and cancel commands
+	// move tasks to, or "" if the workflow has no such state.
+	Started   string
+	Cancelled string
+	// Closed are the states in which no more work is expected: Done and,
+	// for example, cancelled. Tasks waiting for a closed task are not
+	// blocked by it.
+	Closed []string
+	// Transitions lists the states each state may move to. A nil map
+	// allows every change.
+	Transitions map[string][]string
+}
+
+// DefaultWorkflow is used unless the config file sets up another one.
+var DefaultWorkflow = Workflow{
+	States:    []string{"todo", "in-progress", "waiting", "done", "cancelled"},
+	Initial:   "todo",
+	Done:      "done",
+	Started:   "in-progress",
+	Cancelled: "cancelled",
+	Closed:    []string{"done", "cancelled"},
+	Transitions: map[string][]string{
+		"todo":        {"in-progress", "waiting", "done", "cancelled"},
+		"in-progress": {"todo", "waiting", "done", "cancelled"},
+		"waiting":     {"todo", "in-progress", "done", "cancelled"},
+		"done":        {"todo"},
+		"cancelled":   {"todo"},
+	},
+}
+
+// ActiveWorkflow is the workflow tasks follow.
+var ActiveWorkflow = DefaultWorkflow
+
+// Validate checks that every state the workflow refers to is one of its
+// States.
+func (w Workflow) Validate() error {
+	if len(w.States) == 0 {
+		return fmt.Errorf("workflow has no states")
+	}
+	seen := map[string]bool{}
+	for _, state := range w.States {
+		if strings.TrimSpace(state) == "" {
+			return fmt.Errorf("workflow has an empty state name")
+		}
+		if seen[state] {
+			return fmt.Errorf("workflow lists state %q twice", state)
+		}
+		seen[state] = true
+	}
+	check := func(what, state string) error {
+		if !seen[state] {
+			return fmt.Errorf("workflow %s %q is not one of its states", what, state)
+		}
+		return nil
+	}
+	if err := check("initial state", w.Initial); err != nil {
+		return err
+	}
+	if err := check("done state", w.Done); err != nil {
+		return err
+	}
+	if w.Started != "" {
+		if err := check("started state", w.Started); err != nil {
+			return err
+		}
+	}
+	if w.Cancelled != "" {
+		if err := check("cancelled state", w.Cancelled); err != nil {
+			return err
+		}
+	}
+	if w.Initial == w.Done {
+		return fmt.Errorf("workflow initial and done states must differ")
+	}
+	for _, state := range w.Closed {
+		if err := check("closed state", state); err != nil {
+			return err
+		}
+	}
+	for from, targets := range w.Transitions {
+		if err := check("transition from", from); err != nil {
+			return err
+		}
+		for _, to := range targets {
+			if err := check("transition to", to); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// StateOf returns the state task is in.
+func (w Workflow) StateOf(task Todo) string {
+	switch {
+	case task.Completed:
+		return w.Done
+	case task.Status == "":
+		return w.Initial
+	default:
+		return task.Status
+	}
+}
+
+// Has reports whether state is one of the workflow's states.
+func (w Workflow) Has(state string) bool {
+	for _, s := range w.States {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// IsClosed reports whether no more work is expected in state.
+func (w Workflow) IsClosed(state string) bool {
+	if state == w.Done {
+		return true
+	}
+	for _, s := range w.Closed {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the states a task in state may move to. A task in a state
+// the workflow does not know, for example after the config changed, may
+// move to any state.
+func (w Workflow) Next(state string) []string {
+	targets, ok := w.Transitions[state]
+	if w.Transitions == nil || (!ok && !w.Has(state)) {
+		var all []string
+		for _, s := range w.States {
+			if s != state {
+				all = append(all, s)
+			}
+		}
+		return all
+	}
+	return targets
+}
+
+// CanMove reports whether a task may move from one state to another.
+func (w Workflow) CanMove(from, to string) bool {
+	for _, s := range w.Next(from) {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsClosed reports whether no more work is expected on task.
+func (t Todo) IsClosed() bool {
+	return ActiveWorkflow.IsClosed(ActiveWorkflow.StateOf(t))
+}
+
+// SetStatus moves the task at index to state, if the active workflow allows
+// it. Moving to the done state completes the task, as Complete does.
+func (t *Todos) SetStatus(meta *Meta, index int, state string) error {
+	if index < 0 || index >= len(*t) {
+		return fmt.Errorf("index out of range")
+	}
+	w := ActiveWorkflow
+	task := &(*t)[index]
+	from := w.StateOf(*task)
+	if !w.Has(state) {
+		return fmt.Errorf("unknown status %q (use one of: %s)", state, strings.Join(w.States, ", "))
+	}
+	if from == state {
+		return nil
+	}
+	if !w.CanMove(from, state) {
+		return fmt.Errorf("%w: %s -> %s (from %s: %s)", ErrTransition, from, state, from, formatStates(w.Next(from)))
+	}
+	if state == w.Done {
+		return t.Complete(meta, index)
+	}
+
+	task.RecordChange("Status", from, state)
+	if task.Completed {
+		task.Completed = false
+		task.CompletedAt = nil
+	}
+	task.Status = state
+	if state == w.Initial {
+		task.Status = ""
+	}
+	return nil
+}
+
+func formatStates(states []string) string {
+	if len(states) == 0 {
+		return "none"
+	}
+	return strings.Join(states, ", ")
+}
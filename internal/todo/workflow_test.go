@@ -0,0 +1,71 @@
+package todo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWorkflowValidate(t *testing.T) {
+	if err := DefaultWorkflow.Validate(); err != nil {
+		t.Errorf("Expected the default workflow to be valid, got %v", err)
+	}
+	tests := []struct {
+		name     string
+		workflow Workflow
+	}{
+		{"no states", Workflow{}},
+		{"duplicate state", Workflow{States: []string{"a", "a", "b"}, Initial: "a", Done: "b"}},
+		{"unknown done", Workflow{States: []string{"a", "b"}, Initial: "a", Done: "c"}},
+		{"same initial and done", Workflow{States: []string{"a", "b"}, Initial: "a", Done: "a"}},
+		{"unknown started", Workflow{States: []string{"a", "b"}, Initial: "a", Done: "b", Started: "c"}},
+		{"unknown transition", Workflow{States: []string{"a", "b"}, Initial: "a", Done: "b", Transitions: map[string][]string{"a": {"c"}}}},
+	}
+	for _, tt := range tests {
+		if err := tt.workflow.Validate(); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestSetStatus(t *testing.T) {
+	todos := Todos{{ID: 1, Task: "Task"}}
+
+	if err := todos.SetStatus(nil, 0, "in-progress"); err != nil {
+		t.Fatalf("Error starting task: %v", err)
+	}
+	if todos[0].Status != "in-progress" || todos.StatusOf(todos[0]) != "In-progress" {
+		t.Errorf("Expected the task to be in progress, got %+v", todos[0])
+	}
+	if err := todos.SetStatus(nil, 0, "someday"); err == nil {
+		t.Errorf("Expected an error for an unknown status")
+	}
+	if err := todos.SetStatus(nil, 0, "cancelled"); err != nil {
+		t.Fatalf("Error cancelling task: %v", err)
+	}
+	if err := todos.SetStatus(nil, 0, "done"); !errors.Is(err, ErrTransition) {
+		t.Errorf("Expected ErrTransition completing a cancelled task, got %v", err)
+	}
+	if err := todos.SetStatus(nil, 0, "todo"); err != nil {
+		t.Fatalf("Error reopening task: %v", err)
+	}
+	if err := todos.SetStatus(nil, 0, "done"); err != nil {
+		t.Fatalf("Error completing task: %v", err)
+	}
+	if !todos[0].Completed || todos[0].Status != "" {
+		t.Errorf("Expected the task to be completed, got %+v", todos[0])
+	}
+	if err := todos.SetStatus(nil, 0, "todo"); err != nil || todos[0].Completed || todos[0].CompletedAt != nil {
+		t.Errorf("Expected reopening to clear completion, got %+v (%v)", todos[0], err)
+	}
+}
+
+func TestCancelledDependencyDoesNotBlock(t *testing.T) {
+	todos := Todos{{ID: 1, Task: "Spike"}, {ID: 2, Task: "Build", DependsOn: []int{1}}}
+	if !todos.IsBlocked(todos[1]) {
+		t.Fatalf("Expected task 2 to be blocked")
+	}
+	todos.SetStatus(nil, 0, "cancelled")
+	if todos.IsBlocked(todos[1]) {
+		t.Errorf("Expected a cancelled dependency not to block")
+	}
+}